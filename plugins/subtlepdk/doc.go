@@ -0,0 +1,46 @@
+//
+// @package subtle-rs
+//
+// @file Package documentation
+// @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+// @version $Id$
+//
+// This program can be distributed under the terms of the GNU GPLv3.
+// See the file LICENSE for details.
+//
+
+// Package subtlepdk provides typed Go bindings of the host functions subtle-rs
+// exports to plugins.
+//
+// The bindings handle the memory of the extism kernel and the JSON encoding, so
+// plugins don't have to declare the imports themselves:
+//
+//	//go:wasmexport run
+//	func run() int32 {
+//		text, _ := subtlepdk.GetFormattedTime("%H:%M")
+//
+//		pdk.OutputString(text)
+//
+//		return 0
+//	}
+//
+// Plugins are built either with TinyGo or with GOOS=wasip1 GOARCH=wasm; the
+// types can be used on any platform, e.g. for tests.
+//
+// The optional exports interval and subscribe pass their value only as output,
+// either as decimal string or as little-endian uint32; non-zero return codes
+// are errors. A successful call with empty output counts as missing, so the
+// config value is used. Output "0" explicitly to update only on demand:
+//
+//	//go:wasmexport interval
+//	func interval() int32 {
+//		pdk.OutputString("0")
+//
+//		return 0
+//	}
+//
+// Host functions with capabilities like exec_command or http_fetch still have
+// to be declared in the manifest of the plugin and granted by the config. The
+// legacy functions get_memory, get_battery and get_cpu are superseded by
+// GetMemoryInfo, GetBatteryStatus and GetCPUUsage and have no bindings.
+package subtlepdk
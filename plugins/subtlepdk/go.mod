@@ -0,0 +1,3 @@
+module github.com/unexist/subtle-rs/plugins/subtlepdk
+
+go 1.22
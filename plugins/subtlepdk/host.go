@@ -0,0 +1,151 @@
+//
+// @package subtle-rs
+//
+// @file Imports of the host functions
+// @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+// @version $Id$
+//
+// This program can be distributed under the terms of the GNU GPLv3.
+// See the file LICENSE for details.
+//
+
+//go:build wasm
+
+package subtlepdk
+
+// Host functions without arguments still take one unused pointer
+
+//go:wasmimport extism:host/user get_formatted_time
+func hostGetFormattedTime(format uint64) uint64
+
+//go:wasmimport extism:host/user format_duration
+func hostFormatDuration(seconds, style uint64) uint64
+
+//go:wasmimport extism:host/user parse_time
+func hostParseTime(time uint64) uint64
+
+//go:wasmimport extism:host/user measure_text
+func hostMeasureText(text uint64) uint64
+
+//go:wasmimport extism:host/user truncate
+func hostTruncate(text, cells, ellipsis uint64) uint64
+
+//go:wasmimport extism:host/user get_memory_info
+func hostGetMemoryInfo(unused uint64) uint64
+
+//go:wasmimport extism:host/user get_load_average
+func hostGetLoadAverage(unused uint64) uint64
+
+//go:wasmimport extism:host/user get_uptime
+func hostGetUptime(unused uint64) uint64
+
+//go:wasmimport extism:host/user log_message
+func hostLogMessage(level, message uint64)
+
+//go:wasmimport extism:host/user exec_command
+func hostExecCommand(request uint64) uint64
+
+//go:wasmimport extism:host/user http_fetch
+func hostHTTPFetch(request uint64) uint64
+
+//go:wasmimport extism:host/user kv_get
+func hostKVGet(key uint64) uint64
+
+//go:wasmimport extism:host/user kv_set
+func hostKVSet(key, value uint64) uint32
+
+//go:wasmimport extism:host/user send_command
+func hostSendCommand(command uint64) uint64
+
+//go:wasmimport extism:host/user notify
+func hostNotify(request uint64) uint64
+
+//go:wasmimport extism:host/user notify_close
+func hostNotifyClose(id uint64) uint64
+
+//go:wasmimport extism:host/user set_tooltip
+func hostSetTooltip(tooltip uint64)
+
+//go:wasmimport extism:host/user set_min_width
+func hostSetMinWidth(cells uint64)
+
+//go:wasmimport extism:host/user schedule_callback
+func hostScheduleCallback(delayMs uint64) uint64
+
+//go:wasmimport extism:host/user cancel_callback
+func hostCancelCallback(id uint64) uint32
+
+//go:wasmimport extism:host/user get_current_view
+func hostGetCurrentView(unused uint64) uint64
+
+//go:wasmimport extism:host/user list_views
+func hostListViews(unused uint64) uint64
+
+//go:wasmimport extism:host/user list_clients
+func hostListClients(unused uint64) uint64
+
+//go:wasmimport extism:host/user get_theme
+func hostGetTheme(unused uint64) uint64
+
+//go:wasmimport extism:host/user get_pointer
+func hostGetPointer(unused uint64) uint64
+
+//go:wasmimport extism:host/user get_clipboard
+func hostGetClipboard(selection uint64) uint64
+
+//go:wasmimport extism:host/user set_clipboard
+func hostSetClipboard(selection, text uint64) uint32
+
+//go:wasmimport extism:host/user get_config_value
+func hostGetConfigValue(key uint64) uint64
+
+//go:wasmimport extism:host/user get_keyboard_layout
+func hostGetKeyboardLayout(unused uint64) uint64
+
+//go:wasmimport extism:host/user set_keyboard_layout
+func hostSetKeyboardLayout(index uint64) uint32
+
+//go:wasmimport extism:host/user get_panel_geometry
+func hostGetPanelGeometry(unused uint64) uint64
+
+//go:wasmimport extism:host/user get_battery_status
+func hostGetBatteryStatus(name uint64) uint64
+
+//go:wasmimport extism:host/user get_disk_usage
+func hostGetDiskUsage(path uint64) uint64
+
+//go:wasmimport extism:host/user get_volume
+func hostGetVolume(unused uint64) uint64
+
+//go:wasmimport extism:host/user set_volume
+func hostSetVolume(request uint64) uint64
+
+//go:wasmimport extism:host/user get_brightness
+func hostGetBrightness(device uint64) uint64
+
+//go:wasmimport extism:host/user set_brightness
+func hostSetBrightness(request uint64) uint64
+
+//go:wasmimport extism:host/user get_mail_count
+func hostGetMailCount(paths uint64) uint64
+
+//go:wasmimport extism:host/user get_temperature
+func hostGetTemperature(sensor uint64) uint64
+
+//go:wasmimport extism:host/user get_media_status
+func hostGetMediaStatus(unused uint64) uint64
+
+//go:wasmimport extism:host/user media_control
+func hostMediaControl(control uint64) uint32
+
+//go:wasmimport extism:host/user get_cpu_usage
+func hostGetCPUUsage(unused uint64) uint64
+
+//go:wasmimport extism:host/user get_process_info
+func hostGetProcessInfo(name uint64) uint64
+
+//go:wasmimport extism:host/user get_network_throughput
+func hostGetNetworkThroughput(iface uint64) uint64
+
+//go:wasmimport extism:host/user get_wifi_status
+func hostGetWifiStatus(iface uint64) uint64
@@ -0,0 +1,138 @@
+//
+// @package subtle-rs
+//
+// @file Memory functions of the extism kernel
+// @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+// @version $Id$
+//
+// This program can be distributed under the terms of the GNU GPLv3.
+// See the file LICENSE for details.
+//
+
+//go:build wasm
+
+package subtlepdk
+
+import (
+	"encoding/binary"
+	"encoding/json"
+)
+
+//go:wasmimport extism:host/env alloc
+func extismAlloc(length uint64) uint64
+
+//go:wasmimport extism:host/env free
+func extismFree(offset uint64)
+
+//go:wasmimport extism:host/env length
+func extismLength(offset uint64) uint64
+
+//go:wasmimport extism:host/env load_u8
+func extismLoadU8(offset uint64) uint32
+
+//go:wasmimport extism:host/env load_u64
+func extismLoadU64(offset uint64) uint64
+
+//go:wasmimport extism:host/env store_u8
+func extismStoreU8(offset uint64, value uint32)
+
+//go:wasmimport extism:host/env store_u64
+func extismStoreU64(offset uint64, value uint64)
+
+// allocString copies text into a new block of kernel memory, which has to be
+// freed by the caller.
+func allocString(text string) uint64 {
+	data := []byte(text)
+	offset := extismAlloc(uint64(len(data)))
+
+	// Copy in words and store the remaining bytes one by one
+	idx := 0
+
+	for ; idx+8 <= len(data); idx += 8 {
+		extismStoreU64(offset+uint64(idx), binary.LittleEndian.Uint64(data[idx:]))
+	}
+
+	for ; idx < len(data); idx++ {
+		extismStoreU8(offset+uint64(idx), uint32(data[idx]))
+	}
+
+	return offset
+}
+
+// readString copies a block of kernel memory returned by the host and frees it.
+func readString(offset uint64) string {
+	if offset == 0 {
+		return ""
+	}
+
+	defer extismFree(offset)
+
+	data := make([]byte, extismLength(offset))
+	idx := 0
+
+	for ; idx+8 <= len(data); idx += 8 {
+		binary.LittleEndian.PutUint64(data[idx:], extismLoadU64(offset+uint64(idx)))
+	}
+
+	for ; idx < len(data); idx++ {
+		data[idx] = byte(extismLoadU8(offset + uint64(idx)))
+	}
+
+	return string(data)
+}
+
+// callString calls a host function with one string argument and returns its result.
+func callString(hostFn func(uint64) uint64, arg string) string {
+	offset := allocString(arg)
+
+	defer extismFree(offset)
+
+	return readString(hostFn(offset))
+}
+
+// callJSON calls a host function with one string argument and decodes the JSON result.
+func callJSON[T any](hostFn func(uint64) uint64, arg string) (T, error) {
+	var result T
+
+	err := json.Unmarshal([]byte(callString(hostFn, arg)), &result)
+
+	return result, err
+}
+
+// callEmpty calls a host function without arguments and decodes the JSON result.
+func callEmpty[T any](hostFn func(uint64) uint64) (T, error) {
+	var result T
+
+	err := json.Unmarshal([]byte(readString(hostFn(0))), &result)
+
+	return result, err
+}
+
+// callRequest encodes the request as JSON, calls the host function and decodes its result.
+func callRequest[T any](hostFn func(uint64) uint64, request any) (T, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		var result T
+
+		return result, err
+	}
+
+	return callJSON[T](hostFn, string(data))
+}
+
+// withStrings calls a host function with several string arguments.
+func withStrings[R any](args []string, call func(offsets []uint64) R) R {
+	offsets := make([]uint64, len(args))
+
+	for idx, arg := range args {
+		offsets[idx] = allocString(arg)
+	}
+
+	defer func() {
+		for _, offset := range offsets {
+			extismFree(offset)
+		}
+	}()
+
+	return call(offsets)
+}
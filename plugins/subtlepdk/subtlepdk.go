@@ -0,0 +1,338 @@
+//
+// @package subtle-rs
+//
+// @file Bindings of the host functions
+// @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+// @version $Id$
+//
+// This program can be distributed under the terms of the GNU GPLv3.
+// See the file LICENSE for details.
+//
+
+//go:build wasm
+
+package subtlepdk
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+// ErrTooManyTimers is returned by ScheduleCallback when 16 timers are pending.
+var ErrTooManyTimers = errors.New("too many pending timers")
+
+// GetFormattedTime formats the current time with strftime specifiers or time
+// component tokens and an optional timezone prefix like TZ=Europe/Berlin;.
+func GetFormattedTime(format string) (string, error) {
+	return callString(hostGetFormattedTime, format), nil
+}
+
+// FormatDuration formats seconds in the styles compact (2h3m), long
+// (2 hours 3 minutes) or clock (02:03:00).
+func FormatDuration(seconds int64, style string) (string, error) {
+	return withStrings([]string{strconv.FormatInt(seconds, 10), style}, func(offsets []uint64) string {
+		return readString(hostFormatDuration(offsets[0], offsets[1]))
+	}), nil
+}
+
+// ParseTime converts a RFC 3339 time to a unix timestamp.
+func ParseTime(rfc3339 string) (int64, error) {
+	return strconv.ParseInt(callString(hostParseTime, rfc3339), 10, 64)
+}
+
+// MeasureText returns the number of display columns of the text.
+func MeasureText(text string) (int, error) {
+	return strconv.Atoi(callString(hostMeasureText, text))
+}
+
+// Truncate cuts the text to the given number of display columns and appends
+// the ellipsis when it fits.
+func Truncate(text string, cells int, ellipsis string) (string, error) {
+	return withStrings([]string{text, strconv.Itoa(cells), ellipsis}, func(offsets []uint64) string {
+		return readString(hostTruncate(offsets[0], offsets[1], offsets[2]))
+	}), nil
+}
+
+// GetMemoryInfo returns the memory and swap usage.
+func GetMemoryInfo() (MemoryInfo, error) {
+	return callEmpty[MemoryInfo](hostGetMemoryInfo)
+}
+
+// GetLoadAverage returns the load averages and the number of processes.
+func GetLoadAverage() (LoadAverage, error) {
+	return callEmpty[LoadAverage](hostGetLoadAverage)
+}
+
+// GetUptime returns the uptime in seconds.
+func GetUptime() (uint64, error) {
+	return strconv.ParseUint(readString(hostGetUptime(0)), 10, 64)
+}
+
+// Log writes the message with the level (error, warn, info, debug or trace)
+// to the log of the window manager.
+func Log(level, message string) {
+	withStrings([]string{level, message}, func(offsets []uint64) struct{} {
+		hostLogMessage(offsets[0], offsets[1])
+
+		return struct{}{}
+	})
+}
+
+// ExecCommand runs a command; needs the exec capability and allow_exec.
+// Commands that finish within 50ms return their output right away. Longer ones
+// continue in the background, so the output has Pending set until the same
+// command is run again in the run after it finished; the output is returned
+// only once there and any later call runs the command again.
+func ExecCommand(request CommandRequest) (CommandOutput, error) {
+	return callRequest[CommandOutput](hostExecCommand, request)
+}
+
+// HTTPFetch sends a http request; needs the http capability for the host and
+// allowed_hosts. Requests run in the background, so the response has Pending
+// set until the same request is made again in the run after it finished; the
+// response is returned only once there and any later call sends it again.
+func HTTPFetch(request HTTPRequest) (HTTPResponse, error) {
+	return callRequest[HTTPResponse](hostHTTPFetch, request)
+}
+
+// KVGet returns a stored value or an empty string; needs the kv capability.
+func KVGet(key string) (string, error) {
+	return callString(hostKVGet, key), nil
+}
+
+// KVSet stores a value or removes it when empty; needs the kv capability.
+func KVSet(key, value string) bool {
+	return withStrings([]string{key, value}, func(offsets []uint64) bool {
+		return hostKVSet(offsets[0], offsets[1]) != 0
+	})
+}
+
+// SendCommand queues a window manager command; needs the command capability.
+func SendCommand(command WmCommand) (Result, error) {
+	return callRequest[Result](hostSendCommand, command)
+}
+
+// Notify shows a desktop notification in the background, so the result has
+// Pending set until the same call is made again in the next run.
+func Notify(request NotifyRequest) (Result, error) {
+	return callRequest[Result](hostNotify, request)
+}
+
+// NotifyClose closes a desktop notification in the background.
+func NotifyClose(id uint32) (Result, error) {
+	return callJSON[Result](hostNotifyClose, strconv.FormatUint(uint64(id), 10))
+}
+
+// SetTooltip sets the tooltip of the panel item; an empty text clears it.
+func SetTooltip(tooltip string) {
+	offset := allocString(tooltip)
+
+	defer extismFree(offset)
+
+	hostSetTooltip(offset)
+}
+
+// SetMinWidth reserves a minimum width in cells of the font.
+func SetMinWidth(cells uint16) {
+	offset := allocString(strconv.FormatUint(uint64(cells), 10))
+
+	defer extismFree(offset)
+
+	hostSetMinWidth(offset)
+}
+
+// ScheduleCallback schedules a one-shot timer, which calls the on_timer export
+// after the delay, and returns its id.
+func ScheduleCallback(delayMs uint64) (uint32, error) {
+	id := callString(hostScheduleCallback, strconv.FormatUint(delayMs, 10))
+
+	if id == "" {
+		return 0, ErrTooManyTimers
+	}
+
+	parsed, err := strconv.ParseUint(id, 10, 32)
+
+	return uint32(parsed), err
+}
+
+// CancelCallback cancels a pending timer.
+func CancelCallback(id uint32) bool {
+	offset := allocString(strconv.FormatUint(uint64(id), 10))
+
+	defer extismFree(offset)
+
+	return hostCancelCallback(offset) != 0
+}
+
+// GetCurrentView returns the view of the focused screen or nil.
+func GetCurrentView() (*ViewInfo, error) {
+	return callEmpty[*ViewInfo](hostGetCurrentView)
+}
+
+// ListViews returns all views.
+func ListViews() ([]ViewSummary, error) {
+	return callEmpty[[]ViewSummary](hostListViews)
+}
+
+// ListClients returns the clients of the current view.
+func ListClients() ([]ClientInfo, error) {
+	return callEmpty[[]ClientInfo](hostListClients)
+}
+
+// GetTheme returns the colors of the panel.
+func GetTheme() (ThemeColors, error) {
+	return callEmpty[ThemeColors](hostGetTheme)
+}
+
+// GetPointer returns the pointer position or nil when it cannot be queried.
+func GetPointer() (*PointerInfo, error) {
+	return callEmpty[*PointerInfo](hostGetPointer)
+}
+
+// GetClipboard returns the content of the clipboard or primary selection;
+// needs the clipboard capability.
+func GetClipboard(selection string) (SelectionContent, error) {
+	return callJSON[SelectionContent](hostGetClipboard, selection)
+}
+
+// SetClipboard sets the content of the clipboard or primary selection; needs
+// the clipboard capability.
+func SetClipboard(selection, text string) bool {
+	return withStrings([]string{selection, text}, func(offsets []uint64) bool {
+		return hostSetClipboard(offsets[0], offsets[1]) != 0
+	})
+}
+
+// GetConfigValue returns a whitelisted global config value like locale or an
+// empty string.
+func GetConfigValue(key string) (string, error) {
+	return callString(hostGetConfigValue, key), nil
+}
+
+// GetKeyboardLayout returns the current keyboard layout or nil.
+func GetKeyboardLayout() (*KeyboardLayout, error) {
+	return callEmpty[*KeyboardLayout](hostGetKeyboardLayout)
+}
+
+// SetKeyboardLayout switches to the keyboard layout with the index.
+func SetKeyboardLayout(index int) bool {
+	offset := allocString(strconv.Itoa(index))
+
+	defer extismFree(offset)
+
+	return hostSetKeyboardLayout(offset) != 0
+}
+
+// GetPanelGeometry returns the geometry of the calling panel or nil. Plugins
+// shown on several panels are run once per panel; MonitorIndex and IsBottom
+// tell them apart.
+func GetPanelGeometry() (*PanelGeometry, error) {
+	return callEmpty[*PanelGeometry](hostGetPanelGeometry)
+}
+
+// GetBatteryStatus returns the status of the named battery or of the first
+// one when the name is empty.
+func GetBatteryStatus(name string) (BatteryStatus, error) {
+	return callJSON[BatteryStatus](hostGetBatteryStatus, name)
+}
+
+// GetDiskUsage returns the usage of the filesystem of the path.
+func GetDiskUsage(path string) (DiskUsage, error) {
+	return callJSON[DiskUsage](hostGetDiskUsage, path)
+}
+
+// GetVolume returns the last known volume of the default sink and refreshes it
+// in the background; Pending is set until the first state is there.
+func GetVolume() (VolumeState, error) {
+	return callEmpty[VolumeState](hostGetVolume)
+}
+
+// SetVolume changes the volume of the default sink in the background and
+// returns the last known state with Pending set; the plugin is run again once
+// it is done.
+func SetVolume(request VolumeRequest) (VolumeState, error) {
+	return callRequest[VolumeState](hostSetVolume, request)
+}
+
+// GetBrightness returns the brightness of the named backlight device or of
+// the first one when the name is empty.
+func GetBrightness(device string) (BrightnessStatus, error) {
+	return callJSON[BrightnessStatus](hostGetBrightness, device)
+}
+
+// SetBrightness changes the brightness and returns the new state.
+func SetBrightness(request BrightnessRequest) (BrightnessStatus, error) {
+	return callRequest[BrightnessStatus](hostSetBrightness, request)
+}
+
+// GetMailCount counts the messages of the Maildirs; missing ones count as zero.
+func GetMailCount(paths ...string) (MailCount, error) {
+	data, err := json.Marshal(paths)
+	if err != nil {
+		return MailCount{}, err
+	}
+
+	return callJSON[MailCount](hostGetMailCount, string(data))
+}
+
+// GetTemperatures returns all temperature sensors.
+func GetTemperatures() ([]Temperature, error) {
+	return callJSON[[]Temperature](hostGetTemperature, "")
+}
+
+// GetTemperature returns the first sensor matching label or chip or nil.
+func GetTemperature(sensor string) (*Temperature, error) {
+	if sensor == "" {
+		return nil, errors.New("sensor name required")
+	}
+
+	return callJSON[*Temperature](hostGetTemperature, sensor)
+}
+
+// GetMediaStatus returns the last known status of the MPRIS media player and
+// refreshes it in the background; Pending is set until the first one is there.
+func GetMediaStatus() (MediaStatus, error) {
+	return callEmpty[MediaStatus](hostGetMediaStatus)
+}
+
+// MediaControl controls the media player with play_pause, next or previous in
+// the background; the plugin is run again once it is done.
+func MediaControl(action string) bool {
+	data, err := json.Marshal(struct {
+		Action string `json:"action"`
+	}{action})
+	if err != nil {
+		return false
+	}
+
+	offset := allocString(string(data))
+
+	defer extismFree(offset)
+
+	return hostMediaControl(offset) != 0
+}
+
+// GetCPUUsage returns the busy percentage since the last call.
+func GetCPUUsage() (CPUUsage, error) {
+	return callEmpty[CPUUsage](hostGetCPUUsage)
+}
+
+// GetProcessInfo returns all processes with the name; the list is empty when
+// none is running.
+func GetProcessInfo(name string) ([]ProcessInfo, error) {
+	return callJSON[[]ProcessInfo](hostGetProcessInfo, name)
+}
+
+// GetNetworkThroughput returns the rates of the interface or of the one of
+// the default route when empty.
+func GetNetworkThroughput(iface string) (NetThroughput, error) {
+	return callJSON[NetThroughput](hostGetNetworkThroughput, iface)
+}
+
+// GetWifiStatus returns the last known status of the wireless interface or of
+// the first one when empty and refreshes it in the background; Pending is set
+// until the first status is there.
+func GetWifiStatus(iface string) (WifiStatus, error) {
+	return callJSON[WifiStatus](hostGetWifiStatus, iface)
+}
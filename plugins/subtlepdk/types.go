@@ -0,0 +1,503 @@
+//
+// @package subtle-rs
+//
+// @file Types of the host functions
+// @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+// @version $Id$
+//
+// This program can be distributed under the terms of the GNU GPLv3.
+// See the file LICENSE for details.
+//
+
+package subtlepdk
+
+// Output is the JSON envelope plugins can return instead of plain text.
+type Output struct {
+	// Version of the envelope; must be 1
+	Version uint32 `json:"version"`
+	// Text to display
+	Text string `json:"text"`
+	// Whether the text contains markup; the host defaults to true
+	Markup *bool `json:"markup,omitempty"`
+	// Minimum width in cells of the font like SetMinWidth
+	MinWidth uint16 `json:"min_width,omitempty"`
+	// Alignment of the text inside of the minimum width (left, center or right)
+	Align string `json:"align,omitempty"`
+	// Commands to run on click per mouse button; needs the command capability
+	ClickActions map[uint8]WmCommand `json:"click_actions,omitempty"`
+}
+
+// Placement is the result of the optional placement export.
+type Placement struct {
+	// Side of the panel items (left, center or right)
+	Side string `json:"side,omitempty"`
+	// Position inside of the side; higher positions are placed further right
+	Position *int32 `json:"position,omitempty"`
+}
+
+// ClickEvent is the input of the on_click export.
+type ClickEvent struct {
+	// Mouse button (1=left, 2=middle, 3=right)
+	Button uint8 `json:"button"`
+	// X offset of the click within the panel item
+	X int16 `json:"x"`
+}
+
+// ScrollEvent is the input of the on_scroll export.
+type ScrollEvent struct {
+	// Direction of the wheel (up or down)
+	Direction string `json:"direction"`
+	// Number of wheel steps
+	Delta uint32 `json:"delta"`
+}
+
+// TimerEvent is the input of the on_timer export.
+type TimerEvent struct {
+	// Id of the timer returned by ScheduleCallback
+	ID uint32 `json:"id"`
+}
+
+// MemoryInfo is the result of GetMemoryInfo.
+type MemoryInfo struct {
+	// Total memory in KiB
+	TotalKB uint64 `json:"total_kb"`
+	// Available memory in KiB
+	AvailableKB uint64 `json:"available_kb"`
+	// Used memory (total minus available) in KiB
+	UsedKB uint64 `json:"used_kb"`
+	// Total swap in KiB
+	SwapTotalKB uint64 `json:"swap_total_kb"`
+	// Used swap in KiB
+	SwapUsedKB uint64 `json:"swap_used_kb"`
+	// All raw values of /proc/meminfo in KiB
+	Raw map[string]uint64 `json:"raw"`
+}
+
+// LoadAverage is the result of GetLoadAverage.
+type LoadAverage struct {
+	// Load average of the last minute
+	One float64 `json:"one"`
+	// Load average of the last five minutes
+	Five float64 `json:"five"`
+	// Load average of the last fifteen minutes
+	Fifteen float64 `json:"fifteen"`
+	// Number of currently running processes
+	RunningProcs uint32 `json:"running_procs"`
+	// Total number of processes
+	TotalProcs uint32 `json:"total_procs"`
+}
+
+// CPUUsage is the result of GetCPUUsage.
+type CPUUsage struct {
+	// Busy percentage of all cores
+	Aggregate float64 `json:"aggregate"`
+	// Busy percentage per core
+	Cores []float64 `json:"cores"`
+	// Whether the values are averages since boot due to missing previous sample
+	WarmingUp bool `json:"warming_up"`
+}
+
+// ProcessInfo is an entry of the result of GetProcessInfo.
+type ProcessInfo struct {
+	// Id of the process
+	Pid uint32 `json:"pid"`
+	// Name of the process
+	Name string `json:"name"`
+	// Busy percentage of one core since the last call
+	CPUPercent float64 `json:"cpu_percent"`
+	// Resident memory in kilobytes
+	RSSKb uint64 `json:"rss_kb"`
+	// State like running, sleeping or zombie
+	State string `json:"state"`
+	// Whether the cpu percentage is zero due to missing previous sample
+	WarmingUp bool `json:"warming_up"`
+}
+
+// BatteryStatus is the result of GetBatteryStatus.
+type BatteryStatus struct {
+	// Whether a battery could be found
+	Present bool `json:"present"`
+	// Name of the battery (e.g. BAT0)
+	Name *string `json:"name"`
+	// Charge level in percent
+	Percent *uint8 `json:"percent"`
+	// Whether the battery is charging
+	Charging bool `json:"charging"`
+	// Estimated seconds until empty or full
+	TimeRemainingSecs *uint64 `json:"time_remaining_secs"`
+	// Current power draw in watts
+	PowerDrawWatts *float64 `json:"power_draw_watts"`
+}
+
+// DiskUsage is the result of GetDiskUsage.
+type DiskUsage struct {
+	// Total size of the filesystem in bytes
+	TotalBytes uint64 `json:"total_bytes"`
+	// Used bytes
+	UsedBytes uint64 `json:"used_bytes"`
+	// Bytes available to unprivileged users
+	AvailableBytes uint64 `json:"available_bytes"`
+	// Used percentage without the reserved blocks like df
+	Percent uint8 `json:"percent"`
+	// Error message when the path cannot be read
+	Error *string `json:"error"`
+}
+
+// NetThroughput is the result of GetNetworkThroughput.
+type NetThroughput struct {
+	// Name of the interface
+	Iface string `json:"iface"`
+	// Whether the interface exists
+	Present bool `json:"present"`
+	// Received bytes per second
+	RxBytesPerSec float64 `json:"rx_bytes_per_sec"`
+	// Transmitted bytes per second
+	TxBytesPerSec float64 `json:"tx_bytes_per_sec"`
+	// Total received bytes
+	RxTotal uint64 `json:"rx_total"`
+	// Total transmitted bytes
+	TxTotal uint64 `json:"tx_total"`
+	// Whether the rates are zero due to missing previous sample
+	WarmingUp bool `json:"warming_up"`
+}
+
+// WifiStatus is the result of GetWifiStatus.
+type WifiStatus struct {
+	// Name of the interface
+	Iface string `json:"iface"`
+	// Whether the interface is associated with an access point
+	Connected bool `json:"connected"`
+	// Name of the network
+	SSID string `json:"ssid"`
+	// Signal quality in percent
+	SignalPercent uint8 `json:"signal_percent"`
+	// Receive bitrate in MBit/s
+	BitrateMbps float64 `json:"bitrate_mbps"`
+	// Frequency of the channel in MHz
+	FrequencyMHz uint32 `json:"frequency_mhz"`
+	// Whether the status is outdated until the helper in the background is done
+	Pending bool `json:"pending"`
+}
+
+// MailboxCount is the count of a single account of GetMailCount.
+type MailboxCount struct {
+	// Path of the Maildir
+	Path string `json:"path"`
+	// Whether the path is a Maildir
+	Present bool `json:"present"`
+	// Number of messages in new
+	New uint32 `json:"new"`
+	// Number of new messages and messages in cur without seen flag
+	Unread uint32 `json:"unread"`
+	// Number of all messages
+	Total uint32 `json:"total"`
+}
+
+// MailCount is the result of GetMailCount.
+type MailCount struct {
+	// Number of messages in new of all accounts
+	New uint32 `json:"new"`
+	// Number of unread messages of all accounts
+	Unread uint32 `json:"unread"`
+	// Number of all messages of all accounts
+	Total uint32 `json:"total"`
+	// Counts per account
+	Accounts []MailboxCount `json:"accounts"`
+}
+
+// Temperature is the result of GetTemperature.
+type Temperature struct {
+	// Label of the sensor (e.g. Package id 0)
+	Label string `json:"label"`
+	// Name of the chip of the sensor (e.g. coretemp)
+	Chip string `json:"chip"`
+	// Current temperature in degree celsius
+	Celsius float64 `json:"celsius"`
+	// High threshold in degree celsius
+	High *float64 `json:"high"`
+	// Critical threshold in degree celsius
+	Critical *float64 `json:"critical"`
+}
+
+// VolumeState is the result of GetVolume and SetVolume.
+type VolumeState struct {
+	// Whether a sound server could be reached
+	Present bool `json:"present"`
+	// Volume of the default sink in percent
+	Percent uint8 `json:"percent"`
+	// Whether the default sink is muted
+	Muted bool `json:"muted"`
+	// Error message when the sound server cannot be queried or set
+	Error *string `json:"error"`
+	// Whether the state is outdated until the helper in the background is done
+	Pending bool `json:"pending"`
+}
+
+// VolumeRequest is the argument of SetVolume.
+type VolumeRequest struct {
+	// Absolute volume in percent
+	Percent *int32 `json:"percent,omitempty"`
+	// Relative change of the volume in percent
+	Delta *int32 `json:"delta,omitempty"`
+	// Whether to toggle the mute state
+	ToggleMute bool `json:"toggle_mute,omitempty"`
+}
+
+// BrightnessStatus is the result of GetBrightness and SetBrightness.
+type BrightnessStatus struct {
+	// Whether a backlight device could be found
+	Present bool `json:"present"`
+	// Name of the device (e.g. intel_backlight)
+	Name *string `json:"name"`
+	// Brightness in percent
+	Percent uint8 `json:"percent"`
+	// Raw brightness value of the device
+	Raw uint32 `json:"raw"`
+	// Maximum raw brightness value of the device
+	MaxRaw uint32 `json:"max_raw"`
+	// Error message when the brightness cannot be set
+	Error *string `json:"error"`
+}
+
+// BrightnessRequest is the argument of SetBrightness.
+type BrightnessRequest struct {
+	// Name of the device or empty to use the first one
+	Device string `json:"device,omitempty"`
+	// Absolute brightness in percent
+	Percent *int32 `json:"percent,omitempty"`
+	// Relative change of the brightness in percent
+	Delta *int32 `json:"delta,omitempty"`
+}
+
+// MediaStatus is the result of GetMediaStatus.
+type MediaStatus struct {
+	// Name of the player or nil when no player is running
+	Player *string `json:"player"`
+	// Playback status (playing, paused or stopped)
+	Status string `json:"status"`
+	// Artist of the current track
+	Artist string `json:"artist"`
+	// Title of the current track
+	Title string `json:"title"`
+	// Album of the current track
+	Album string `json:"album"`
+	// Playback position in seconds
+	PositionSecs *uint64 `json:"position_secs"`
+	// Length of the current track in seconds
+	LengthSecs *uint64 `json:"length_secs"`
+	// Error message when the players cannot be queried
+	Error *string `json:"error"`
+	// Whether the status is outdated until the helper in the background is done
+	Pending bool `json:"pending"`
+}
+
+// KeyboardLayout is the result of GetKeyboardLayout.
+type KeyboardLayout struct {
+	// Short name of the current layout (e.g. us)
+	Layout string `json:"layout"`
+	// Variant of the current layout (e.g. nodeadkeys)
+	Variant string `json:"variant"`
+	// Index of the current layout
+	Index int `json:"index"`
+	// Names of all available layouts
+	Available []string `json:"available"`
+}
+
+// CommandRequest is the argument of ExecCommand.
+type CommandRequest struct {
+	// Command to run
+	Cmd string `json:"cmd"`
+	// Arguments of the command
+	Args []string `json:"args,omitempty"`
+	// Timeout in milliseconds (max. 4000)
+	TimeoutMs *uint64 `json:"timeout_ms,omitempty"`
+}
+
+// CommandOutput is the result of ExecCommand.
+type CommandOutput struct {
+	// Captured stdout
+	Stdout string `json:"stdout"`
+	// Captured stderr
+	Stderr string `json:"stderr"`
+	// Exit code or -1 when killed or not run at all
+	ExitCode int32 `json:"exit_code"`
+	// Whether the command has been killed after the timeout
+	TimedOut bool `json:"timed_out"`
+	// Whether the command is still running; the plugin is run again when done
+	Pending bool `json:"pending"`
+}
+
+// HTTPRequest is the argument of HTTPFetch.
+type HTTPRequest struct {
+	// Http method; the host defaults to GET
+	Method string `json:"method,omitempty"`
+	// Url to fetch
+	URL string `json:"url"`
+	// Request headers
+	Headers map[string]string `json:"headers,omitempty"`
+	// Request body
+	Body string `json:"body,omitempty"`
+	// Timeout in milliseconds (max. 4000)
+	TimeoutMs *uint64 `json:"timeout_ms,omitempty"`
+	// Number of retries of transient failures (max. 3)
+	Retries uint32 `json:"retries,omitempty"`
+	// Delay before the first retry in milliseconds; doubles with each retry
+	RetryBackoffMs *uint64 `json:"retry_backoff_ms,omitempty"`
+	// Time in milliseconds a good response is served when later requests fail
+	CacheTTLMs *uint64 `json:"cache_ttl_ms,omitempty"`
+}
+
+// HTTPResponse is the result of HTTPFetch.
+type HTTPResponse struct {
+	// Http status or zero when the request failed
+	Status uint16 `json:"status"`
+	// Response headers
+	Headers map[string]string `json:"headers"`
+	// Response body
+	Body string `json:"body"`
+	// Whether the body has been cut at the size limit
+	Truncated bool `json:"truncated"`
+	// Error message when the request failed
+	Error *string `json:"error"`
+	// Number of attempts made
+	Attempts uint32 `json:"attempts"`
+	// Whether this is a cached response served in place of a failed request
+	Cached bool `json:"cached"`
+	// Whether the request is still running; the plugin is run again when done
+	Pending bool `json:"pending"`
+}
+
+// WmCommand is the argument of SendCommand and the value of click actions.
+type WmCommand struct {
+	// Name of the action (switch_view, spawn, focus_next, focus_prev, focus_client,
+	// restart or reload_sublet)
+	Action string `json:"action"`
+	// Argument of the action
+	Arg string `json:"arg,omitempty"`
+}
+
+// Result is the result of SendCommand, Notify and NotifyClose.
+type Result struct {
+	// Id of the notification; only set by Notify and NotifyClose
+	ID uint32 `json:"id"`
+	// Zero on success or otherwise the error code
+	Code int32 `json:"code"`
+	// Error message on failure
+	Error *string `json:"error"`
+	// Whether the call is still running in the background; only set by Notify
+	// and NotifyClose
+	Pending bool `json:"pending"`
+}
+
+// NotifyRequest is the argument of Notify.
+type NotifyRequest struct {
+	// Summary of the notification
+	Summary string `json:"summary"`
+	// Body of the notification
+	Body string `json:"body,omitempty"`
+	// Urgency (low, normal or critical)
+	Urgency string `json:"urgency,omitempty"`
+	// Expiration timeout in milliseconds; server default when unset
+	TimeoutMs *int32 `json:"timeout_ms,omitempty"`
+	// Icon name or path
+	Icon string `json:"icon,omitempty"`
+}
+
+// ViewInfo is the result of GetCurrentView.
+type ViewInfo struct {
+	// Name of the view
+	Name string `json:"name"`
+	// Index of the view
+	Index int `json:"index"`
+	// Names of the tags of this view
+	TagNames []string `json:"tag_names"`
+	// Number of clients on this view
+	ClientCount int `json:"client_count"`
+	// Whether any client on this view is urgent
+	Urgent bool `json:"urgent"`
+}
+
+// ViewSummary is an entry of the result of ListViews.
+type ViewSummary struct {
+	// Name of the view
+	Name string `json:"name"`
+	// Index of the view
+	Index int `json:"index"`
+	// Whether this is the view of the focused screen
+	Active bool `json:"active"`
+	// Whether the view has at least one client
+	Occupied bool `json:"occupied"`
+	// Whether any client on this view is urgent
+	Urgent bool `json:"urgent"`
+	// Number of clients on this view
+	ClientCount int `json:"client_count"`
+}
+
+// ClientInfo is an entry of the result of ListClients.
+type ClientInfo struct {
+	// Window id of the client
+	ID uint32 `json:"id"`
+	// Sanitized title of the client
+	Title string `json:"title"`
+	// Window class of the client
+	Class string `json:"class"`
+	// Whether the client has the focus
+	Focused bool `json:"focused"`
+	// Whether the client is urgent
+	Urgent bool `json:"urgent"`
+	// Whether the client is minimized
+	Minimized bool `json:"minimized"`
+}
+
+// PointerInfo is the result of GetPointer.
+type PointerInfo struct {
+	// X position in root coordinates
+	X int16 `json:"x"`
+	// Y position in root coordinates
+	Y int16 `json:"y"`
+	// Index of the screen under the pointer
+	Monitor *int `json:"monitor"`
+	// Window under the pointer or nil when over the root window
+	WindowID *uint32 `json:"window_id"`
+	// Window class when the window is a client
+	WindowClass *string `json:"window_class"`
+}
+
+// ThemeColors is the result of GetTheme.
+type ThemeColors struct {
+	// Foreground color of panel items
+	Foreground *string `json:"foreground"`
+	// Background color of panel items
+	Background *string `json:"background"`
+	// Border color of panel items
+	Border *string `json:"border"`
+	// Named accent colors of the other styles
+	Accents map[string]string `json:"accents"`
+}
+
+// PanelGeometry is the result of GetPanelGeometry.
+type PanelGeometry struct {
+	// X position of the panel in pixels
+	X int16 `json:"x"`
+	// Y position of the panel in pixels
+	Y int16 `json:"y"`
+	// Width of the panel in pixels
+	Width uint16 `json:"width"`
+	// Height of the panel in pixels
+	Height uint16 `json:"height"`
+	// Index of the monitor the panel lives on
+	MonitorIndex int `json:"monitor_index"`
+	// Total number of monitors
+	MonitorCount int `json:"monitor_count"`
+	// Whether this is the bottom panel; together with MonitorIndex it tells the
+	// panels apart
+	IsBottom bool `json:"is_bottom"`
+}
+
+// SelectionContent is the result of GetClipboard.
+type SelectionContent struct {
+	// Text of the selection; empty for other types
+	Text string `json:"text"`
+	// Type of the content like text or image/png; empty without owner
+	Type string `json:"type"`
+}
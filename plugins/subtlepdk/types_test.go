@@ -0,0 +1,67 @@
+//
+// @package subtle-rs
+//
+// @file Type tests
+// @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+// @version $Id$
+//
+// This program can be distributed under the terms of the GNU GPLv3.
+// See the file LICENSE for details.
+//
+
+package subtlepdk
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestShouldEncodeOutput(t *testing.T) {
+	data, err := json.Marshal(Output{
+		Version:      1,
+		Text:         "12:00",
+		ClickActions: map[uint8]WmCommand{1: {Action: "spawn", Arg: "alacritty"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"version":1,"text":"12:00","click_actions":{"1":{"action":"spawn","arg":"alacritty"}}}`
+
+	if string(data) != expected {
+		t.Errorf("got %s, want %s", data, expected)
+	}
+}
+
+func TestShouldDecodeOptionalValues(t *testing.T) {
+	var status BatteryStatus
+
+	err := json.Unmarshal([]byte(`{"present":true,"name":"BAT0","percent":null,"charging":false,`+
+		`"time_remaining_secs":null,"power_draw_watts":4.5}`), &status)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !status.Present || status.Name == nil || *status.Name != "BAT0" || status.Percent != nil {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	var view *ViewInfo
+
+	if err := json.Unmarshal([]byte("null"), &view); err != nil || view != nil {
+		t.Errorf("unexpected view %+v: %v", view, err)
+	}
+}
+
+func TestShouldEncodeRequests(t *testing.T) {
+	delta := int32(-5)
+
+	data, err := json.Marshal(BrightnessRequest{Delta: &delta})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != `{"delta":-5}` {
+		t.Errorf("got %s", data)
+	}
+}
@@ -385,7 +385,7 @@ impl Client {
             }
 
             // Check for specific position and size
-            if subtle.flags.contains(SubtleFlags::RESIZE)
+            if subtle.flags.get().contains(SubtleFlags::RESIZE)
                 || self.flags.contains(ClientFlags::MODE_FLOAT | ClientFlags::MODE_RESIZE | ClientFlags::TYPE_DOCK)
             {
                 // User/program position
@@ -678,7 +678,7 @@ impl Client {
 
         if !trans.is_empty() {
             // Check if transient windows should be urgent
-            mode_flags.insert(if subtle.flags.intersects(SubtleFlags::URGENT) {
+            mode_flags.insert(if subtle.flags.get().intersects(SubtleFlags::URGENT) {
                 ClientFlags::MODE_FLOAT | ClientFlags::MODE_URGENT
             } else {
                 ClientFlags::MODE_FLOAT
@@ -767,7 +767,7 @@ impl Client {
                                AtomEnum::WINDOW, list.as_slice())?.check()?;
 
         // Warp pointer
-        if warp_pointer && !subtle.flags.intersects(SubtleFlags::SKIP_POINTER_WARP) {
+        if warp_pointer && !subtle.flags.get().intersects(SubtleFlags::SKIP_POINTER_WARP) {
             self.warp_pointer(subtle)?;
         }
 
@@ -818,7 +818,7 @@ impl Client {
                 // Set screen when required
                 if !self.flags.contains(ClientFlags::MODE_STICK_SCREEN) {
                     // Find screen: Prefer screen of current window
-                    if subtle.flags.contains(SubtleFlags::SKIP_POINTER_WARP)  {
+                    if subtle.flags.get().contains(SubtleFlags::SKIP_POINTER_WARP)  {
                         if let Some(win) = subtle.focus_history.borrow(0) {
                             if let Some(focus) = subtle.find_client(*win) {
                                 if focus.is_visible(subtle) {
@@ -1146,7 +1146,7 @@ impl Client {
                 // Gravity tiling
                 let maybe_old_gravity = subtle.gravities.get(old_gravity_id as usize);
 
-                if -1 != old_screen_id && (subtle.flags.contains(SubtleFlags::GRAVITY_TILING)
+                if -1 != old_screen_id && (subtle.flags.get().contains(SubtleFlags::GRAVITY_TILING)
                     || maybe_old_gravity.is_some() &&
                     maybe_old_gravity.unwrap().flags.contains(GravityFlags::HORZ | GravityFlags::VERT))
                 {
@@ -1155,7 +1155,7 @@ impl Client {
 
                 let maybe_gravity = subtle.gravities.get(gravity_idx as usize);
 
-                if subtle.flags.contains(SubtleFlags::GRAVITY_TILING)
+                if subtle.flags.get().contains(SubtleFlags::GRAVITY_TILING)
                     && (maybe_gravity.is_some()
                     && maybe_gravity.unwrap().flags.contains(GravityFlags::HORZ | GravityFlags::VERT))
                 {
@@ -1597,7 +1597,7 @@ impl Client {
         // Tile remaining clients if necessary
         if self.is_visible(subtle) {
             if let Some(gravity) = subtle.gravities.get(self.gravity_idx as usize) {
-               if subtle.flags.contains(SubtleFlags::GRAVITY_TILING)
+               if subtle.flags.get().contains(SubtleFlags::GRAVITY_TILING)
                    || gravity.flags.contains(GravityFlags::HORZ | GravityFlags::VERT)
                {
                    self.gravity_tile(subtle, self.gravity_idx, self.screen_idx)?;
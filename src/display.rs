@@ -16,6 +16,7 @@ use stdext::function_name;
 use struct_iterable::Iterable;
 use x11rb::connection::Connection;
 use x11rb::{COPY_DEPTH_FROM_PARENT, CURRENT_TIME, NONE};
+use x11rb::protocol::xkb::{ConnectionExt as xkb_ext, EventType, MapPart, NameDetail, SelectEventsAux, SelectEventsAuxBitcase2, SelectEventsAuxBitcase6, StatePart, ID};
 use x11rb::protocol::xproto::{AtomEnum, CapStyle, ChangeWindowAttributesAux, ConnectionExt, CreateGCAux, CreateWindowAux, EventMask, FillStyle, FontWrapper, InputFocus, JoinStyle, LineStyle, MapState, PropMode, SubwindowMode, Time, WindowClass, GX};
 use x11rb::wrapper::ConnectionExt as ConnectionWrapperExt;
 use crate::{client, ewmh, Config, Subtle};
@@ -79,17 +80,42 @@ pub(crate) fn init(config: &Config, subtle: &mut Subtle) -> Result<()> {
 
     // Check extensions
     if conn.query_extension("XINERAMA".as_ref())?.reply()?.present {
-        subtle.flags.insert(SubtleFlags::XINERAMA);
+        subtle.flags.get_mut().insert(SubtleFlags::XINERAMA);
 
         debug!("Found xinerama extension");
     }
 
     if conn.query_extension("RANDR".as_ref())?.reply()?.present {
-        subtle.flags.insert(SubtleFlags::XRANDR);
+        subtle.flags.get_mut().insert(SubtleFlags::XRANDR);
 
         debug!("Found xrandr extension");
     }
 
+    // Xkb must be enabled before use
+    if conn.xkb_use_extension(1, 0).ok()
+        .and_then(|cookie| cookie.reply().ok())
+        .is_some_and(|reply| reply.supported)
+    {
+        subtle.flags.get_mut().insert(SubtleFlags::XKB);
+
+        // Get notified about layout changes instead of querying the layout all the time
+        let names = NameDetail::GROUP_NAMES | NameDetail::SYMBOLS;
+        let aux = SelectEventsAux::new()
+            .bitcase2(SelectEventsAuxBitcase2 {
+                affect_state: StatePart::GROUP_STATE,
+                state_details: StatePart::GROUP_STATE,
+            })
+            .bitcase6(SelectEventsAuxBitcase6 {
+                affect_names: names,
+                names_details: names,
+            });
+
+        conn.xkb_select_events(ID::USE_CORE_KBD.into(), EventType::from(0u16), EventType::from(0u16),
+                               MapPart::from(0u16), MapPart::from(0u16), &aux)?.check()?;
+
+        debug!("Found xkb extension");
+    }
+
     // Create GCs
     let aux = CreateGCAux::default()
         .function(GX::INVERT)
@@ -171,7 +197,7 @@ pub(crate) fn claim(subtle: &Subtle) -> Result<()> {
     let owner = conn.get_selection_owner(session)?.reply()?.owner;
 
     if NONE != owner {
-        if !subtle.flags.contains(SubtleFlags::REPLACE) {
+        if !subtle.flags.get().contains(SubtleFlags::REPLACE) {
             return Err(anyhow!("Found a running window manager"))
         }
 
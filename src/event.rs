@@ -13,17 +13,25 @@ use anyhow::{Context, Result};
 use std::sync::atomic;
 use std::sync::atomic::Ordering;
 use std::process::{Command, Stdio};
+use std::time::Duration;
 use log::{debug, warn};
+use rustix::event::{poll, PollFd, PollFlags, Timespec};
+use rustix::io::Errno;
 use stdext::function_name;
 use x11rb::connection::Connection;
 use x11rb::CURRENT_TIME;
-use x11rb::protocol::xproto::{ButtonPressEvent, ClientMessageEvent, ConfigureNotifyEvent, ConfigureRequestEvent, ConfigureWindowAux, ConnectionExt, DestroyNotifyEvent, EnterNotifyEvent, ExposeEvent, FocusInEvent, KeyPressEvent, LeaveNotifyEvent, MapNotifyEvent, MapRequestEvent, Mapping, MappingNotifyEvent, ModMask, PropertyNotifyEvent, SelectionClearEvent, UnmapNotifyEvent, Window};
+use x11rb::protocol::xproto::{ButtonPressEvent, ClientMessageEvent, ConfigureNotifyEvent, ConfigureRequestEvent, ConfigureWindowAux, ConnectionExt, DestroyNotifyEvent, EnterNotifyEvent, ExposeEvent, FocusInEvent, KeyPressEvent, LeaveNotifyEvent, MapNotifyEvent, MapRequestEvent, Mapping, MappingNotifyEvent, ModMask, MotionNotifyEvent, PropertyNotifyEvent, SelectionClearEvent, SelectionNotifyEvent, SelectionRequestEvent, UnmapNotifyEvent, Window};
 use x11rb::protocol::Event;
+use x11rb::rust_connection::RustConnection;
 use crate::subtle::{SubtleFlags, Subtle};
 use crate::client::{Client, ClientFlags, DragMode, RestackOrder};
-use crate::{client, display, ewmh, grab, panel, screen, tray};
+use crate::{client, display, ewmh, grab, keyboard, panel, screen, selection, tooltip, tray};
+#[cfg(feature = "plugins")]
+use crate::plugin;
+#[cfg(feature = "plugins")]
+use crate::plugin::PluginEvents;
 use crate::ewmh::WMState;
-use crate::grab::{DirectionOrder, GrabAction, GrabFlags};
+use crate::grab::{CycleOrder, DirectionOrder, GrabAction, GrabFlags};
 use crate::panel::PanelAction;
 use crate::tray::{Tray, TrayFlags, XEmbed, XEmbedFocus};
 
@@ -39,6 +47,8 @@ use crate::tray::{Tray, TrayFlags, XEmbed, XEmbedFocus};
 /// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
 fn handle_button_press(subtle: &Subtle, event: ButtonPressEvent) -> Result<()> {
     if let Some((_, screen)) = subtle.find_screen_by_panel_win(event.event) {
+        tooltip::leave(subtle, None)?;
+
         screen.handle_action(subtle, &PanelAction::MouseDown(event.event_x, event.event_y, event.detail as i8),
             screen.bottom_panel_win == event.event)?;
 
@@ -129,7 +139,7 @@ fn handle_configure_request(subtle: &Subtle, event: ConfigureRequestEvent) -> Re
     if let Some(client) = subtle.find_client_mut(event.window) {
         // Check flags if the request is important
         if !client.flags.contains(ClientFlags::MODE_FULL)
-            && subtle.flags.contains(SubtleFlags::RESIZE)
+            && subtle.flags.get().contains(SubtleFlags::RESIZE)
             || client.flags.contains(ClientFlags::MODE_FLOAT | ClientFlags::MODE_RESIZE)
         {
             let _maybe_screen = subtle.screens.get(client.screen_idx as usize);
@@ -284,6 +294,9 @@ fn handle_destroy_notify(subtle: &Subtle, event: DestroyNotifyEvent) -> Result<(
 
         subtle.remove_client_by_win(event.window);
 
+        #[cfg(feature = "plugins")]
+        plugin::notify(subtle, PluginEvents::CLIENT_REMOVE);
+
         client::publish(subtle, false)?;
 
         screen::configure(subtle)?;
@@ -327,7 +340,7 @@ fn handle_destroy_notify(subtle: &Subtle, event: DestroyNotifyEvent) -> Result<(
 /// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
 fn handle_enter_notify(subtle: &Subtle, event: EnterNotifyEvent) -> Result<()> {
     if let Some(client) = subtle.find_client(event.event) {
-        if !subtle.flags.intersects(SubtleFlags::CLICK_TO_FOCUS) {
+        if !subtle.flags.get().intersects(SubtleFlags::CLICK_TO_FOCUS) {
             client.focus(subtle, false)?;
         }
     }
@@ -373,7 +386,11 @@ fn handle_leave_notify(subtle: &Subtle, event: LeaveNotifyEvent) -> Result<()> {
 fn handle_expose(subtle: &Subtle, event: ExposeEvent) -> Result<()> {
     // Render only once
     if 0 == event.count {
-        panel::render(subtle)?;
+        if subtle.tooltip.borrow().win == event.window {
+            tooltip::render(subtle)?;
+        } else {
+            panel::render(subtle)?;
+        }
     }
 
     debug!("{}: win={}, count={}", function_name!(), event.window, event.count);
@@ -381,6 +398,27 @@ fn handle_expose(subtle: &Subtle, event: ExposeEvent) -> Result<()> {
     Ok(())
 }
 
+/// Handle motion notify events
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+/// * `event` - Event to handle
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+fn handle_motion_notify(subtle: &Subtle, event: MotionNotifyEvent) -> Result<()> {
+    if let Some((_, screen)) = subtle.find_screen_by_panel_win(event.event) {
+        screen.handle_action(subtle, &PanelAction::MouseOver(event.event_x, event.event_y),
+                             screen.bottom_panel_win == event.event)?;
+    }
+
+    debug!("{}: win={}, x={}, y={}", function_name!(), event.event, event.event_x, event.event_y);
+
+    Ok(())
+}
+
 /// Handle focus in events
 ///
 /// # Arguments
@@ -407,6 +445,9 @@ fn handle_focus_in(subtle: &Subtle, event: FocusInEvent) -> Result<()> {
             *focus_win = event.event;
         }
 
+        #[cfg(feature = "plugins")]
+        plugin::notify(subtle, PluginEvents::FOCUS_CHANGE);
+
         // Update screen
         panel::update(subtle)?;
         panel::render(subtle)?;
@@ -417,174 +458,253 @@ fn handle_focus_in(subtle: &Subtle, event: FocusInEvent) -> Result<()> {
     Ok(())
 }
 
-/// Handle key press events
+/// Handle grab actions of keybindings and other sources like plugins
 ///
 /// # Arguments
 ///
 /// * `subtle` - Global state object
-/// * `event` - Event to handle
+/// * `flag` - Grab flag without key or mouse flags
+/// * `action` - Action of the grab
+/// * `pointer_x` - X position used to find the screen
+/// * `pointer_y` - Y position used to find the screen
 ///
 /// # Returns
 ///
 /// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
-fn handle_key_press(subtle: &Subtle, event: KeyPressEvent) -> Result<()> {
-    // Limit mod mask to relevant ones
-    let relevant_modifiers = ModMask::from(event.state.bits()
-        & (ModMask::SHIFT | ModMask::CONTROL | ModMask::M1 | ModMask::M4));
+pub(crate) fn handle_grab(subtle: &Subtle, flag: GrabFlags, action: &GrabAction,
+                          pointer_x: i16, pointer_y: i16) -> Result<()>
+{
+    match flag {
+        GrabFlags::VIEW_SWITCH | GrabFlags::VIEW_SELECT => {
+            if let &GrabAction::Index(idx) = action {
+                if let Some(view) = subtle.views.get(idx as usize - 1) {
+                    let mut screen_idx: isize = -1;
+
+                    // Find screen: Prefer screen of current window
+                    if subtle.flags.get().intersects(SubtleFlags::SKIP_POINTER_WARP)
+                        && let Some(focus_client) = subtle.find_focus_client()
+                        && focus_client.is_visible(subtle)
+                    {
+                        screen_idx = focus_client.screen_idx;
+                    } else if let Some((maybe_screen_id, _)) = subtle.find_screen_by_xy(
+                        pointer_x, pointer_y)
+                    {
+                        screen_idx = maybe_screen_id as isize;
+                    }
 
-    if let Some(grab) = subtle.find_grab(event.detail, relevant_modifiers) {
-        let flag = grab.flags.difference(GrabFlags::IS_KEY | GrabFlags::IS_MOUSE);
+                    view.focus(subtle, screen_idx as usize,
+                               GrabFlags::VIEW_SWITCH == flag, true)?;
 
-        match flag {
-            GrabFlags::VIEW_SWITCH | GrabFlags::VIEW_SELECT => {
-                if let GrabAction::Index(idx) = grab.action {
-                    if let Some(view) = subtle.views.get(idx as usize - 1) {
-                        let mut screen_idx: isize = -1;
-
-                        // Find screen: Prefer screen of current window
-                        if subtle.flags.intersects(SubtleFlags::SKIP_POINTER_WARP)
-                            && let Some(focus_client) = subtle.find_focus_client()
-                            && focus_client.is_visible(subtle)
-                        {
-                            screen_idx = focus_client.screen_idx;
-                        } else if let Some((maybe_screen_id, _)) = subtle.find_screen_by_xy(
-                            event.event_x, event.event_y)
-                        {
-                            screen_idx = maybe_screen_id as isize;
-                        }
+                    // Finally configure and render
+                    screen::configure(subtle)?;
+                    panel::render(subtle)?;
+                }
+            }
+        },
 
-                        view.focus(subtle, screen_idx as usize,
-                                   GrabFlags::VIEW_SWITCH == flag, true)?;
+        GrabFlags::WINDOW_MODE => {
+            if let Some(mut focus_client) = subtle.find_focus_client_mut() {
+                if let &GrabAction::Index(bits) = action {
+                    let mut mode_flags = ClientFlags::from_bits(bits)
+                        .context("Unknown client flags")?;
 
-                        // Finally configure and render
+                    focus_client.toggle(subtle, &mut mode_flags, true)?;
+
+                    // Update screen and focus
+                    if focus_client.is_visible(subtle) || ClientFlags::MODE_STICK == mode_flags {
+                        // Store values and drop reference
+                        let is_visible = focus_client.is_visible(subtle);
+                        let screen_idx = focus_client.screen_idx;
+
+                        drop(focus_client);
+
+                        // Find next and focus
+                        if !is_visible {
+                            if let Some(next_client) = subtle.find_next_client(screen_idx, false) {
+                                next_client.focus(subtle, true)?;
+                            }
+                        }
+
+                        // Finally configure, update and render
                         screen::configure(subtle)?;
+                        panel::update(subtle)?;
                         panel::render(subtle)?;
                     }
                 }
-            },
+            }
+        },
+
+        GrabFlags::WINDOW_RESTACK => {
+            if let Some(mut focus_client) = subtle.find_focus_client_mut() {
+                if let &GrabAction::Index(order) = action {
+                    focus_client.restack(RestackOrder::from_repr(order as u8)
+                        .context("Unknown order")?);
 
-            GrabFlags::WINDOW_MODE => {
-                if let Some(mut focus_client) = subtle.find_focus_client_mut() {
-                    if let GrabAction::Index(bits) = grab.action {
-                        let mut mode_flags = ClientFlags::from_bits(bits)
-                            .context("Unknown client flags")?;
+                    drop(focus_client);
 
+                    subtle.restack_windows()?;
+                }
+            }
+        },
+
+        GrabFlags::WINDOW_GRAVITY => {
+            if let Some(mut focus_client) = subtle.find_focus_client_mut() {
+                if let GrabAction::List(gravity_ids) = action {
+                    // Remove float and fullscreen mode
+                    if focus_client.flags.intersects(ClientFlags::MODE_FLOAT | ClientFlags::MODE_FULL) {
+                        let mut mode_flags = focus_client.flags & (ClientFlags::MODE_FLOAT | ClientFlags::MODE_FULL);
                         focus_client.toggle(subtle, &mut mode_flags, true)?;
 
-                        // Update screen and focus
-                        if focus_client.is_visible(subtle) || ClientFlags::MODE_STICK == mode_flags {
-                            // Store values and drop reference
-                            let is_visible = focus_client.is_visible(subtle);
-                            let screen_idx = focus_client.screen_idx;
+                        focus_client.gravity_idx = -1; // Reset
+                    }
 
-                            drop(focus_client);
+                    // Find next gravity or fallback to first
+                    let mut new_gravity_id = *gravity_ids.first().context("No gravity ID")?;
 
-                            // Find next and focus
-                            if !is_visible {
-                                if let Some(next_client) = subtle.find_next_client(screen_idx, false) {
-                                    next_client.focus(subtle, true)?;
-                                }
+                    for (idx, gravity_id) in gravity_ids.iter().enumerate() {
+                        if focus_client.gravity_idx == *gravity_id as isize {
+                            if idx < gravity_ids.len() {
+                                new_gravity_id = idx + 1;
                             }
 
-                            // Finally configure, update and render
-                            screen::configure(subtle)?;
-                            panel::update(subtle)?;
-                            panel::render(subtle)?;
+                            break;
                         }
                     }
-                }
-            },
 
-            GrabFlags::WINDOW_RESTACK => {
-                if let Some(mut focus_client) = subtle.find_focus_client_mut() {
-                    if let GrabAction::Index(order) = grab.action {
-                        focus_client.restack(RestackOrder::from_repr(order as u8)
-                            .context("Unknown order")?);
+                    // Finally update client
+                    let screen_id = focus_client.screen_idx;
 
-                        drop(focus_client);
+                    focus_client.arrange(subtle, new_gravity_id as isize, screen_id)?;
+                    focus_client.restack(RestackOrder::Up);
 
-                        subtle.restack_windows()?;
+                    if !subtle.flags.get().intersects(SubtleFlags::SKIP_POINTER_WARP) {
+                        focus_client.warp_pointer(subtle)?;
                     }
-                }
-            },
 
-            GrabFlags::WINDOW_GRAVITY => {
-                if let Some(mut focus_client) = subtle.find_focus_client_mut() {
-                    if let GrabAction::List(gravity_ids) = &grab.action {
-                        // Remove float and fullscreen mode
-                        if focus_client.flags.intersects(ClientFlags::MODE_FLOAT | ClientFlags::MODE_FULL) {
-                            let mut mode_flags = focus_client.flags & (ClientFlags::MODE_FLOAT | ClientFlags::MODE_FULL);
-                            focus_client.toggle(subtle, &mut mode_flags, true)?;
+                    drop(focus_client);
 
-                            focus_client.gravity_idx = -1; // Reset
-                        }
+                    subtle.restack_windows()?;
+                    screen::configure(subtle)?;
+                    panel::update(subtle)?;
+                }
+            }
+        },
 
-                        // Find next gravity or fallback to first
-                        let mut new_gravity_id = *gravity_ids.first().context("No gravity ID")?;
+        GrabFlags::WINDOW_KILL => {
+            if let Some(focus_client) = subtle.find_focus_client_mut() {
+                let screen_idx = focus_client.screen_idx;
 
-                        for (idx, gravity_id) in gravity_ids.iter().enumerate() {
-                            if focus_client.gravity_idx == *gravity_id as isize {
-                                if idx < gravity_ids.len() {
-                                    new_gravity_id = idx + 1;
-                                }
+                focus_client.close(subtle)?;
 
-                                break;
-                            }
-                        }
+                screen::configure(subtle)?;
+                panel::update(subtle)?;
+                panel::render(subtle)?;
 
-                        // Finally update client
-                        let screen_id = focus_client.screen_idx;
+                // Update focus if necessary
+                if let Some(next_client) = subtle.find_next_client(screen_idx, false) {
+                    next_client.focus(subtle, true)?;
+                }
+            }
+        },
+
+        GrabFlags::WINDOW_CYCLE => {
+            if let &GrabAction::Index(order) = action {
+                let order = CycleOrder::from_repr(order as u8).context("Unknown order")?;
+
+                // Collect visible clients of the current screen
+                let focus_win = subtle.find_focus_win();
+                let screen_idx = subtle.find_focus_client()
+                    .map_or(0, |focus_client| focus_client.screen_idx);
+
+                let wins: Vec<Window> = subtle.clients.borrow().iter()
+                    .filter(|client| client.screen_idx == screen_idx && client.is_alive()
+                        && client.is_visible(subtle) && !client.flags.intersects(ClientFlags::TYPE_DESKTOP))
+                    .map(|client| client.win)
+                    .collect();
+
+                if !wins.is_empty() {
+                    let next_idx = match (wins.iter().position(|win| *win == focus_win), order) {
+                        (Some(idx), CycleOrder::Next) => (idx + 1) % wins.len(),
+                        (Some(idx), CycleOrder::Prev) => (idx + wins.len() - 1) % wins.len(),
+                        (None, _) => 0,
+                    };
+
+                    if let Some(next_client) = subtle.find_client(wins[next_idx]) {
+                        next_client.focus(subtle, !subtle.flags.get().intersects(SubtleFlags::SKIP_POINTER_WARP))?;
+                    }
+                }
+            }
+        },
 
-                        focus_client.arrange(subtle, new_gravity_id as isize, screen_id)?;
-                        focus_client.restack(RestackOrder::Up);
+        GrabFlags::WINDOW_FOCUS => {
+            if let &GrabAction::Index(win) = action
+                && let Some(client) = subtle.find_client(win)
+                && client.is_alive() && client.is_visible(subtle)
+            {
+                client.focus(subtle, !subtle.flags.get().intersects(SubtleFlags::SKIP_POINTER_WARP))?;
+            }
+        },
 
-                        if !subtle.flags.intersects(SubtleFlags::SKIP_POINTER_WARP) {
-                            focus_client.warp_pointer(subtle)?;
-                        }
+        GrabFlags::KEYBOARD_LAYOUT => {
+            if let &GrabAction::Index(idx) = action {
+                keyboard::set_layout(subtle, idx as u8)?;
+            }
+        },
 
-                        drop(focus_client);
+        GrabFlags::SUBTLE_RESTART => {
+            subtle.flags.set(subtle.flags.get() | SubtleFlags::RESTART);
+            subtle.shutdown.store(true, Ordering::Relaxed);
+        },
 
-                        subtle.restack_windows()?;
-                        screen::configure(subtle)?;
-                        panel::update(subtle)?;
-                    }
-                }
-            },
+        GrabFlags::SUBTLE_QUIT => {
+            subtle.shutdown.store(true, Ordering::Relaxed);
+        },
 
-            GrabFlags::WINDOW_KILL => {
-                if let Some(focus_client) = subtle.find_focus_client_mut() {
-                    let screen_idx = focus_client.screen_idx;
+        #[cfg(feature = "plugins")]
+        GrabFlags::PLUGIN_RELOAD => {
+            if let GrabAction::Command(name) = action {
+                plugin::reload(subtle, name)?;
+            }
+        },
 
-                    focus_client.close(subtle)?;
+        GrabFlags::COMMAND => {
+            if let GrabAction::Command(cmd) = action {
+                debug!("{}: command={}", function_name!(), cmd);
 
-                    screen::configure(subtle)?;
-                    panel::update(subtle)?;
-                    panel::render(subtle)?;
+                Command::new(cmd)
+                    .stdout(Stdio::null())
+                    .stderr(Stdio::null())
+                    .spawn()?;
+            }
+        }
 
-                    // Update focus if necessary
-                    if let Some(next_client) = subtle.find_next_client(screen_idx, false) {
-                        next_client.focus(subtle, true)?;
-                    }
-                }
-            },
+        _ => {},
+    }
 
-            GrabFlags::SUBTLE_QUIT => {
-                subtle.shutdown.store(true, Ordering::Relaxed);
-            },
+    debug!("{}: flag={:?}, action={:?}", function_name!(), flag, action);
 
-            GrabFlags::COMMAND => {
-                if let GrabAction::Command(cmd) = &grab.action {
-                    debug!("{}: command={}", function_name!(), cmd);
+    Ok(())
+}
 
-                    Command::new(cmd)
-                        .stdout(Stdio::null())
-                        .stderr(Stdio::null())
-                        .spawn()?;
-                }
-            }
+/// Handle key press events
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+/// * `event` - Event to handle
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+fn handle_key_press(subtle: &Subtle, event: KeyPressEvent) -> Result<()> {
+    // Limit mod mask to relevant ones
+    let relevant_modifiers = ModMask::from(event.state.bits()
+        & (ModMask::SHIFT | ModMask::CONTROL | ModMask::M1 | ModMask::M4));
 
-            _ => {},
-        }
+    if let Some(grab) = subtle.find_grab(event.detail, relevant_modifiers) {
+        let flag = grab.flags.difference(GrabFlags::IS_KEY | GrabFlags::IS_MOUSE);
+
+        handle_grab(subtle, flag, &grab.action, event.event_x, event.event_y)?;
 
         println!("grab={:?}", grab);
     }
@@ -775,6 +895,9 @@ fn handle_map_request(subtle: &Subtle, event: MapRequestEvent) -> Result<()> {
     } else if let Ok(client) = Client::new(subtle, event.window) {
         subtle.add_client(client);
 
+        #[cfg(feature = "plugins")]
+        plugin::notify(subtle, PluginEvents::CLIENT_ADD);
+
         screen::configure(subtle)?;
         panel::update(subtle)?;
         panel::render(subtle)?;
@@ -812,6 +935,9 @@ fn handle_unmap_notify(subtle: &Subtle, event: UnmapNotifyEvent) -> Result<()> {
 
             subtle.remove_client_by_win(event.window);
 
+            #[cfg(feature = "plugins")]
+            plugin::notify(subtle, PluginEvents::CLIENT_REMOVE);
+
             client::publish(subtle, false)?;
 
             screen::configure(subtle)?;
@@ -856,7 +982,11 @@ fn handle_unmap_notify(subtle: &Subtle, event: UnmapNotifyEvent) -> Result<()> {
 ///
 /// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
 fn handle_selection_clear(subtle: &Subtle, event: SelectionClearEvent) -> Result<()> {
-    if event.owner == subtle.tray_win {
+    let selection_win = subtle.selection.borrow().win;
+
+    if event.owner == selection_win {
+        selection::handle_clear(subtle, &event);
+    } else if event.owner == subtle.tray_win {
         unimplemented!()
     } else if event.owner == subtle.support_win {
         warn!("Leaving the field");
@@ -870,6 +1000,135 @@ fn handle_selection_clear(subtle: &Subtle, event: SelectionClearEvent) -> Result
     Ok(())
 }
 
+/// Handle selection notify events
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+/// * `event` - Event to handle
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+fn handle_selection_notify(subtle: &Subtle, event: SelectionNotifyEvent) -> Result<()> {
+    selection::handle_notify(subtle, &event)?;
+
+    debug!("{}: win={}, selection={}, target={}",
+        function_name!(), event.requestor, event.selection, event.target);
+
+    Ok(())
+}
+
+/// Handle selection request events
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+/// * `event` - Event to handle
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+fn handle_selection_request(subtle: &Subtle, event: SelectionRequestEvent) -> Result<()> {
+    // Failed answers only affect the requestor, so just log them
+    if let Err(err) = selection::handle_request(subtle, &event) {
+        warn!("Cannot answer selection request of window ({}): {}", event.requestor, err);
+    }
+
+    debug!("{}: win={}, selection={}, target={}",
+        function_name!(), event.requestor, event.selection, event.target);
+
+    Ok(())
+}
+
+/// Handle xkb state and names notify events
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+#[cfg(feature = "plugins")]
+fn handle_xkb_notify(subtle: &Subtle) -> Result<()> {
+    // Layout or group has changed, so plugins have to query it again
+    plugin::invalidate_keyboard_layout(subtle);
+
+    debug!("{}", function_name!());
+
+    Ok(())
+}
+
+/// Dispatch event to the matching handler
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+/// * `event` - Event to handle
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+fn handle_event(subtle: &Subtle, event: Event) -> Result<()> {
+    match event {
+        Event::ButtonPress(evt) => handle_button_press(subtle, evt)?,
+        Event::ConfigureNotify(evt) => handle_configure_notify(subtle, evt)?,
+        Event::ConfigureRequest(evt) => handle_configure_request(subtle, evt)?,
+        Event::ClientMessage(evt) => handle_client_message(subtle, evt)?,
+        Event::DestroyNotify(evt) => handle_destroy_notify(subtle, evt)?,
+        Event::EnterNotify(evt) => handle_enter_notify(subtle, evt)?,
+        Event::LeaveNotify(evt) => handle_leave_notify(subtle, evt)?,
+        Event::Expose(evt) => handle_expose(subtle, evt)?,
+        Event::FocusIn(evt) => handle_focus_in(subtle, evt)?,
+        Event::KeyPress(evt) => handle_key_press(subtle, evt)?,
+        Event::MapNotify(evt) => handle_map_notify(subtle, evt)?,
+        Event::MappingNotify(evt) => handle_mapping_notify(subtle, evt)?,
+        Event::MotionNotify(evt) => handle_motion_notify(subtle, evt)?,
+        Event::MapRequest(evt) => handle_map_request(subtle, evt)?,
+        Event::PropertyNotify(evt) => handle_property_notify(subtle, evt)?,
+        Event::SelectionClear(evt) => handle_selection_clear(subtle, evt)?,
+        Event::SelectionNotify(evt) => handle_selection_notify(subtle, evt)?,
+        Event::SelectionRequest(evt) => handle_selection_request(subtle, evt)?,
+        Event::UnmapNotify(evt) => handle_unmap_notify(subtle, evt)?,
+        #[cfg(feature = "plugins")]
+        Event::XkbStateNotify(_) | Event::XkbNamesNotify(_) => handle_xkb_notify(subtle)?,
+
+        _ => {
+            if subtle.flags.get().intersects(SubtleFlags::DEBUG) {
+                warn!("Unhandled event: {:?}", event)
+            }
+        },
+    }
+
+    Ok(())
+}
+
+/// Wait until the connection becomes readable or the timeout expires
+///
+/// # Arguments
+///
+/// * `conn` - Connection to wait for
+/// * `timeout` - Optional timeout; wait forever when unset
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+fn wait_for_event(conn: &RustConnection, timeout: Option<Duration>) -> Result<()> {
+    let timeout = timeout.map(|timeout| Timespec {
+        tv_sec: timeout.as_secs() as _,
+        tv_nsec: timeout.subsec_nanos() as _,
+    });
+
+    let mut fds = [PollFd::new(conn.stream(), PollFlags::IN)];
+
+    // Signals interrupt the wait, the caller checks for shutdown afterwards
+    match poll(&mut fds, timeout.as_ref()) {
+        Ok(_) | Err(Errno::INTR) => Ok(()),
+        Err(err) => Err(err.into()),
+    }
+}
+
 /// Run event loop and handle events
 ///
 /// # Arguments
@@ -885,11 +1144,13 @@ pub(crate) fn event_loop(subtle: &Subtle) -> Result<()> {
 
     // Update screen and panels
     screen::configure(subtle)?;
+    #[cfg(feature = "plugins")]
+    plugin::update(subtle)?;
     panel::update(subtle)?;
     panel::render(subtle)?;
 
     // Set tray selection
-    if subtle.flags.intersects(SubtleFlags::TRAY) {
+    if subtle.flags.get().intersects(SubtleFlags::TRAY) {
         display::select_tray(subtle)?;
     }
 
@@ -907,36 +1168,35 @@ pub(crate) fn event_loop(subtle: &Subtle) -> Result<()> {
     while !subtle.shutdown.load(atomic::Ordering::SeqCst) {
         conn.flush()?;
 
-        if let Ok(event) = conn.wait_for_event() {
-            match event {
-                Event::ButtonPress(evt) => handle_button_press(subtle, evt)?,
-                Event::ConfigureNotify(evt) => handle_configure_notify(subtle, evt)?,
-                Event::ConfigureRequest(evt) => handle_configure_request(subtle, evt)?,
-                Event::ClientMessage(evt) => handle_client_message(subtle, evt)?,
-                Event::DestroyNotify(evt) => handle_destroy_notify(subtle, evt)?,
-                Event::EnterNotify(evt) => handle_enter_notify(subtle, evt)?,
-                Event::LeaveNotify(evt) => handle_leave_notify(subtle, evt)?,
-                Event::Expose(evt) => handle_expose(subtle, evt)?,
-                Event::FocusIn(evt) => handle_focus_in(subtle, evt)?,
-                Event::KeyPress(evt) => handle_key_press(subtle, evt)?,
-                Event::MapNotify(evt) => handle_map_notify(subtle, evt)?,
-                Event::MappingNotify(evt) => handle_mapping_notify(subtle, evt)?,
-                Event::MapRequest(evt) => handle_map_request(subtle, evt)?,
-                Event::PropertyNotify(evt) => handle_property_notify(subtle, evt)?,
-                Event::SelectionClear(evt) => handle_selection_clear(subtle, evt)?,
-                Event::UnmapNotify(evt) => handle_unmap_notify(subtle, evt)?,
-
-                _ => {
-                    if subtle.flags.intersects(SubtleFlags::DEBUG) {
-                        warn!("Unhandled event: {:?}", event)
-                    }
-                },
-            }
+        // Run commands queued by plugins
+        #[cfg(feature = "plugins")]
+        plugin::dispatch(subtle);
+
+        // Run due plugins and refresh panels
+        #[cfg(feature = "plugins")]
+        if plugin::update(subtle)? {
+            panel::update(subtle)?;
+            panel::render(subtle)?;
+        }
+
+        tooltip::update(subtle)?;
+
+        match conn.poll_for_event()? {
+            Some(event) => handle_event(subtle, event)?,
+            None => {
+                #[cfg(feature = "plugins")]
+                let timeout = [plugin::next_timeout(subtle), tooltip::next_timeout(subtle)]
+                    .into_iter().flatten().min();
+                #[cfg(not(feature = "plugins"))]
+                let timeout = tooltip::next_timeout(subtle);
+
+                wait_for_event(conn, timeout)?;
+            },
         }
     }
 
     // Drop tray selection
-    if subtle.flags.intersects(SubtleFlags::TRAY) {
+    if subtle.flags.get().intersects(SubtleFlags::TRAY) {
         display::deselect_tray(subtle)?;
     }
 
@@ -79,6 +79,9 @@ x11rb::atom_manager! {
         // Misc
         UTF8_STRING, MANAGER, _MOTIF_WM_HINTS,
 
+        // Selections
+        CLIPBOARD, TARGETS, INCR,
+
         // XEmbed
         _XEMBED, _XEMBED_INFO,
 
@@ -114,7 +117,7 @@ pub(crate) fn init(_config: &Config, subtle: &mut Subtle) -> Result<()> {
 
     subtle.atoms.set(atoms).unwrap();
 
-    subtle.flags.insert(SubtleFlags::EWMH);
+    subtle.flags.get_mut().insert(SubtleFlags::EWMH);
 
     debug!("{}", function_name!());
 
@@ -158,7 +161,7 @@ pub(crate) fn send_message(subtle: &Subtle, win: Window, message_type: Atom, dat
 pub(crate) fn finish(subtle: &Subtle) -> Result<()> {
 
     // Delete root properties on real shutdown
-    if subtle.flags.contains(SubtleFlags::EWMH) {
+    if subtle.flags.get().contains(SubtleFlags::EWMH) {
         let conn = subtle.conn.get().unwrap();
         let atoms = subtle.atoms.get().unwrap();
 
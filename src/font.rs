@@ -9,13 +9,18 @@
 //! See the file LICENSE for details.
 //!
 
+use std::collections::HashSet;
 use std::fmt;
 use anyhow::Result;
 use log::debug;
 use stdext::function_name;
 use x11rb::connection::Connection;
-use x11rb::protocol::xproto::{Char2b, ConnectionExt};
+use x11rb::protocol::xproto::{Char2b, CharInfo, ConnectionExt};
 use x11rb::rust_connection::RustConnection;
+use crate::text;
+
+/// Replacement for glyphs outside of the basic multilingual plane
+const REPLACEMENT_CHAR: u16 = 0xfffd;
 
 #[derive(Default, Debug, Clone)]
 pub(crate) struct Font {
@@ -25,6 +30,16 @@ pub(crate) struct Font {
     pub(crate) y: u16,
     /// Height of the font
     pub(crate) height: u16,
+    /// Width of a single display column
+    pub(crate) column_width: u16,
+    /// Range of the first byte of the glyphs
+    pub(crate) byte1_range: (u8, u8),
+    /// Range of the second byte of the glyphs
+    pub(crate) byte2_range: (u16, u16),
+    /// Glyphs inside of the ranges the font doesn't have
+    pub(crate) missing_glyphs: HashSet<u16>,
+    /// Width of the glyph that is drawn for missing glyphs
+    pub(crate) default_width: u16,
 }
 
 impl Font {
@@ -54,6 +69,30 @@ impl Font {
 
             font.height = (reply.font_ascent + reply.font_descent + 2) as u16;
             font.y = (font.height - 2 + reply.font_ascent as u16) / 2;
+
+            // Use width of a digit as column width, since digits are usually fixed
+            font.column_width = conn.query_text_extents(font.fontable, &encode_text("0"))?
+                .reply()?.overall_width as u16;
+
+            // Collect missing glyphs once, so measuring needs no further requests
+            font.byte1_range = (reply.min_byte1, reply.max_byte1);
+            font.byte2_range = (reply.min_char_or_byte2, reply.max_char_or_byte2);
+
+            let columns = (font.byte2_range.1.saturating_sub(font.byte2_range.0) + 1) as usize;
+
+            for (idx, info) in reply.char_infos.iter().enumerate() {
+                if is_empty_glyph(info) {
+                    let byte1 = font.byte1_range.0 as u16 + (idx / columns) as u16;
+                    let byte2 = font.byte2_range.0 + (idx % columns) as u16;
+
+                    font.missing_glyphs.insert(byte1 << 8 | byte2);
+                }
+            }
+
+            if font.has_glyph(reply.default_char) {
+                font.default_width = reply.char_infos.get(font.glyph_index(reply.default_char))
+                    .unwrap_or(&reply.max_bounds).character_width.max(0) as u16;
+            }
         }
 
         debug!("{}: {}", function_name!(), font);
@@ -73,21 +112,52 @@ impl Font {
     ///
     /// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
     pub(crate) fn calc_text_width(&self, conn: &RustConnection, text: &String, center: bool) -> Result<(u16, u16, u16)> {
-        let text_char2b: Vec<Char2b> = text.as_bytes()
-            .to_vec()
-            .iter()
-            .map(|b| Char2b {
-                byte1: 0,
-                byte2: *b,
-            }).collect();
-
-        let reply = conn.query_text_extents(self.fontable, &text_char2b)?.reply()?;
+        let reply = conn.query_text_extents(self.fontable, &encode_text(text))?.reply()?;
 
-        Ok(((if center {
+        let width = if center {
             reply.overall_width - (reply.overall_left - reply.overall_right).abs()
         } else {
             reply.overall_width
-        }) as u16, reply.overall_left as u16, reply.overall_right as u16))
+        };
+
+        // Reserve display columns only for glyphs missing in the font like CJK in latin1 fonts
+        let missing_width = calc_missing_width(text, self.column_width, self.default_width,
+                                               |code| self.has_glyph(code));
+
+        Ok(((width as u16).saturating_add(missing_width), reply.overall_left as u16, reply.overall_right as u16))
+    }
+
+    /// Check whether the font has a glyph
+    ///
+    /// # Arguments
+    ///
+    /// * `code` - Code of the glyph
+    ///
+    /// # Returns
+    ///
+    /// Either [`true`] when the font has the glyph or otherwise [`false`]
+    pub(crate) fn has_glyph(&self, code: u16) -> bool {
+        let (byte1, byte2) = ((code >> 8) as u8, code & 0xff);
+
+        (self.byte1_range.0..=self.byte1_range.1).contains(&byte1)
+            && (self.byte2_range.0..=self.byte2_range.1).contains(&byte2)
+            && !self.missing_glyphs.contains(&code)
+    }
+
+    /// Get the index of a glyph in the char infos of the font
+    ///
+    /// # Arguments
+    ///
+    /// * `code` - Code of the glyph
+    ///
+    /// # Returns
+    ///
+    /// The index of the glyph
+    fn glyph_index(&self, code: u16) -> usize {
+        let columns = (self.byte2_range.1.saturating_sub(self.byte2_range.0) + 1) as usize;
+
+        ((code >> 8) as u8).saturating_sub(self.byte1_range.0) as usize * columns
+            + (code & 0xff).saturating_sub(self.byte2_range.0) as usize
     }
 
     /// Close font
@@ -108,6 +178,83 @@ impl Font {
     }
 }
 
+/// Check whether the char info describes a glyph that doesn't exist
+///
+/// # Arguments
+///
+/// * `info` - Char info of the glyph
+///
+/// # Returns
+///
+/// Either [`true`] when all metrics are zero or otherwise [`false`]
+fn is_empty_glyph(info: &CharInfo) -> bool {
+    0 == info.left_side_bearing && 0 == info.right_side_bearing && 0 == info.character_width
+        && 0 == info.ascent && 0 == info.descent
+}
+
+/// Get the glyph code of the first char of a cluster
+///
+/// # Arguments
+///
+/// * `ch` - Char to encode
+///
+/// # Returns
+///
+/// The glyph code
+fn glyph_code(ch: char) -> u16 {
+    // Core fonts are limited to the basic multilingual plane
+    u16::try_from(u32::from(ch)).unwrap_or(REPLACEMENT_CHAR)
+}
+
+/// Calculate the extra width the display columns of clusters missing in the font need
+///
+/// # Arguments
+///
+/// * `text` - Text to calculate
+/// * `column_width` - Width of a single display column
+/// * `default_width` - Width of the glyph the server draws instead
+/// * `has_glyph` - Check whether the font has a glyph
+///
+/// # Returns
+///
+/// The extra width in pixel
+pub(crate) fn calc_missing_width<F>(text: &str, column_width: u16, default_width: u16, has_glyph: F) -> u16
+    where F: Fn(u16) -> bool
+{
+    let width: usize = text::clusters(text)
+        .filter(|(_, columns)| 0 < *columns)
+        .filter_map(|(cluster, columns)| cluster.chars().next().map(|ch| (glyph_code(ch), columns)))
+        .filter(|(code, _)| !has_glyph(*code))
+        .map(|(_, columns)| (columns * column_width as usize).saturating_sub(default_width as usize))
+        .sum();
+
+    width.min(u16::MAX as usize) as u16
+}
+
+/// Encode text as glyphs with one glyph per grapheme cluster
+///
+/// # Arguments
+///
+/// * `text` - Text to encode
+///
+/// # Returns
+///
+/// A [`Vec`] of [`Char2b`] to use with 16-bit text requests
+pub(crate) fn encode_text(text: &str) -> Vec<Char2b> {
+    text::clusters(text)
+        .filter(|(_, columns)| 0 < *columns)
+        .filter_map(|(cluster, _)| cluster.chars().next())
+        .map(|ch| {
+            let code = glyph_code(ch);
+
+            Char2b {
+                byte1: (code >> 8) as u8,
+                byte2: (code & 0xff) as u8,
+            }
+        })
+        .collect()
+}
+
 impl fmt::Display for Font {
     fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
         write!(f, "(y={}, height={})", self.y, self.height)
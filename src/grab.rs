@@ -15,6 +15,7 @@ use bitflags::bitflags;
 use anyhow::{Context, Result, bail};
 use log::debug;
 use stdext::function_name;
+use strum_macros::FromRepr;
 use x11rb::connection::Connection;
 use x11rb::NONE;
 use x11rb::protocol::xproto::{ButtonIndex, ConnectionExt, EventMask, GrabMode, Keycode, Keysym, ModMask, Window};
@@ -61,6 +62,14 @@ bitflags! {
         const WINDOW_GRAVITY = 1 << 15;
         /// Kill window
         const WINDOW_KILL = 1 << 16;
+        /// Cycle window focus
+        const WINDOW_CYCLE = 1 << 17;
+        /// Focus given window
+        const WINDOW_FOCUS = 1 << 18;
+        /// Switch keyboard layout
+        const KEYBOARD_LAYOUT = 1 << 19;
+        /// Reload plugin
+        const PLUGIN_RELOAD = 1 << 20;
     }
 }
 
@@ -74,7 +83,14 @@ pub(crate) enum DirectionOrder {
     Left = 4,
 }
 
-#[derive(Default, Debug)]
+#[repr(u8)]
+#[derive(Debug, Copy, Clone, PartialEq, FromRepr)]
+pub(crate) enum CycleOrder {
+    Next = 0,
+    Prev = 1,
+}
+
+#[derive(Default, Debug, Clone, PartialEq)]
 pub(crate) enum GrabAction {
     #[default]
     None,
@@ -155,12 +171,19 @@ pub(crate) fn parse_name(name: &str) -> Result<(GrabFlags, GrabAction)> {
         "subtle_restart" => (GrabFlags::SUBTLE_RESTART, GrabAction::None),
         "subtle_quit" => (GrabFlags::SUBTLE_QUIT, GrabAction::None),
 
+        // Reload all plugins
+        "reload_sublet" => (GrabFlags::PLUGIN_RELOAD, GrabAction::Command(String::new())),
+
         "window_toggle" => (GrabFlags::WINDOW_MODE, GrabAction::None),
         "window_stack" => (GrabFlags::WINDOW_RESTACK, GrabAction::None),
         "window_select" => (GrabFlags::WINDOW_SELECT, GrabAction::None),
         "window_gravity" => (GrabFlags::WINDOW_GRAVITY, GrabAction::None),
         "window_kill" => (GrabFlags::WINDOW_KILL, GrabAction::None),
 
+        // Window cycle
+        "window_next" => (GrabFlags::WINDOW_CYCLE, GrabAction::Index(CycleOrder::Next as u32)),
+        "window_prev" => (GrabFlags::WINDOW_CYCLE, GrabAction::Index(CycleOrder::Prev as u32)),
+
         // Window modes
         "window_float" => (GrabFlags::WINDOW_MODE, GrabAction::Index(ClientFlags::MODE_FLOAT.bits())),
         "window_full" => (GrabFlags::WINDOW_MODE, GrabAction::Index(ClientFlags::MODE_FULL.bits())),
@@ -191,6 +214,8 @@ pub(crate) fn parse_name(name: &str) -> Result<(GrabFlags, GrabAction)> {
                 (GrabFlags::VIEW_SWITCH, GrabAction::Index(stripped.parse()?))
             } else if let Some(stripped) =name.strip_prefix("screen_jump") {
                 (GrabFlags::SCREEN_JUMP, GrabAction::Index(stripped.parse()?))
+            } else if let Some(stripped) = name.strip_prefix("reload_sublet_") {
+                (GrabFlags::PLUGIN_RELOAD, GrabAction::Command(stripped.to_string()))
             } else {
                 (GrabFlags::COMMAND, GrabAction::Command(name.to_string()))
             }
@@ -340,7 +365,7 @@ pub(crate) fn set(subtle: &Subtle, win: Window, grab_mask: GrabFlags) -> Result<
     let default_screen = &conn.setup().roots[subtle.screen_num];
 
     // Unbind click-to-focus grab
-    if subtle.flags.intersects(SubtleFlags::CLICK_TO_FOCUS) && default_screen.root != win {
+    if subtle.flags.get().intersects(SubtleFlags::CLICK_TO_FOCUS) && default_screen.root != win {
         conn.ungrab_button(ButtonIndex::ANY, win, ModMask::ANY)?.check()?;
     }
 
@@ -395,7 +420,7 @@ pub(crate) fn unset(subtle: &Subtle, win: Window) -> Result<()> {
     conn.ungrab_button(ButtonIndex::ANY, win, ModMask::ANY)?.check()?;
 
     // Bind click-to-focus grab
-    if subtle.flags.intersects(SubtleFlags::CLICK_TO_FOCUS) && default_screen.root != win {
+    if subtle.flags.get().intersects(SubtleFlags::CLICK_TO_FOCUS) && default_screen.root != win {
         conn.grab_button(false, win,
                          EventMask::BUTTON_PRESS | EventMask::BUTTON_RELEASE,
                          GrabMode::ASYNC, GrabMode::ASYNC, NONE, NONE,
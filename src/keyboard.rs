@@ -0,0 +1,145 @@
+//!
+//! @package subtle-rs
+//!
+//! @file Keyboard functions
+//! @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+//! @version $Id$
+//!
+//! This program can be distributed under the terms of the GNU GPLv3.
+//! See the file LICENSE for details.
+//!
+
+use std::fmt;
+use anyhow::{anyhow, Context, Result};
+use log::debug;
+use serde::Serialize;
+use stdext::function_name;
+use x11rb::connection::Connection;
+use x11rb::protocol::xkb::{ConnectionExt as xkb_ext, Group, NameDetail, ID};
+use x11rb::protocol::xproto::ConnectionExt;
+use crate::subtle::{Subtle, SubtleFlags};
+
+/// Parts of the symbols name which are options rather than layouts
+const SYMBOLS_OPTIONS: [&str; 16] = [
+    "pc", "inet", "group", "compose", "terminate", "level3", "level5", "ctrl",
+    "altwin", "caps", "capslock", "lv3", "keypad", "kpdl", "nbsp", "eurosign",
+];
+
+#[derive(Default, Debug, Clone, PartialEq, Serialize)]
+pub(crate) struct KeyboardLayout {
+    /// Short name of the current layout (e.g. us)
+    pub(crate) layout: String,
+    /// Variant of the current layout (e.g. nodeadkeys)
+    pub(crate) variant: String,
+    /// Index of the current layout
+    pub(crate) index: usize,
+    /// Names of all available layouts
+    pub(crate) available: Vec<String>,
+}
+
+impl fmt::Display for KeyboardLayout {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "(layout={}, variant={}, index={})", self.layout, self.variant, self.index)
+    }
+}
+
+/// Parse layouts and variants from the symbols name of the keymap
+///
+/// # Arguments
+///
+/// * `symbols` - Symbols name like `pc+us+de(nodeadkeys):2+inet(evdev)`
+///
+/// # Returns
+///
+/// A [`Vec`] of layout and variant in order of the groups
+pub(crate) fn parse_symbols(symbols: &str) -> Vec<(String, String)> {
+    symbols.split('+')
+        .map(|part| part.split_once(':').map_or(part, |(layout, _group)| layout))
+        .filter_map(|part| {
+            let (layout, variant) = match part.split_once('(') {
+                Some((layout, variant)) => (layout, variant.trim_end_matches(')')),
+                None => (part, ""),
+            };
+
+            if layout.is_empty() || SYMBOLS_OPTIONS.contains(&layout) {
+                None
+            } else {
+                Some((layout.to_string(), variant.to_string()))
+            }
+        })
+        .collect()
+}
+
+/// Query the current keyboard layout
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+///
+/// # Returns
+///
+/// A [`Result`] with either [`KeyboardLayout`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn query_layout(subtle: &Subtle) -> Result<KeyboardLayout> {
+    if !subtle.flags.get().intersects(SubtleFlags::XKB) {
+        return Err(anyhow!("Xkb extension not available"));
+    }
+
+    let conn = subtle.conn.get().context("Failed to get connection")?;
+
+    let state = conn.xkb_get_state(ID::USE_CORE_KBD.into())?.reply()?;
+    let names = conn.xkb_get_names(ID::USE_CORE_KBD.into(),
+                                   NameDetail::GROUP_NAMES | NameDetail::SYMBOLS)?.reply()?;
+
+    // Resolve descriptive names of all groups
+    let mut available = Vec::new();
+
+    for atom in names.value_list.groups.unwrap_or_default() {
+        available.push(String::from_utf8_lossy(&conn.get_atom_name(atom)?.reply()?.name).into_owned());
+    }
+
+    let symbols = match names.value_list.symbols_name {
+        Some(atom) => String::from_utf8_lossy(&conn.get_atom_name(atom)?.reply()?.name).into_owned(),
+        None => String::new(),
+    };
+
+    let index = u8::from(state.group) as usize;
+    let (layout, variant) = parse_symbols(&symbols).into_iter().nth(index).unwrap_or_default();
+
+    let layout = KeyboardLayout {
+        layout,
+        variant,
+        index,
+        available,
+    };
+
+    debug!("{}: layout={}", function_name!(), layout);
+
+    Ok(layout)
+}
+
+/// Lock the keyboard to the given layout
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+/// * `index` - Index of the layout
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn set_layout(subtle: &Subtle, index: u8) -> Result<()> {
+    if !subtle.flags.get().intersects(SubtleFlags::XKB) {
+        return Err(anyhow!("Xkb extension not available"));
+    }
+
+    let conn = subtle.conn.get().context("Failed to get connection")?;
+
+    conn.xkb_latch_lock_state(ID::USE_CORE_KBD.into(), 0u8, 0u8, true,
+                              Group::from(index), 0u8, false, 0)?.check()?;
+
+    conn.flush()?;
+
+    debug!("{}: index={}", function_name!(), index);
+
+    Ok(())
+}
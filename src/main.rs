@@ -46,12 +46,22 @@ mod style;
 mod font;
 /// Panel module
 mod panel;
+/// Markup module for panel text
+mod markup;
+/// Helper module for unicode text
+mod text;
 /// Helper module for spacing
 mod spacing;
 /// Icon module
 mod icon;
 /// Tray module
 mod tray;
+/// Tooltip module
+mod tooltip;
+/// Selection module
+mod selection;
+/// Keyboard module
+mod keyboard;
 /// Plugin module
 #[cfg(feature = "plugins")]
 mod plugin;
@@ -59,6 +69,7 @@ mod plugin;
 use std::env;
 use std::env::current_exe;
 use std::sync::Arc;
+use std::sync::atomic::Ordering;
 use anyhow::{anyhow, Context, Result};
 use log::{debug, error, info};
 use crate::config::Config;
@@ -148,6 +159,8 @@ fn configure(config: &Config, subtle: &mut Subtle) -> Result<()> {
     #[cfg(feature = "plugins")]
     plugin::init(config, subtle)?; // Must be before screen init
     screen::init(config, subtle)?;
+    tooltip::init(config, subtle)?;
+    selection::init(config, subtle)?;
     gravity::init(config, subtle)?;
     tag::init(config, subtle)?;
     view::init(config, subtle)?;
@@ -214,11 +227,15 @@ fn main() -> Result<()> {
     }
 
     // Tidy up
+    #[cfg(feature = "plugins")]
+    plugin::finish(&subtle);
     ewmh::finish(&subtle)?;
+    tooltip::finish(&subtle)?;
+    selection::finish(&subtle)?;
     display::finish(&mut subtle)?;
 
     // Restart if necessary
-    if subtle.flags.contains(SubtleFlags::RESTART) {
+    if subtle.flags.get().contains(SubtleFlags::RESTART) {
         info!("Restarting");
 
         // When this actually returns something went wrong
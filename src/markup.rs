@@ -0,0 +1,290 @@
+//!
+//! @package subtle-rs
+//!
+//! @file Markup functions
+//! @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+//! @version $Id$
+//!
+//! This program can be distributed under the terms of the GNU GPLv3.
+//! See the file LICENSE for details.
+//!
+//! Grammar of the panel markup:
+//!
+//! ```text
+//! markup    = { text | entity | tag | bar }
+//! tag       = "<" ( "fg" | "bg" ) "=" color ">" | "</" ( "fg" | "bg" ) ">"
+//! bar       = "<bar" { " " attr } [ "/" ] ">"
+//! attr      = ( "value" | "width" | "fg" | "bg" ) "=" [ '"' ] value [ '"' ]
+//! color     = "#" hex hex hex [ hex hex hex ]
+//! entity    = "&lt;" | "&gt;" | "&amp;" | "&quot;" | "&apos;"
+//! ```
+//!
+//! Unknown tags are stripped, closing tags without opening tag are ignored
+//! and unclosed tags apply until the end of the text.
+//!
+//! Bars are filled by value between 0.0 and 1.0, are width cells wide and
+//! use the current colors unless fg or bg are given.
+//!
+
+use std::fmt;
+use hex_color::HexColor;
+
+/// Default width of bars in cells
+const DEFAULT_BAR_WIDTH: u16 = 10;
+
+/// Maximum width of bars in cells
+const MAX_BAR_WIDTH: u16 = 100;
+
+#[derive(Default, Debug, Copy, Clone, PartialEq)]
+pub(crate) struct Bar {
+    /// Filled fraction between 0.0 and 1.0
+    pub(crate) value: f32,
+    /// Width in cells
+    pub(crate) width: u16,
+}
+
+#[derive(Default, Debug, Clone, PartialEq)]
+pub(crate) struct Span {
+    /// Text of this span
+    pub(crate) text: String,
+    /// Optional foreground color
+    pub(crate) fg: Option<String>,
+    /// Optional background color
+    pub(crate) bg: Option<String>,
+    /// Bar drawn instead of the text
+    pub(crate) bar: Option<Bar>,
+}
+
+impl fmt::Display for Span {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "(text={}, fg={:?}, bg={:?}, bar={:?})", self.text, self.fg, self.bg, self.bar)
+    }
+}
+
+/// Decode a single entity
+///
+/// # Arguments
+///
+/// * `entity` - Name of the entity without ampersand and semicolon
+///
+/// # Returns
+///
+/// Either [`Some`] with the decoded char or otherwise [`None`]
+fn decode_entity(entity: &str) -> Option<char> {
+    match entity {
+        "lt" => Some('<'),
+        "gt" => Some('>'),
+        "amp" => Some('&'),
+        "quot" => Some('"'),
+        "apos" => Some('\''),
+        _ => None,
+    }
+}
+
+/// Check whether the content between brackets looks like a tag
+///
+/// # Arguments
+///
+/// * `tag` - Content between the brackets
+///
+/// # Returns
+///
+/// Either [`true`] if this is a tag or otherwise [`false`]
+fn is_tag(tag: &str) -> bool {
+    let name = tag.strip_prefix('/').unwrap_or(tag);
+
+    name.chars().next().is_some_and(|ch| ch.is_ascii_alphabetic()) && !tag.contains('<')
+}
+
+/// Push text to the spans and merge it with the last span if the colors match
+///
+/// # Arguments
+///
+/// * `spans` - List of spans
+/// * `text` - Text to push
+/// * `fg_stack` - Stack of foreground colors
+/// * `bg_stack` - Stack of background colors
+fn push_text(spans: &mut Vec<Span>, text: &str, fg_stack: &[Option<String>], bg_stack: &[Option<String>]) {
+    if text.is_empty() {
+        return;
+    }
+
+    let fg = fg_stack.iter().rev().find_map(|color| color.clone());
+    let bg = bg_stack.iter().rev().find_map(|color| color.clone());
+
+    match spans.last_mut() {
+        Some(last_span) if last_span.fg == fg && last_span.bg == bg && last_span.bar.is_none() =>
+            last_span.text.push_str(text),
+        _ => spans.push(Span {
+            text: text.to_string(),
+            fg,
+            bg,
+            bar: None,
+        }),
+    }
+}
+
+/// Parse a color value and ignore invalid ones
+///
+/// # Arguments
+///
+/// * `value` - Color value with optional quotes
+///
+/// # Returns
+///
+/// Either [`Some`] with the color or otherwise [`None`]
+fn parse_color(value: &str) -> Option<String> {
+    let value = value.trim().trim_matches('"');
+
+    HexColor::parse(value).ok().map(|_| value.to_string())
+}
+
+/// Parse the attributes of a bar into a span
+///
+/// # Arguments
+///
+/// * `attrs` - Attributes of the bar tag
+/// * `fg_stack` - Stack of foreground colors
+/// * `bg_stack` - Stack of background colors
+///
+/// # Returns
+///
+/// A [`Span`] with the bar and its colors
+fn parse_bar(attrs: &str, fg_stack: &[Option<String>], bg_stack: &[Option<String>]) -> Span {
+    let mut span = Span {
+        fg: fg_stack.iter().rev().find_map(|color| color.clone()),
+        bg: bg_stack.iter().rev().find_map(|color| color.clone()),
+        ..Span::default()
+    };
+
+    let mut bar = Bar {
+        width: DEFAULT_BAR_WIDTH,
+        ..Bar::default()
+    };
+
+    for (name, value) in attrs.split_whitespace().filter_map(|attr| attr.split_once('=')) {
+        let value = value.trim_matches('"');
+
+        // Invalid values keep the defaults and colors the current color
+        match name {
+            "value" => bar.value = value.parse::<f32>().ok()
+                .filter(|value| value.is_finite())
+                .map_or(0.0, |value| value.clamp(0.0, 1.0)),
+            "width" => bar.width = value.parse::<u16>().ok()
+                .map_or(DEFAULT_BAR_WIDTH, |width| width.clamp(1, MAX_BAR_WIDTH)),
+            "fg" => span.fg = parse_color(value).or(span.fg.take()),
+            "bg" => span.bg = parse_color(value).or(span.bg.take()),
+            _ => {},
+        }
+    }
+
+    span.bar = Some(bar);
+
+    span
+}
+
+/// Parse markup into a list of spans
+///
+/// # Arguments
+///
+/// * `markup` - Text with markup
+///
+/// # Returns
+///
+/// A [`Vec`] of [`Span`]
+pub(crate) fn parse(markup: &str) -> Vec<Span> {
+    let mut spans: Vec<Span> = Vec::new();
+    let mut fg_stack: Vec<Option<String>> = Vec::new();
+    let mut bg_stack: Vec<Option<String>> = Vec::new();
+    let mut text = String::new();
+    let mut rest = markup;
+
+    while let Some(idx) = rest.find(['<', '&']) {
+        text.push_str(&rest[..idx]);
+        rest = &rest[idx..];
+
+        if rest.starts_with('&') {
+            // Decode entities and keep unknown ones verbatim
+            if let Some(end) = rest.find(';') && let Some(ch) = decode_entity(&rest[1..end]) {
+                text.push(ch);
+                rest = &rest[end + 1..];
+            } else {
+                text.push('&');
+                rest = &rest[1..];
+            }
+
+            continue;
+        }
+
+        // Keep lone brackets verbatim
+        let Some(tag) = rest.find('>').map(|end| &rest[1..end]).filter(|tag| is_tag(tag)) else {
+            text.push('<');
+            rest = &rest[1..];
+
+            continue;
+        };
+
+        rest = &rest[tag.len() + 2..];
+
+        // Flush text before styles change
+        push_text(&mut spans, &text, &fg_stack, &bg_stack);
+        text.clear();
+
+        if let Some(attrs) = tag.strip_suffix('/').unwrap_or(tag).strip_prefix("bar")
+            .filter(|attrs| attrs.is_empty() || attrs.starts_with(char::is_whitespace))
+        {
+            spans.push(parse_bar(attrs, &fg_stack, &bg_stack));
+        } else if let Some(name) = tag.strip_prefix('/') {
+            match name.trim() {
+                "fg" => { fg_stack.pop(); },
+                "bg" => { bg_stack.pop(); },
+                _ => {},
+            }
+        } else if let Some((name, value)) = tag.split_once('=') {
+            // Invalid colors inherit the current color
+            let color = parse_color(value);
+
+            match name.trim() {
+                "fg" => fg_stack.push(color),
+                "bg" => bg_stack.push(color),
+                _ => {},
+            }
+        }
+    }
+
+    text.push_str(rest);
+
+    push_text(&mut spans, &text, &fg_stack, &bg_stack);
+
+    spans
+}
+
+/// Strip all markup and return the plain text
+///
+/// # Arguments
+///
+/// * `markup` - Text with markup
+///
+/// # Returns
+///
+/// A [`String`] without markup
+pub(crate) fn strip(markup: &str) -> String {
+    parse(markup).into_iter()
+        .map(|span| span.text)
+        .collect()
+}
+
+/// Escape text so it can be used verbatim in markup
+///
+/// # Arguments
+///
+/// * `text` - Text to escape
+///
+/// # Returns
+///
+/// A [`String`] with escaped markup chars
+pub(crate) fn escape(text: &str) -> String {
+    text.replace('&', "&amp;")
+        .replace('<', "&lt;")
+        .replace('>', "&gt;")
+}
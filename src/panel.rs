@@ -10,19 +10,25 @@
 //!
 
 use std::fmt;
+use std::collections::HashMap;
 use bitflags::bitflags;
-use log::debug;
+use log::{debug, warn};
 use anyhow::{Context, Result};
 use easy_min_max::max;
 use stdext::function_name;
 use x11rb::connection::Connection;
 use x11rb::protocol::xproto::{ChangeGCAux, ConnectionExt, Drawable, Rectangle};
 use crate::client::ClientFlags;
+use crate::font;
 use crate::icon::Icon;
+use crate::markup;
+use crate::markup::{Bar, Span};
+use crate::plugin::{calc_min_width, ScrollDirection, TextAlign};
 use crate::screen::Screen;
-use crate::style::{CalcSpacing, Style};
+use crate::style::{alloc_color, CalcSpacing, Style};
 use crate::subtle::Subtle;
 use crate::tagging::Tagging;
+use crate::tooltip;
 use crate::tray::TrayFlags;
 use crate::view::{View, ViewFlags};
 
@@ -64,7 +70,7 @@ bitflags! {
 }
 
 pub(crate) enum PanelAction {
-    _MouseOver(i16, i16),
+    MouseOver(i16, i16),
     MouseDown(i16, i16, i8),
     MouseOut,
 }
@@ -81,10 +87,18 @@ pub(crate) struct Panel {
     pub(crate) x: i16,
     pub(crate) width: u16,
     pub(crate) screen_idx: usize,
+    pub(crate) is_bottom: bool,
     #[cfg(feature = "plugins")]
     pub(crate) plugin_idx: usize,
+    /// Position inside of the side; items with higher positions are placed further right
+    pub(crate) position: i32,
+    /// Positional flags of the prefix in the panel list
+    pub(crate) prefix_flags: PanelFlags,
     pub(crate) text: Option<String>,
     pub(crate) text_widths: Vec<u16>,
+    pub(crate) spans: Vec<Span>,
+    pub(crate) colors: HashMap<String, i32>,
+    pub(crate) align: TextAlign,
 }
 
 impl Panel {
@@ -226,10 +240,53 @@ impl Panel {
                 .foreground(style.fg as u32)
                 .background(style.bg as u32))?.check()?;
 
-            conn.image_text8(drawable, subtle.draw_gc,
-                             (self.x as u16 + style.calc_spacing(CalcSpacing::Left) as u16 + offset_x) as i16,
-                             font.y as i16 + style.calc_spacing(CalcSpacing::Top),
-                             text.as_bytes())?.check()?;
+            conn.image_text16(drawable, subtle.draw_gc,
+                              (self.x as u16 + style.calc_spacing(CalcSpacing::Left) as u16 + offset_x) as i16,
+                              font.y as i16 + style.calc_spacing(CalcSpacing::Top),
+                              &font::encode_text(text))?.check()?;
+        }
+
+        Ok(())
+    }
+
+    /// Draw bar on panel
+    ///
+    /// # Arguments
+    ///
+    /// * `subtle` - Global state object
+    /// * `drawable` - Drawable to use
+    /// * `offset_x` - X offset on panel
+    /// * `width` - Width of the bar
+    /// * `bar` - Bar to draw
+    /// * `style` - Style to use
+    ///
+    /// # Returns
+    ///
+    /// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+    fn draw_bar(&self, subtle: &Subtle, drawable: Drawable, offset_x: u16,
+                width: u16, bar: &Bar, style: &Style) -> Result<()>
+    {
+        let conn = subtle.conn.get().context("Failed to get connection")?;
+
+        if let Some(font) = style.get_font(subtle) {
+            let x = (self.x as u16 + style.calc_spacing(CalcSpacing::Left) as u16 + offset_x) as i16;
+            let filled_width = (width as f32 * bar.value).round() as u16;
+
+            // Draw background first and then the filled part on top
+            for (color, bar_width) in [(style.bg, width), (style.fg, filled_width)] {
+                if 0 == bar_width {
+                    continue;
+                }
+
+                conn.change_gc(subtle.draw_gc, &ChangeGCAux::default()
+                    .foreground(color as u32))?.check()?;
+                conn.poly_fill_rectangle(drawable, subtle.draw_gc, &[Rectangle {
+                    x,
+                    y: style.calc_spacing(CalcSpacing::Top) + 1,
+                    width: bar_width,
+                    height: font.height.saturating_sub(2),
+                }])?.check()?;
+            }
         }
 
         Ok(())
@@ -289,6 +346,8 @@ impl Panel {
             (PanelFlags::empty(), 0)
         };
 
+        panel.prefix_flags = pos_flags;
+
         // Handle panel types
         match &name[pos_idx..] {
             "tray" => panel.flags = PanelFlags::TRAY | pos_flags,
@@ -330,18 +389,45 @@ impl Panel {
         // Handle panel item type
         if self.flags.intersects(PanelFlags::PLUGIN) {
             if let Some(plugin) = subtle.plugins.get(self.plugin_idx) {
-                if let Ok(res) = plugin.update() {
-                    if let Some(font) = subtle.views_style.get_font(subtle) {
-                        if let Ok((width, _, _)) = font.calc_text_width(conn, &res, false) {
-                            self.text_widths[0] = width;
+                if let Some(output) = plugin.output((self.screen_idx, self.is_bottom)) {
+                    let default_screen = &conn.setup().roots[subtle.screen_num];
+
+                    self.spans = if output.markup {
+                        markup::parse(&output.text)
+                    } else {
+                        vec![Span { text: output.text.clone(), ..Span::default() }]
+                    };
+                    self.text_widths.resize(self.spans.len(), Default::default());
+
+                    for (span_idx, span) in self.spans.iter().enumerate() {
+                        if let Some(font) = subtle.views_style.get_font(subtle) {
+                            if let Some(bar) = span.bar {
+                                self.text_widths[span_idx] = bar.width.saturating_mul(font.column_width);
+                            } else if let Ok((width, _, _)) = font.calc_text_width(conn, &span.text, false) {
+                                self.text_widths[span_idx] = width;
+                            }
+                        }
+
+                        // Cache colors of the markup
+                        for color_str in [&span.fg, &span.bg].into_iter().flatten() {
+                            if !self.colors.contains_key(color_str) {
+                                self.colors.insert(color_str.clone(), alloc_color(conn,
+                                    color_str, default_screen.default_colormap)?);
+                            }
                         }
                     }
 
+                    // Reserve the minimum width in cells of either the output or the plugin
+                    let spacing = subtle.views_style.calc_spacing(CalcSpacing::Width) as u16;
+                    let min_width = subtle.views_style.get_font(subtle)
+                        .map_or(0, |font| calc_min_width(max!(output.min_width, plugin.min_width_cells()),
+                                                         font.column_width, spacing));
+
                     // Finally update actual length
-                    self.width = self.text_widths[0]
-                        + subtle.views_style.calc_spacing(CalcSpacing::Width) as u16;
+                    self.width = max!(self.text_widths.iter().sum::<u16>() + spacing, min_width);
+                    self.align = output.align.unwrap_or(plugin.align);
 
-                    self.text = Some(res);
+                    self.text = Some(output.text);
                 }
             }
         } else if self.flags.intersects(PanelFlags::SEPARATOR) {
@@ -488,8 +574,32 @@ impl Panel {
         } else if self.flags.intersects(PanelFlags::PLUGIN) {
             self.draw_rect(subtle, subtle.panel_double_buffer,0, self.width, &subtle.views_style)?;
 
-            if let Some(text) = &self.text {
-                self.draw_text(subtle, subtle.panel_double_buffer, 0, text, &subtle.views_style)?;
+            let mut style = subtle.views_style.clone();
+
+            // Align text inside of the minimum width
+            let text_width = self.text_widths.iter().sum::<u16>()
+                + subtle.views_style.calc_spacing(CalcSpacing::Width) as u16;
+
+            let mut offset_x = match self.align {
+                TextAlign::Left => 0,
+                TextAlign::Center => self.width.saturating_sub(text_width) / 2,
+                TextAlign::Right => self.width.saturating_sub(text_width),
+            };
+
+            for (span_idx, span) in self.spans.iter().enumerate() {
+                style.fg = span.fg.as_ref().and_then(|fg| self.colors.get(fg))
+                    .copied().unwrap_or(subtle.views_style.fg);
+                style.bg = span.bg.as_ref().and_then(|bg| self.colors.get(bg))
+                    .copied().unwrap_or(subtle.views_style.bg);
+
+                if let Some(bar) = &span.bar {
+                    self.draw_bar(subtle, subtle.panel_double_buffer, offset_x,
+                                  self.text_widths[span_idx], bar, &style)?;
+                } else {
+                    self.draw_text(subtle, subtle.panel_double_buffer, offset_x, &span.text, &style)?;
+                }
+
+                offset_x += self.text_widths[span_idx];
             }
         } else if self.flags.intersects(PanelFlags::SEPARATOR) {
             self.draw_rect(subtle, subtle.panel_double_buffer,0, self.width, &subtle.separator_style)?;
@@ -615,13 +725,45 @@ impl Panel {
     /// # Returns
     ///
     /// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
-    pub(crate) fn handle_action(&self, subtle: &Subtle, action: &PanelAction, _is_bottom: bool) -> Result<()> {
-        if let &PanelAction::MouseDown(x, _y, _button) = action {
+    pub(crate) fn handle_action(&self, subtle: &Subtle, action: &PanelAction, is_bottom: bool) -> Result<()> {
+        // Handle tooltips of plugins
+        #[cfg(feature = "plugins")]
+        if let Some(plugin) = subtle.plugins.get(self.plugin_idx)
+            && self.flags.contains(PanelFlags::PLUGIN | PanelFlags::MOUSE_OVER)
+        {
+            let owner = (self.screen_idx, self.x);
+
+            match *action {
+                PanelAction::MouseOver(x, _y) if x >= self.x && x <= self.x + self.width as i16 =>
+                    tooltip::hover(subtle, owner, is_bottom, &plugin.tooltip())?,
+                PanelAction::MouseOver(_, _) | PanelAction::MouseOut => tooltip::leave(subtle, Some(owner))?,
+                _ => {},
+            }
+        }
+
+        if let &PanelAction::MouseDown(x, _y, button) = action {
 
             // Check if x is in boundry box of panel
             if x >= self.x && x <= self.x + self.width as i16 {
 
                 // Handle panel type
+                #[cfg(feature = "plugins")]
+                if self.flags.contains(PanelFlags::PLUGIN | PanelFlags::MOUSE_DOWN) {
+                    if let Some(plugin) = subtle.plugins.get(self.plugin_idx) {
+                        let panel_id = (self.screen_idx, is_bottom);
+
+                        // Wheel buttons never reach on_click
+                        let res = match ScrollDirection::from_button(button as u8) {
+                            Some(direction) => plugin.scroll(subtle, panel_id, direction),
+                            None => plugin.click(subtle, panel_id, button as u8, x - self.x),
+                        };
+
+                        if let Err(err) = res {
+                            warn!("Cannot handle click of plugin ({}): {}", plugin.name, err);
+                        }
+                    }
+                }
+
                 if self.flags.intersects(PanelFlags::VIEWS) {
                     let mut offset_x = self.x;
 
@@ -0,0 +1,159 @@
+//!
+//! @package subtle-rs
+//!
+//! @file Plugin command functions
+//! @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+//! @version $Id$
+//!
+//! This program can be distributed under the terms of the GNU GPLv3.
+//! See the file LICENSE for details.
+//!
+
+use std::io::Read;
+use std::process::{Command, Stdio};
+use std::sync::mpsc;
+use std::sync::mpsc::Receiver;
+use std::time::{Duration, Instant};
+use anyhow::{anyhow, Context, Result};
+use log::{debug, warn};
+use stdext::function_name;
+use serde::{Deserialize, Serialize};
+
+/// Default timeout of commands in milliseconds
+pub(crate) const DEFAULT_COMMAND_TIMEOUT: u64 = 1000;
+
+/// Maximum timeout of commands in milliseconds
+pub(crate) const MAX_COMMAND_TIMEOUT: u64 = 4000;
+
+/// Grace period to collect output of pipes kept open by children of killed commands
+const PIPE_TIMEOUT: Duration = Duration::from_millis(20);
+
+/// Maximum number of commands of each plugin running in the background
+pub(crate) const MAX_COMMAND_JOBS: usize = 4;
+
+/// Time to wait for commands, so short ones return their output right away
+pub(crate) const MAX_COMMAND_WAIT: Duration = Duration::from_millis(50);
+
+#[derive(Debug, Deserialize)]
+pub(crate) struct CommandRequest {
+    /// Command to run
+    pub(crate) cmd: String,
+    /// Arguments of the command
+    #[serde(default)]
+    pub(crate) args: Vec<String>,
+    /// Timeout in milliseconds
+    pub(crate) timeout_ms: Option<u64>,
+}
+
+#[derive(Default, Debug, Clone, Serialize)]
+pub(crate) struct CommandOutput {
+    /// Captured stdout
+    pub(crate) stdout: String,
+    /// Captured stderr
+    pub(crate) stderr: String,
+    /// Exit code or -1 when killed or not run at all
+    pub(crate) exit_code: i32,
+    /// Whether the command has been killed after the timeout
+    pub(crate) timed_out: bool,
+    /// Whether the command is still running in the background
+    pub(crate) pending: bool,
+}
+
+/// Run helper command on the event loop with a short timeout
+///
+/// # Arguments
+///
+/// * `cmd` - Command to run
+/// * `args` - Arguments of the command
+/// * `timeout` - Timeout after which the command is killed
+///
+/// # Returns
+///
+/// A [`Result`] with either [`CommandOutput`] on success or otherwise [`anyhow::Error`] when it timed out
+pub(crate) fn run_helper(cmd: &str, args: &[&str], timeout: Duration) -> Result<CommandOutput> {
+    let args: Vec<String> = args.iter().map(|arg| arg.to_string()).collect();
+
+    let output = run_command(cmd, &args, timeout)?;
+
+    if output.timed_out {
+        warn!("Helper `{}` not responding after {}ms", cmd, timeout.as_millis());
+
+        return Err(anyhow!("`{}` not responding", cmd));
+    }
+
+    Ok(output)
+}
+
+/// Read pipe to the end in a separate thread
+///
+/// # Arguments
+///
+/// * `pipe` - Pipe to read from
+///
+/// # Returns
+///
+/// A [`Receiver`] for the content of the pipe
+fn spawn_reader<R: Read + Send + 'static>(mut pipe: R) -> Receiver<String> {
+    let (sender, receiver) = mpsc::channel();
+
+    std::thread::spawn(move || {
+        let mut buf = String::new();
+
+        let _ = pipe.read_to_string(&mut buf);
+        let _ = sender.send(buf);
+    });
+
+    receiver
+}
+
+/// Run command and capture its output, the command is killed after the timeout
+///
+/// # Arguments
+///
+/// * `cmd` - Command to run
+/// * `args` - Arguments of the command
+/// * `timeout` - Timeout after which the command is killed
+///
+/// # Returns
+///
+/// A [`Result`] with either [`CommandOutput`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn run_command(cmd: &str, args: &[String], timeout: Duration) -> Result<CommandOutput> {
+    let mut child = Command::new(cmd)
+        .args(args)
+        .stdin(Stdio::null())
+        .stdout(Stdio::piped())
+        .stderr(Stdio::piped())
+        .spawn()?;
+
+    // Drain pipes in the background to prevent the child from blocking
+    let stdout_reader = spawn_reader(child.stdout.take().context("Cannot capture stdout")?);
+    let stderr_reader = spawn_reader(child.stderr.take().context("Cannot capture stderr")?);
+
+    let deadline = Instant::now() + timeout;
+    let mut timed_out = false;
+
+    let status = loop {
+        if let Some(status) = child.try_wait()? {
+            break status;
+        }
+
+        if Instant::now() >= deadline {
+            let _ = child.kill();
+
+            timed_out = true;
+
+            break child.wait()?;
+        }
+
+        std::thread::sleep(Duration::from_millis(5));
+    };
+
+    debug!("{}: cmd={}, status={}, timed_out={}", function_name!(), cmd, status, timed_out);
+
+    Ok(CommandOutput {
+        stdout: stdout_reader.recv_timeout(PIPE_TIMEOUT).unwrap_or_default(),
+        stderr: stderr_reader.recv_timeout(PIPE_TIMEOUT).unwrap_or_default(),
+        exit_code: status.code().unwrap_or(-1),
+        timed_out,
+    })
+}
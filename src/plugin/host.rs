@@ -0,0 +1,1161 @@
+//!
+//! @package subtle-rs
+//!
+//! @file Plugin host functions
+//! @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+//! @version $Id$
+//!
+//! This program can be distributed under the terms of the GNU GPLv3.
+//! See the file LICENSE for details.
+//!
+
+use std::collections::HashMap;
+use std::path::Path;
+use std::time::{Duration, Instant};
+use extism::{host_fn, Manifest, UserData, Wasm, PTR};
+use anyhow::{anyhow, Context, Result};
+use extism::ValType::I32;
+use log::{debug, warn};
+use stdext::function_name;
+use regex::Regex;
+use serde::{Deserialize, Serialize};
+use crate::selection::{SelectionContent, SelectionKind};
+use crate::grab::{CycleOrder, GrabAction, GrabFlags};
+use crate::text;
+use crate::plugin::{PluginState, lookup_config_value};
+use crate::plugin::job::JobStatus;
+use crate::plugin::command::{CommandOutput, CommandRequest, DEFAULT_COMMAND_TIMEOUT, MAX_COMMAND_JOBS, MAX_COMMAND_TIMEOUT, MAX_COMMAND_WAIT, run_command};
+use crate::plugin::http::{HttpRequest, HttpResponse, MAX_HTTP_CACHE_ENTRIES, MAX_HTTP_JOBS, fetch_with_retries, is_host_allowed, is_transient_failure};
+use crate::plugin::notification::{NotifyError, NotifyRequest, NotifyResult, escape_gvariant_string, parse_notification_id, parse_urgency, run_notification_call};
+use crate::plugin::time::{format_duration, format_time, parse_time};
+use crate::plugin::sys::{BrightnessRequest, DiskUsage, MediaControl, MediaStatus, NetSample, NetThroughput, ProcCounters, VolumeRequest, VolumeState, WifiStatus, BACKLIGHT_PATH, DEFAULT_SINK, HWMON_PATH, MAX_HELPER_JOBS, NET_PATH, POWER_SUPPLY_PATH, PROC_PATH, calc_cpu_usage, calc_disk_usage, calc_net_throughput, calc_processes, calc_volume, count_mail, find_wireless_iface, parse_cpu_times, parse_default_route, parse_load_average, parse_mail_paths, parse_memory_info, parse_uptime, read_battery_status, read_brightness, read_media_status, read_net_sample, read_temperatures, read_volume, read_wifi_status, run_pactl, run_playerctl, scan_processes, write_brightness};
+
+/// Maximum length of the selection preview in chars
+const MAX_SELECTION_PREVIEW: usize = 1024;
+
+/// Maximum number of pending timers of each plugin
+const MAX_TIMERS: usize = 16;
+
+#[derive(Debug, Clone, PartialEq, Deserialize)]
+pub(crate) struct WmCommand {
+    /// Name of the action
+    pub(crate) action: String,
+    /// Argument of the action
+    #[serde(default)]
+    pub(crate) arg: String,
+}
+
+#[repr(i32)]
+#[derive(Debug, Copy, Clone, PartialEq)]
+pub(crate) enum WmCommandError {
+    /// Action is unknown
+    UnknownAction = 1,
+    /// Argument is missing or invalid
+    InvalidArgument = 2,
+    /// Plugin is not allowed to run the action
+    NotAllowed = 3,
+}
+
+#[derive(Default, Debug, Serialize)]
+pub(crate) struct WmCommandResult {
+    /// Zero on success or otherwise the error code
+    pub(crate) code: i32,
+    /// Error message on failure
+    pub(crate) error: Option<String>,
+}
+
+host_fn!(get_formatted_time(_user_data: (); format: String) -> String {
+    format_time(&format)
+});
+
+host_fn!(get_formatted_duration(_user_data: (); seconds: String, style: String) -> String {
+    let seconds = seconds.trim().parse::<i64>()
+        .with_context(|| format!("Invalid duration `{}`", seconds))?;
+
+    format_duration(seconds, &style)
+});
+
+host_fn!(get_unix_time(_user_data: (); time: String) -> String {
+    Ok(parse_time(&time)?.to_string())
+});
+
+host_fn!(get_memory(_user_data: ()) -> String {
+    let (mem_available, mem_total, mem_free) = std::fs::read_to_string("/proc/meminfo")?
+        .lines()
+        .filter(|line| line.starts_with("MemAvailable") || line.starts_with("MemTotal") || line.starts_with("MemFree"))
+        .map(|line| line.split_whitespace().nth(1).and_then(|v| v.parse::<i32>().ok()))
+        .collect_tuple()
+        .context("Cannot read `/proc/meminfo`")?;
+
+   Ok(format!("{} {} {}", mem_total.unwrap_or(1), mem_available.unwrap_or(0), mem_free.unwrap_or(0)))
+});
+
+host_fn!(measure_text(_user_data: (); text: String) -> String {
+    Ok(text::display_width(&text).to_string())
+});
+
+host_fn!(truncate_text(_user_data: (); text: String, cells: String, ellipsis: String) -> String {
+    let cells = cells.trim().parse::<usize>()
+        .with_context(|| format!("Invalid number of cells `{}`", cells))?;
+
+    Ok(text::truncate(&text, cells, &ellipsis))
+});
+
+host_fn!(get_memory_info(user_data: PluginState;) -> String {
+    let state = user_data.get()?;
+    let state = state.lock().unwrap();
+
+    state.cache.lock().unwrap().get_or_insert_with("get_memory_info", "", || {
+        let info = parse_memory_info(&std::fs::read_to_string("/proc/meminfo")?);
+
+        Ok(serde_json::to_string(&info)?)
+    })
+});
+
+host_fn!(get_load_average(user_data: PluginState;) -> String {
+    let state = user_data.get()?;
+    let state = state.lock().unwrap();
+
+    state.cache.lock().unwrap().get_or_insert_with("get_load_average", "", || {
+        let load = parse_load_average(&std::fs::read_to_string("/proc/loadavg")?)
+            .context("Cannot parse `/proc/loadavg`")?;
+
+        Ok(serde_json::to_string(&load)?)
+    })
+});
+
+host_fn!(get_uptime(user_data: PluginState;) -> String {
+    let state = user_data.get()?;
+    let state = state.lock().unwrap();
+
+    state.cache.lock().unwrap().get_or_insert_with("get_uptime", "", || {
+        let uptime = parse_uptime(&std::fs::read_to_string("/proc/uptime")?)
+            .context("Cannot parse `/proc/uptime`")?;
+
+        Ok(uptime.to_string())
+    })
+});
+
+host_fn!(get_battery(_user_data: (); battery_slot: String) -> String {
+    let charge_full = std::fs::read_to_string(
+        format!("/sys/class/power_supply/BAT{}/charge_full", battery_slot))?;
+    let charge_now = std::fs::read_to_string(
+        format!("/sys/class/power_supply/BAT{}/charge_now", battery_slot))?;
+
+    Ok(format!("{} {}", charge_full.trim(), charge_now.trim()))
+});
+
+host_fn!(log_message(user_data: PluginState; level: String, message: String) {
+    let level = parse_log_level(&level);
+
+    // Skip everything when the level is filtered anyway
+    if log::log_enabled!(level) {
+        let state = user_data.get()?;
+        let state = state.lock().unwrap();
+
+        log::log!(level, "[{}] {}", state.name, message);
+    }
+
+    Ok(())
+});
+
+host_fn!(exec_command(user_data: PluginState; request: String) -> String {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    let output = if !state.check_capability(state.capabilities.exec, "exec_command") {
+        CommandOutput {
+            stderr: "Capability exec not declared".into(),
+            exit_code: -1,
+            ..CommandOutput::default()
+        }
+    } else if state.allow_exec {
+        let request: CommandRequest = serde_json::from_str(&request)?;
+        let job_key = format!("{}\n{}", request.cmd, request.args.join("\n"));
+
+        debug!("{}: plugin={}, cmd={}, args={:?}", function_name!(), state.name, request.cmd, request.args);
+
+        let timeout = Duration::from_millis(request.timeout_ms
+            .unwrap_or(DEFAULT_COMMAND_TIMEOUT).min(MAX_COMMAND_TIMEOUT));
+
+        // Return the output of short commands right away, others are handed over on a later call
+        let status = state.command_jobs.run_waiting(&job_key, MAX_COMMAND_JOBS, timeout.min(MAX_COMMAND_WAIT), move || {
+            run_command(&request.cmd, &request.args, timeout)
+                .unwrap_or_else(|err| CommandOutput {
+                    stderr: err.to_string(),
+                    exit_code: -1,
+                    ..CommandOutput::default()
+                })
+        });
+
+        match status {
+            JobStatus::Pending => CommandOutput {
+                exit_code: -1,
+                pending: true,
+                ..CommandOutput::default()
+            },
+            JobStatus::Done(output) => output,
+            JobStatus::Failed => CommandOutput {
+                stderr: "Command thread died".into(),
+                exit_code: -1,
+                ..CommandOutput::default()
+            },
+            JobStatus::Busy => CommandOutput {
+                stderr: "Too many pending commands".into(),
+                exit_code: -1,
+                ..CommandOutput::default()
+            },
+        }
+    } else {
+        warn!("Plugin is not allowed to run commands ({})", state.name);
+
+        CommandOutput {
+            stderr: "Command execution not allowed".into(),
+            exit_code: -1,
+            ..CommandOutput::default()
+        }
+    };
+
+    Ok(serde_json::to_string(&output)?)
+});
+
+host_fn!(http_fetch(user_data: PluginState; request: String) -> String {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    let request: HttpRequest = serde_json::from_str(&request)?;
+
+    let is_declared = is_host_allowed(&state.capabilities.http_hosts, &request.url);
+
+    let response = if !state.check_capability(is_declared, "http_fetch") {
+        HttpResponse {
+            error: Some("Capability http not declared for host".into()),
+            ..HttpResponse::default()
+        }
+    } else if is_host_allowed(&state.allowed_hosts, &request.url) {
+        let cache_key = format!("{} {}", request.method.to_ascii_uppercase(), request.url);
+        let job_key = format!("{}\n{}", cache_key, request.body);
+
+        debug!("{}: plugin={}, method={}, url={}", function_name!(), state.name, request.method, request.url);
+
+        let job_request = request.clone();
+
+        // Run requests in the background and hand the result over on a later call
+        let response = match state.http_jobs.run(&job_key, MAX_HTTP_JOBS, move || fetch_with_retries(&job_request)) {
+            JobStatus::Pending => HttpResponse {
+                pending: true,
+                ..HttpResponse::default()
+            },
+            JobStatus::Done(response) => response,
+            JobStatus::Failed => HttpResponse {
+                error: Some("Request thread died".into()),
+                ..HttpResponse::default()
+            },
+            JobStatus::Busy => HttpResponse {
+                error: Some("Too many pending requests".into()),
+                ..HttpResponse::default()
+            },
+        };
+
+        match request.cache_ttl_ms.map(Duration::from_millis) {
+            Some(_) if !response.pending && !is_transient_failure(&response) => {
+                // Drop the oldest entry to make room
+                if MAX_HTTP_CACHE_ENTRIES <= state.http_cache.len() && !state.http_cache.contains_key(&cache_key)
+                    && let Some(oldest_key) = state.http_cache.iter()
+                        .min_by_key(|(_, (time, _))| *time)
+                        .map(|(key, _)| key.clone())
+                {
+                    state.http_cache.remove(&oldest_key);
+                }
+
+                state.http_cache.insert(cache_key, (Instant::now(), response.clone()));
+
+                response
+            },
+            Some(ttl) => match state.http_cache.get(&cache_key)
+                .filter(|(time, _)| time.elapsed() <= ttl)
+            {
+                Some((_, cached)) => HttpResponse {
+                    attempts: response.attempts,
+                    cached: true,
+                    pending: response.pending,
+                    ..cached.clone()
+                },
+                None => response,
+            },
+            None => response,
+        }
+    } else {
+        warn!("Plugin is not allowed to fetch url ({}): {}", state.name, request.url);
+
+        HttpResponse {
+            error: Some("Host not allowed".into()),
+            ..HttpResponse::default()
+        }
+    };
+
+    Ok(serde_json::to_string(&response)?)
+});
+
+host_fn!(kv_get(user_data: PluginState; key: String) -> String {
+    let state = user_data.get()?;
+    let state = state.lock().unwrap();
+
+    if !state.check_capability(state.capabilities.kv, "kv_get") {
+        return Ok(String::new());
+    }
+
+    Ok(state.store.values.get(&key).cloned().unwrap_or_default())
+});
+
+host_fn!(kv_set(user_data: PluginState; key: String, value: String) -> bool {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    if !state.check_capability(state.capabilities.kv, "kv_set") {
+        return Ok(false);
+    }
+
+    match state.store.set(&key, &value) {
+        Ok(_) => Ok(true),
+        Err(err) => {
+            warn!("Cannot store plugin value ({}): {}", state.name, err);
+
+            Ok(false)
+        },
+    }
+});
+
+host_fn!(send_command(user_data: PluginState; command: String) -> String {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    let command: WmCommand = serde_json::from_str(&command)?;
+
+    if !state.check_capability(state.capabilities.command, "send_command") {
+        return Ok(serde_json::to_string(&WmCommandResult {
+            code: WmCommandError::NotAllowed as i32,
+            error: Some("Capability command not declared".into()),
+        })?);
+    }
+
+    // Spawning programs additionally needs the exec capability
+    let allow_exec = state.capabilities.exec && state.allow_exec;
+
+    let result = match parse_wm_command(&command, &state.context.view_names, allow_exec) {
+        Ok((flag, action)) => {
+            debug!("{}: plugin={}, action={}, arg={}", function_name!(), state.name, command.action, command.arg);
+
+            // Commands need the global state, so run them after the plugin returns
+            let position = state.context.panel.map_or((0, 0), |panel| (panel.x, panel.y));
+
+            state.commands.push((flag, action, position));
+
+            WmCommandResult::default()
+        },
+        Err(err) => {
+            warn!("Cannot run command of plugin ({}): {:?}", state.name, err);
+
+            WmCommandResult {
+                code: err as i32,
+                error: Some(match err {
+                    WmCommandError::UnknownAction => format!("Unknown action `{}`", command.action),
+                    WmCommandError::InvalidArgument => format!("Invalid argument `{}`", command.arg),
+                    WmCommandError::NotAllowed => "Command execution not allowed".into(),
+                }),
+            }
+        },
+    };
+
+    Ok(serde_json::to_string(&result)?)
+});
+
+host_fn!(send_notification(user_data: PluginState; request: String) -> String {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    let job_key = format!("send_notification\n{}", request);
+    let request: NotifyRequest = serde_json::from_str(&request)?;
+
+    let Some(urgency) = parse_urgency(&request.urgency) else {
+        return Ok(serde_json::to_string(&NotifyResult {
+            code: NotifyError::InvalidRequest as i32,
+            error: Some(format!("Unknown urgency `{}`", request.urgency)),
+            ..NotifyResult::default()
+        })?);
+    };
+
+    debug!("{}: plugin={}, summary={}", function_name!(), state.name, request.summary);
+
+    let args = [
+        escape_gvariant_string(&state.name),
+        String::from("0"),
+        escape_gvariant_string(&request.icon),
+        escape_gvariant_string(&request.summary),
+        escape_gvariant_string(&request.body),
+        String::from("[]"),
+        format!("{{'urgency': <byte {}>}}", urgency),
+        // Typed to keep negative values from being parsed as options
+        format!("int32 {}", request.timeout_ms.unwrap_or(-1)),
+    ];
+
+    // The daemon can be slow to answer, so call it in the background like exec_command
+    let status = state.helper_jobs.run(&job_key, MAX_HELPER_JOBS, move || {
+        let result = run_notification_call("Notify", &args)
+            .and_then(|output| parse_notification_id(&output)
+                .ok_or_else(|| anyhow!("Cannot parse notification id")));
+
+        serde_json::to_string(&match result {
+            Ok(id) => NotifyResult {
+                id,
+                ..NotifyResult::default()
+            },
+            Err(err) => NotifyResult {
+                code: NotifyError::NotAvailable as i32,
+                error: Some(err.to_string()),
+                ..NotifyResult::default()
+            },
+        }).unwrap_or_default()
+    });
+
+    Ok(match status {
+        JobStatus::Done(result) => result,
+        JobStatus::Pending => serde_json::to_string(&NotifyResult {
+            pending: true,
+            ..NotifyResult::default()
+        })?,
+        JobStatus::Failed => serde_json::to_string(&NotifyResult {
+            code: NotifyError::NotAvailable as i32,
+            error: Some("Helper thread died".into()),
+            ..NotifyResult::default()
+        })?,
+        JobStatus::Busy => serde_json::to_string(&NotifyResult {
+            code: NotifyError::NotAvailable as i32,
+            error: Some("Too many pending helpers".into()),
+            ..NotifyResult::default()
+        })?,
+    })
+});
+
+host_fn!(close_notification(user_data: PluginState; id: String) -> String {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    let Ok(id) = id.trim().parse::<u32>() else {
+        return Ok(serde_json::to_string(&NotifyResult {
+            code: NotifyError::InvalidRequest as i32,
+            error: Some(format!("Invalid notification id `{}`", id)),
+            ..NotifyResult::default()
+        })?);
+    };
+
+    // Nobody waits for the answer, errors are just logged
+    let name = state.name.clone();
+
+    let is_started = state.helper_jobs.detach(MAX_HELPER_JOBS, move || {
+        if let Err(err) = run_notification_call("CloseNotification", &[id.to_string()]) {
+            warn!("Cannot close notification of plugin ({}): {}", name, err);
+        }
+
+        String::new()
+    });
+
+    Ok(serde_json::to_string(&match is_started {
+        true => NotifyResult {
+            id,
+            pending: true,
+            ..NotifyResult::default()
+        },
+        false => NotifyResult {
+            id,
+            code: NotifyError::NotAvailable as i32,
+            error: Some("Too many pending helpers".into()),
+            pending: false,
+        },
+    })?)
+});
+
+host_fn!(set_tooltip(user_data: PluginState; tooltip: String) {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    state.tooltip = tooltip;
+
+    Ok(())
+});
+
+host_fn!(set_min_width(user_data: PluginState; cells: String) {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    state.min_width_cells = cells.trim().parse::<u16>().unwrap_or_else(|_| {
+        warn!("Invalid minimum width of plugin ({}): {}", state.name, cells);
+
+        0
+    });
+
+    Ok(())
+});
+
+host_fn!(schedule_callback(user_data: PluginState; delay_ms: String) -> String {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    let delay = delay_ms.trim().parse::<u64>()
+        .with_context(|| format!("Invalid delay `{}`", delay_ms))?;
+
+    if MAX_TIMERS <= state.timers.len() {
+        warn!("Too many pending timers of plugin ({})", state.name);
+
+        return Ok(String::new());
+    }
+
+    let deadline = Instant::now().checked_add(Duration::from_millis(delay))
+        .context("Delay out of range")?;
+
+    // Ids are unique per plugin and never zero
+    state.last_timer_id = state.last_timer_id.wrapping_add(1).max(1);
+
+    let timer_id = state.last_timer_id;
+
+    state.timers.insert(timer_id, deadline);
+
+    debug!("{}: plugin={}, id={}, delay_ms={}", function_name!(), state.name, timer_id, delay);
+
+    Ok(timer_id.to_string())
+});
+
+host_fn!(cancel_callback(user_data: PluginState; id: String) -> bool {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    Ok(id.trim().parse::<u32>().is_ok_and(|timer_id| state.timers.remove(&timer_id).is_some()))
+});
+
+host_fn!(get_current_view(user_data: PluginState;) -> String {
+    let state = user_data.get()?;
+    let state = state.lock().unwrap();
+
+    Ok(serde_json::to_string(&state.context.current_view)?)
+});
+
+host_fn!(list_views(user_data: PluginState;) -> String {
+    let state = user_data.get()?;
+    let state = state.lock().unwrap();
+
+    Ok(serde_json::to_string(&state.context.views)?)
+});
+
+host_fn!(list_clients(user_data: PluginState;) -> String {
+    let state = user_data.get()?;
+    let state = state.lock().unwrap();
+
+    Ok(serde_json::to_string(&state.context.clients)?)
+});
+
+host_fn!(get_pointer(user_data: PluginState;) -> String {
+    let state = user_data.get()?;
+    let state = state.lock().unwrap();
+
+    Ok(serde_json::to_string(&state.context.pointer)?)
+});
+
+host_fn!(get_clipboard(user_data: PluginState; selection: String) -> String {
+    let state = user_data.get()?;
+    let state = state.lock().unwrap();
+
+    if !state.check_capability(state.capabilities.clipboard, "get_clipboard") {
+        return Ok(serde_json::to_string(&SelectionContent::default())?);
+    }
+
+    let kind = SelectionKind::parse(&selection)
+        .with_context(|| format!("Unknown selection `{}`", selection))?;
+
+    // Only return a preview of large contents
+    let content = state.context.selections.get(&kind)
+        .map(|content| SelectionContent {
+            text: content.text.chars().take(MAX_SELECTION_PREVIEW).collect(),
+            content_type: content.content_type.clone(),
+        })
+        .unwrap_or_default();
+
+    Ok(serde_json::to_string(&content)?)
+});
+
+host_fn!(set_clipboard(user_data: PluginState; selection: String, text: String) -> bool {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    if !state.check_capability(state.capabilities.clipboard, "set_clipboard") {
+        return Ok(false);
+    }
+
+    let Some(kind) = SelectionKind::parse(&selection) else {
+        warn!("Unknown selection of plugin ({}): {}", state.name, selection);
+
+        return Ok(false);
+    };
+
+    // Selections need the global state, so set them after the plugin returns
+    state.selection_requests.push((kind, text));
+
+    Ok(true)
+});
+
+host_fn!(get_theme(user_data: PluginState;) -> String {
+    let state = user_data.get()?;
+    let state = state.lock().unwrap();
+
+    Ok(serde_json::to_string(&state.context.theme)?)
+});
+
+host_fn!(get_config_value(user_data: PluginState; key: String) -> String {
+    let state = user_data.get()?;
+    let state = state.lock().unwrap();
+
+    let value = lookup_config_value(&state.config_values, key.trim());
+
+    debug!("{}: plugin={}, key={}, value={}", function_name!(), state.name, key, value);
+
+    Ok(value)
+});
+
+host_fn!(get_keyboard_layout(user_data: PluginState;) -> String {
+    let state = user_data.get()?;
+    let state = state.lock().unwrap();
+
+    Ok(serde_json::to_string(&state.context.keyboard_layout)?)
+});
+
+host_fn!(set_keyboard_layout(user_data: PluginState; index: String) -> bool {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    let nlayouts = state.context.keyboard_layout.as_ref()
+        .map_or(0, |layout| layout.available.len());
+
+    let Some(index) = index.trim().parse::<u32>().ok().filter(|idx| (*idx as usize) < nlayouts) else {
+        warn!("Invalid keyboard layout of plugin ({}): {}", state.name, index);
+
+        return Ok(false);
+    };
+
+    // Switch after the plugin returns like other commands
+    let position = state.context.panel.map_or((0, 0), |panel| (panel.x, panel.y));
+
+    state.commands.push((GrabFlags::KEYBOARD_LAYOUT, GrabAction::Index(index), position));
+
+    Ok(true)
+});
+
+host_fn!(get_panel_geometry(user_data: PluginState;) -> String {
+    let state = user_data.get()?;
+    let state = state.lock().unwrap();
+
+    Ok(serde_json::to_string(&state.context.panel)?)
+});
+
+host_fn!(get_battery_status(user_data: PluginState; battery_name: String) -> String {
+    let state = user_data.get()?;
+    let state = state.lock().unwrap();
+
+    state.cache.lock().unwrap().get_or_insert_with("get_battery_status", battery_name.trim(), || {
+        let status = read_battery_status(Path::new(POWER_SUPPLY_PATH), battery_name.trim());
+
+        Ok(serde_json::to_string(&status)?)
+    })
+});
+
+host_fn!(get_disk_usage(user_data: PluginState; path: String) -> String {
+    let state = user_data.get()?;
+    let state = state.lock().unwrap();
+
+    let path = match path.trim() {
+        "" => "/",
+        path => path,
+    };
+
+    state.cache.lock().unwrap().get_or_insert_with("get_disk_usage", path, || {
+        let usage = match rustix::fs::statvfs(path) {
+            Ok(stat) => calc_disk_usage(stat.f_frsize, stat.f_blocks, stat.f_bfree, stat.f_bavail),
+            Err(err) => DiskUsage {
+                error: Some(format!("Cannot read `{}`: {}", path, err)),
+                ..DiskUsage::default()
+            },
+        };
+
+        Ok(serde_json::to_string(&usage)?)
+    })
+});
+
+host_fn!(get_volume(user_data: PluginState;) -> String {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    // Hand out the last state and query the sound server in the background
+    Ok(match state.refresh_helper("get_volume", || serde_json::to_string(&read_volume()).unwrap_or_default()) {
+        Some(volume) => volume,
+        None => serde_json::to_string(&VolumeState {
+            pending: true,
+            ..VolumeState::default()
+        })?,
+    })
+});
+
+host_fn!(set_volume(user_data: PluginState; request: String) -> String {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    let request: VolumeRequest = serde_json::from_str(&request)?;
+    let name = state.name.clone();
+
+    // Each call changes the volume, so nobody waits for the result
+    let is_started = state.helper_jobs.detach(MAX_HELPER_JOBS, move || {
+        let volume = read_volume();
+
+        if volume.present {
+            if let Some(percent) = calc_volume(volume.percent, &request)
+                && let Err(err) = run_pactl(&["set-sink-volume", DEFAULT_SINK, &format!("{}%", percent)])
+            {
+                warn!("Cannot set volume of plugin ({}): {}", name, err);
+            }
+
+            if request.toggle_mute && let Err(err) = run_pactl(&["set-sink-mute", DEFAULT_SINK, "toggle"]) {
+                warn!("Cannot toggle mute of plugin ({}): {}", name, err);
+            }
+        }
+
+        String::new()
+    });
+
+    // The plugin is run again once done and the next get_volume refreshes the state
+    state.outdate_helper("get_volume");
+
+    let volume = state.helper_values.get("get_volume")
+        .and_then(|(_, volume)| serde_json::from_str::<VolumeState>(volume).ok())
+        .unwrap_or_default();
+
+    Ok(serde_json::to_string(&VolumeState {
+        error: (!is_started).then(|| "Too many pending helpers".into()).or(volume.error),
+        pending: is_started,
+        ..volume
+    })?)
+});
+
+host_fn!(get_brightness(user_data: PluginState; device: String) -> String {
+    let state = user_data.get()?;
+    let state = state.lock().unwrap();
+
+    state.cache.lock().unwrap().get_or_insert_with("get_brightness", device.trim(), || {
+        Ok(serde_json::to_string(&read_brightness(Path::new(BACKLIGHT_PATH), device.trim()))?)
+    })
+});
+
+host_fn!(set_brightness(user_data: PluginState; request: String) -> String {
+    let state = user_data.get()?;
+    let state = state.lock().unwrap();
+
+    let request: BrightnessRequest = serde_json::from_str(&request)?;
+
+    state.cache.lock().unwrap().invalidate("get_brightness");
+
+    let status = write_brightness(Path::new(BACKLIGHT_PATH), &request);
+
+    if let Some(err) = status.error.as_ref() {
+        warn!("Cannot set brightness ({}): {}", state.name, err);
+    }
+
+    // Return the resulting state to allow a rerender right away
+    Ok(serde_json::to_string(&status)?)
+});
+
+host_fn!(get_mail_count(user_data: PluginState; paths: String) -> String {
+    let state = user_data.get()?;
+    let state = state.lock().unwrap();
+
+    state.cache.lock().unwrap().get_or_insert_with("get_mail_count", paths.trim(), || {
+        let mail_count = count_mail(&parse_mail_paths(&paths));
+
+        for account in mail_count.accounts.iter().filter(|account| !account.present) {
+            warn!("Cannot find Maildir of plugin ({}): {}", state.name, account.path);
+        }
+
+        Ok(serde_json::to_string(&mail_count)?)
+    })
+});
+
+host_fn!(get_temperature(user_data: PluginState; sensor: String) -> String {
+    let state = user_data.get()?;
+    let state = state.lock().unwrap();
+
+    state.cache.lock().unwrap().get_or_insert_with("get_temperature", sensor.trim(), || {
+        let temperatures = read_temperatures(Path::new(HWMON_PATH));
+
+        // Return either all sensors or the first match of label or chip
+        Ok(match sensor.trim() {
+            "" => serde_json::to_string(&temperatures)?,
+            sensor => serde_json::to_string(&temperatures.iter()
+                .find(|temp| temp.label.eq_ignore_ascii_case(sensor) || temp.chip.eq_ignore_ascii_case(sensor)))?,
+        })
+    })
+});
+
+host_fn!(get_media_status(user_data: PluginState;) -> String {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    // Hand out the last status and query the players in the background
+    Ok(match state.refresh_helper("get_media_status", || serde_json::to_string(&read_media_status()).unwrap_or_default()) {
+        Some(status) => status,
+        None => serde_json::to_string(&MediaStatus {
+            pending: true,
+            ..MediaStatus::default()
+        })?,
+    })
+});
+
+host_fn!(media_control(user_data: PluginState; control: String) -> bool {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    let control: MediaControl = serde_json::from_str(&control)?;
+
+    let command = match control.action.as_str() {
+        "play_pause" => "play-pause",
+        "next" => "next",
+        "previous" => "previous",
+        _ => {
+            warn!("Unknown media action `{}`", control.action);
+
+            return Ok(false);
+        },
+    };
+
+    let name = state.name.clone();
+
+    // Each call controls the player, so nobody waits for the result
+    let is_started = state.helper_jobs.detach(MAX_HELPER_JOBS, move || {
+        // Control the same player the status is shown for
+        if let Some(player) = read_media_status().player
+            && let Err(err) = run_playerctl(&[&format!("--player={}", player), command])
+        {
+            warn!("Cannot control media player of plugin ({}): {}", name, err);
+        }
+
+        String::new()
+    });
+
+    // The plugin is run again once done and the next get_media_status refreshes the status
+    state.outdate_helper("get_media_status");
+
+    Ok(is_started)
+});
+
+host_fn!(get_cpu_usage(user_data: PluginState;) -> String {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    // Share the counters within the cycle, but calculate the delta against the own sample
+    let stat = state.cache.lock().unwrap().get_or_insert_with("read_proc_stat", "", || {
+        Ok(std::fs::read_to_string("/proc/stat")?)
+    })?;
+
+    let cpu_times = parse_cpu_times(&stat);
+    let usage = calc_cpu_usage(&state.cpu_times, &cpu_times);
+
+    state.cpu_times = cpu_times;
+
+    Ok(serde_json::to_string(&usage)?)
+});
+
+host_fn!(get_process_info(user_data: PluginState; name: String) -> String {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    let name = name.trim();
+
+    // Share the counters within the cycle, but calculate the delta against the own samples
+    let counters: Vec<ProcCounters> = serde_json::from_str(&state.cache.lock().unwrap()
+        .get_or_insert_with("scan_processes", name, || {
+            Ok(serde_json::to_string(&scan_processes(Path::new(PROC_PATH), name))?)
+        })?)?;
+
+    // Processes that aren't running just result in an empty list
+    let processes = calc_processes(Path::new(PROC_PATH), name, &counters,
+                                   &mut state.proc_samples, Instant::now());
+
+    Ok(serde_json::to_string(&processes)?)
+});
+
+host_fn!(get_network_throughput(user_data: PluginState; iface: String) -> String {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    // Fall back to the interface of the default route
+    let iface = match iface.trim() {
+        "" => std::fs::read_to_string("/proc/net/route").ok()
+            .and_then(|route| parse_default_route(&route))
+            .unwrap_or_default(),
+        iface => iface.to_string(),
+    };
+
+    // Share the counters within the cycle, but calculate the rates against the own sample
+    let counters = state.cache.lock().unwrap().get_or_insert_with("read_net_sample", &iface, || {
+        read_net_sample(Path::new(NET_PATH), &iface)
+            .map(|sample| format!("{} {}", sample.rx_bytes, sample.tx_bytes))
+            .ok_or_else(|| anyhow!("Interface not found"))
+    }).ok();
+
+    let cur_sample = counters.as_deref()
+        .and_then(|counters| counters.split_once(' '))
+        .and_then(|(rx_bytes, tx_bytes)| Some(NetSample {
+            rx_bytes: rx_bytes.parse().ok()?,
+            tx_bytes: tx_bytes.parse().ok()?,
+            time: Instant::now(),
+        }));
+
+    let throughput = match cur_sample {
+        Some(cur_sample) => {
+            let throughput = calc_net_throughput(&iface, state.net_samples.get(&iface), &cur_sample);
+
+            state.net_samples.insert(iface, cur_sample);
+
+            throughput
+        },
+        None => {
+            state.net_samples.remove(&iface);
+
+            NetThroughput {
+                iface,
+                ..NetThroughput::default()
+            }
+        },
+    };
+
+    Ok(serde_json::to_string(&throughput)?)
+});
+
+host_fn!(get_wifi_status(user_data: PluginState; iface: String) -> String {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    // Fall back to the first wireless interface
+    let iface = match iface.trim() {
+        "" => find_wireless_iface(Path::new(NET_PATH)).unwrap_or_default(),
+        iface => iface.to_string(),
+    };
+
+    let job_key = format!("get_wifi_status\n{}", iface);
+    let job_iface = iface.clone();
+
+    // Hand out the last status and ask iw in the background
+    Ok(match state.refresh_helper(&job_key, move || serde_json::to_string(&read_wifi_status(&job_iface)).unwrap_or_default()) {
+        Some(status) => status,
+        None => serde_json::to_string(&WifiStatus {
+            iface,
+            pending: true,
+            ..WifiStatus::default()
+        })?,
+    })
+});
+
+host_fn!(get_cpu(user_data: PluginState;) -> bool {
+    let state = user_data.get()?;
+    let mut state = state.lock().unwrap();
+
+    state.cpu_samples.clear();
+
+    let regex = Regex::new(r"cpu(\d+) (\d+) (\d+) (\d+)")?;
+
+    for line in std::fs::read_to_string("/proc/stat")?.lines() {
+        if let Some(cap) = regex.captures(line) {
+            let cpu_user = cap.get(1).map_or(0, |v| v.as_str().parse::<i32>().unwrap_or(0));
+            let cpu_nice = cap.get(2).map_or(0, |v| v.as_str().parse::<i32>().unwrap_or(0));
+            let cpu_system = cap.get(3).map_or(0, |v| v.as_str().parse::<i32>().unwrap_or(0));
+
+            state.cpu_samples.push((cpu_user, cpu_nice, cpu_system));
+        }
+    }
+
+   Ok(true)
+});
+
+/// Parse window manager command into the matching grab
+///
+/// # Arguments
+///
+/// * `command` - Command to parse
+/// * `view_names` - Names of all views
+/// * `allow_exec` - Whether the plugin may run commands
+///
+/// # Returns
+///
+/// A [`Result`] with either ([`GrabFlags`], [`GrabAction`]) on success or otherwise [`WmCommandError`]
+pub(crate) fn parse_wm_command(command: &WmCommand, view_names: &[String], allow_exec: bool)
+    -> Result<(GrabFlags, GrabAction), WmCommandError>
+{
+    let arg = command.arg.trim();
+
+    match command.action.as_str() {
+        "switch_view" => {
+            // Find view either by name or by index; grabs count views from one
+            let view_idx = view_names.iter().position(|name| name == arg)
+                .or_else(|| arg.parse::<usize>().ok().filter(|idx| *idx < view_names.len()))
+                .ok_or(WmCommandError::InvalidArgument)?;
+
+            Ok((GrabFlags::VIEW_SWITCH, GrabAction::Index(view_idx as u32 + 1)))
+        },
+        "spawn" if !allow_exec => Err(WmCommandError::NotAllowed),
+        "spawn" if arg.is_empty() => Err(WmCommandError::InvalidArgument),
+        "spawn" => Ok((GrabFlags::COMMAND, GrabAction::Command(arg.to_string()))),
+        "focus_next" => Ok((GrabFlags::WINDOW_CYCLE, GrabAction::Index(CycleOrder::Next as u32))),
+        "focus_prev" => Ok((GrabFlags::WINDOW_CYCLE, GrabAction::Index(CycleOrder::Prev as u32))),
+        "focus_client" => arg.parse::<u32>()
+            .map(|win| (GrabFlags::WINDOW_FOCUS, GrabAction::Index(win)))
+            .map_err(|_| WmCommandError::InvalidArgument),
+        "restart" => Ok((GrabFlags::SUBTLE_RESTART, GrabAction::None)),
+        "reload_sublet" => Ok((GrabFlags::PLUGIN_RELOAD, GrabAction::Command(arg.to_string()))),
+        _ => Err(WmCommandError::UnknownAction),
+    }
+}
+
+/// Parse log level name and fall back to info
+///
+/// # Arguments
+///
+/// * `level` - Name of the level like trace, debug, info, warn or error
+///
+/// # Returns
+///
+/// The matching [`log::Level`]
+pub(crate) fn parse_log_level(level: &str) -> log::Level {
+    match level.trim().to_lowercase().as_str() {
+        "trace" => log::Level::Trace,
+        "debug" => log::Level::Debug,
+        "warn" | "warning" => log::Level::Warn,
+        "error" => log::Level::Error,
+        _ => log::Level::Info,
+    }
+}
+
+/// Load the wasm file and register the host functions
+///
+/// # Arguments
+///
+/// * `url` - Path or file url to wasm file
+/// * `config` - Plugin config
+/// * `state` - State shared with the host functions
+///
+/// # Returns
+///
+/// A [`Result`] with either [`extism::Plugin`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn load_wasm(url: &str, config: &HashMap<String, String>, state: &UserData<PluginState>) -> Result<extism::Plugin> {
+    let wasm = Wasm::file(url);
+    let manifest = Manifest::new([wasm])
+        .with_timeout(Duration::from_secs(5))
+        .with_config(config.clone().into_iter());
+
+    let plugin = extism::PluginBuilder::new(&manifest)
+        .with_wasi(true)
+        .with_function("get_formatted_time", [PTR], [PTR],
+                       UserData::default(), get_formatted_time)
+        .with_function("format_duration", [PTR, PTR], [PTR],
+                       UserData::default(), get_formatted_duration)
+        .with_function("parse_time", [PTR], [PTR],
+                       UserData::default(), get_unix_time)
+        .with_function("get_memory", [PTR], [PTR],
+                       UserData::default(), get_memory)
+        .with_function("measure_text", [PTR], [PTR],
+                       UserData::default(), measure_text)
+        .with_function("truncate", [PTR, PTR, PTR], [PTR],
+                       UserData::default(), truncate_text)
+        .with_function("get_memory_info", [PTR], [PTR],
+                       state.clone(), get_memory_info)
+        .with_function("get_load_average", [PTR], [PTR],
+                       state.clone(), get_load_average)
+        .with_function("get_uptime", [PTR], [PTR],
+                       state.clone(), get_uptime)
+        .with_function("get_battery", [PTR], [PTR],
+                       UserData::default(), get_battery)
+        .with_function("log_message", [PTR, PTR], [],
+                       state.clone(), log_message)
+        .with_function("exec_command", [PTR], [PTR],
+                       state.clone(), exec_command)
+        .with_function("http_fetch", [PTR], [PTR],
+                       state.clone(), http_fetch)
+        .with_function("kv_get", [PTR], [PTR],
+                       state.clone(), kv_get)
+        .with_function("kv_set", [PTR, PTR], [I32],
+                       state.clone(), kv_set)
+        .with_function("send_command", [PTR], [PTR],
+                       state.clone(), send_command)
+        .with_function("notify", [PTR], [PTR],
+                       state.clone(), send_notification)
+        .with_function("notify_close", [PTR], [PTR],
+                       state.clone(), close_notification)
+        .with_function("set_tooltip", [PTR], [],
+                       state.clone(), set_tooltip)
+        .with_function("set_min_width", [PTR], [],
+                       state.clone(), set_min_width)
+        .with_function("schedule_callback", [PTR], [PTR],
+                       state.clone(), schedule_callback)
+        .with_function("cancel_callback", [PTR], [I32],
+                       state.clone(), cancel_callback)
+        .with_function("get_current_view", [PTR], [PTR],
+                       state.clone(), get_current_view)
+        .with_function("list_views", [PTR], [PTR],
+                       state.clone(), list_views)
+        .with_function("list_clients", [PTR], [PTR],
+                       state.clone(), list_clients)
+        .with_function("get_theme", [PTR], [PTR],
+                       state.clone(), get_theme)
+        .with_function("get_pointer", [PTR], [PTR],
+                       state.clone(), get_pointer)
+        .with_function("get_clipboard", [PTR], [PTR],
+                       state.clone(), get_clipboard)
+        .with_function("set_clipboard", [PTR, PTR], [I32],
+                       state.clone(), set_clipboard)
+        .with_function("get_config_value", [PTR], [PTR],
+                       state.clone(), get_config_value)
+        .with_function("get_keyboard_layout", [PTR], [PTR],
+                       state.clone(), get_keyboard_layout)
+        .with_function("set_keyboard_layout", [PTR], [I32],
+                       state.clone(), set_keyboard_layout)
+        .with_function("get_panel_geometry", [PTR], [PTR],
+                       state.clone(), get_panel_geometry)
+        .with_function("get_battery_status", [PTR], [PTR],
+                       state.clone(), get_battery_status)
+        .with_function("get_disk_usage", [PTR], [PTR],
+                       state.clone(), get_disk_usage)
+        .with_function("get_volume", [PTR], [PTR],
+                       state.clone(), get_volume)
+        .with_function("set_volume", [PTR], [PTR],
+                       state.clone(), set_volume)
+        .with_function("get_brightness", [PTR], [PTR],
+                       state.clone(), get_brightness)
+        .with_function("set_brightness", [PTR], [PTR],
+                       state.clone(), set_brightness)
+        .with_function("get_mail_count", [PTR], [PTR],
+                       state.clone(), get_mail_count)
+        .with_function("get_temperature", [PTR], [PTR],
+                       state.clone(), get_temperature)
+        .with_function("get_media_status", [PTR], [PTR],
+                       state.clone(), get_media_status)
+        .with_function("media_control", [PTR], [I32],
+                       state.clone(), media_control)
+        .with_function("get_cpu_usage", [PTR], [PTR],
+                       state.clone(), get_cpu_usage)
+        .with_function("get_process_info", [PTR], [PTR],
+                       state.clone(), get_process_info)
+        .with_function("get_network_throughput", [PTR], [PTR],
+                       state.clone(), get_network_throughput)
+        .with_function("get_wifi_status", [PTR], [PTR],
+                       state.clone(), get_wifi_status)
+        .with_function("get_cpu", [PTR], [I32],
+                       state.clone(), get_cpu)
+        .build()?;
+
+    debug!("{}: url={}", function_name!(), url);
+
+    Ok(plugin)
+}
@@ -0,0 +1,259 @@
+//!
+//! @package subtle-rs
+//!
+//! @file Plugin http functions
+//! @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+//! @version $Id$
+//!
+//! This program can be distributed under the terms of the GNU GPLv3.
+//! See the file LICENSE for details.
+//!
+
+use std::collections::HashMap;
+use std::io::Read;
+use std::time::{Duration, Instant};
+use anyhow::Result;
+use log::debug;
+use stdext::function_name;
+use serde::{Deserialize, Serialize};
+use ureq::Agent;
+use ureq::http::{Request, Uri};
+
+/// Default timeout of http requests in milliseconds
+const DEFAULT_HTTP_TIMEOUT: u64 = 3000;
+
+/// Maximum timeout of http requests in milliseconds
+const MAX_HTTP_TIMEOUT: u64 = 4000;
+
+/// Maximum size of http response bodies in bytes
+const MAX_HTTP_BODY_SIZE: u64 = 256 * 1024;
+
+/// Maximum number of retries of failed http requests
+const MAX_HTTP_RETRIES: u32 = 3;
+
+/// Default delay before the first retry of http requests in milliseconds
+const DEFAULT_HTTP_RETRY_BACKOFF: u64 = 250;
+
+/// Maximum time of http requests including all retries
+const MAX_HTTP_TOTAL_TIME: Duration = Duration::from_millis(MAX_HTTP_TIMEOUT);
+
+/// Maximum number of cached http responses of each plugin
+pub(crate) const MAX_HTTP_CACHE_ENTRIES: usize = 16;
+
+/// Maximum number of http requests of each plugin running in the background
+pub(crate) const MAX_HTTP_JOBS: usize = 4;
+
+#[derive(Debug, Clone, Deserialize)]
+pub(crate) struct HttpRequest {
+    /// Http method
+    #[serde(default = "default_http_method")]
+    pub(crate) method: String,
+    /// Url to fetch
+    pub(crate) url: String,
+    /// Request headers
+    #[serde(default)]
+    pub(crate) headers: HashMap<String, String>,
+    /// Request body
+    #[serde(default)]
+    pub(crate) body: String,
+    /// Timeout in milliseconds
+    pub(crate) timeout_ms: Option<u64>,
+    /// Number of retries of transient failures
+    #[serde(default)]
+    pub(crate) retries: u32,
+    /// Delay before the first retry in milliseconds; doubles with each retry
+    pub(crate) retry_backoff_ms: Option<u64>,
+    /// Time in milliseconds a good response is served when later requests fail
+    pub(crate) cache_ttl_ms: Option<u64>,
+}
+
+#[derive(Default, Debug, Clone, Serialize)]
+pub(crate) struct HttpResponse {
+    /// Http status or zero when the request failed
+    pub(crate) status: u16,
+    /// Response headers
+    pub(crate) headers: HashMap<String, String>,
+    /// Response body
+    pub(crate) body: String,
+    /// Whether the body has been cut at the size limit
+    pub(crate) truncated: bool,
+    /// Error message when the request failed
+    pub(crate) error: Option<String>,
+    /// Number of attempts made
+    pub(crate) attempts: u32,
+    /// Whether this is a cached response served in place of a failed request
+    pub(crate) cached: bool,
+    /// Whether the request is still running in the background
+    pub(crate) pending: bool,
+}
+
+/// Default method of http requests
+///
+/// # Returns
+///
+/// A [`String`] with the method
+fn default_http_method() -> String {
+    "GET".into()
+}
+
+/// Check whether the host of the url is in the list of allowed hosts
+///
+/// # Arguments
+///
+/// * `allowed_hosts` - List of hosts; entries like `*.example.com` also match subdomains
+/// * `url` - Url to check
+///
+/// # Returns
+///
+/// Either [`true`] if the host is allowed or otherwise [`false`]
+pub(crate) fn is_host_allowed(allowed_hosts: &[String], url: &str) -> bool {
+    let Ok(uri) = url.parse::<Uri>() else {
+        return false;
+    };
+
+    if !matches!(uri.scheme_str(), Some("http" | "https")) {
+        return false;
+    }
+
+    let Some(host) = uri.host().map(|host| host.to_ascii_lowercase()) else {
+        return false;
+    };
+
+    allowed_hosts.iter()
+        .map(|allowed_host| allowed_host.to_ascii_lowercase())
+        .any(|allowed_host| match allowed_host.strip_prefix("*.") {
+            Some(domain) => host == domain || host.ends_with(&format!(".{}", domain)),
+            None => host == allowed_host,
+        })
+}
+
+/// Check whether a response is a transient failure worth a retry
+///
+/// # Arguments
+///
+/// * `response` - Response to check
+///
+/// # Returns
+///
+/// Either [`true`] if the request failed or the server has an error or otherwise [`false`]
+pub(crate) fn is_transient_failure(response: &HttpResponse) -> bool {
+    response.error.is_some() || 500 <= response.status
+}
+
+/// Calculate the delay before the next retry of a http request
+///
+/// # Arguments
+///
+/// * `backoff` - Delay before the first retry
+/// * `attempt` - Number of attempts made so far
+/// * `remaining` - Remaining time of the request
+///
+/// # Returns
+///
+/// Either [`Some`] with the delay or otherwise [`None`] when no time is left for another attempt
+pub(crate) fn calc_retry_delay(backoff: Duration, attempt: u32, remaining: Duration) -> Option<Duration> {
+    let delay = backoff.saturating_mul(1 << attempt.saturating_sub(1).min(16));
+
+    (delay < remaining).then_some(delay)
+}
+
+/// Send http request and retry transient failures within the total time limit; this
+/// blocks and must run in a background [`Job`]
+///
+/// # Arguments
+///
+/// * `request` - Request to send
+///
+/// # Returns
+///
+/// A [`HttpResponse`] of the last attempt
+pub(crate) fn fetch_with_retries(request: &HttpRequest) -> HttpResponse {
+    let deadline = Instant::now() + MAX_HTTP_TOTAL_TIME;
+    let timeout = Duration::from_millis(request.timeout_ms
+        .unwrap_or(DEFAULT_HTTP_TIMEOUT).min(MAX_HTTP_TIMEOUT));
+    let backoff = Duration::from_millis(request.retry_backoff_ms.unwrap_or(DEFAULT_HTTP_RETRY_BACKOFF));
+    let mut attempts = 0;
+
+    loop {
+        attempts += 1;
+
+        let mut response = fetch_url(request, timeout.min(deadline.saturating_duration_since(Instant::now())))
+            .unwrap_or_else(|err| HttpResponse {
+                error: Some(err.to_string()),
+                ..HttpResponse::default()
+            });
+
+        response.attempts = attempts;
+
+        if !is_transient_failure(&response) || attempts > request.retries.min(MAX_HTTP_RETRIES) {
+            return response;
+        }
+
+        debug!("{}: url={}, attempts={}, status={}, error={:?}", function_name!(),
+            request.url, attempts, response.status, response.error);
+
+        match calc_retry_delay(backoff, attempts, deadline.saturating_duration_since(Instant::now())) {
+            Some(delay) => std::thread::sleep(delay),
+            None => return response,
+        }
+    }
+}
+
+/// Send http request and collect the response
+///
+/// # Arguments
+///
+/// * `request` - Request to send
+/// * `timeout` - Timeout of the request
+///
+/// # Returns
+///
+/// A [`Result`] with either [`HttpResponse`] on success or otherwise [`anyhow::Error`]
+fn fetch_url(request: &HttpRequest, timeout: Duration) -> Result<HttpResponse> {
+    // Pass error status to the plugin and never follow redirects to other hosts
+    let agent: Agent = Agent::config_builder()
+        .timeout_global(Some(timeout))
+        .http_status_as_error(false)
+        .max_redirects(0)
+        .build()
+        .into();
+
+    let mut builder = Request::builder()
+        .method(request.method.to_ascii_uppercase().as_str())
+        .uri(&request.url);
+
+    for (key, value) in request.headers.iter() {
+        builder = builder.header(key, value);
+    }
+
+    let mut response = if request.body.is_empty() {
+        agent.run(builder.body(())?)?
+    } else {
+        agent.run(builder.body(request.body.clone())?)?
+    };
+
+    let headers = response.headers().iter()
+        .filter_map(|(key, value)| value.to_str().ok()
+            .map(|value| (key.to_string(), value.to_string())))
+        .collect();
+
+    // Read one byte more than allowed to detect truncation
+    let mut body = Vec::new();
+
+    response.body_mut().as_reader()
+        .take(MAX_HTTP_BODY_SIZE + 1)
+        .read_to_end(&mut body)?;
+
+    let truncated = MAX_HTTP_BODY_SIZE < body.len() as u64;
+
+    body.truncate(MAX_HTTP_BODY_SIZE as usize);
+
+    Ok(HttpResponse {
+        status: response.status().as_u16(),
+        headers,
+        body: String::from_utf8_lossy(&body).into_owned(),
+        truncated,
+        error: None,
+        ..HttpResponse::default()
+    })
+}
@@ -0,0 +1,268 @@
+//!
+//! @package subtle-rs
+//!
+//! @file Plugin background job functions
+//! @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+//! @version $Id$
+//!
+//! This program can be distributed under the terms of the GNU GPLv3.
+//! See the file LICENSE for details.
+//!
+
+use std::collections::HashMap;
+use std::sync::mpsc;
+use std::sync::mpsc::{Receiver, RecvTimeoutError, TryRecvError};
+use std::time::{Duration, Instant};
+
+/// Interval to check for finished background jobs
+pub(crate) const JOB_POLL_INTERVAL: Duration = Duration::from_millis(50);
+
+/// Time to keep results of background jobs nobody has collected
+const JOB_RESULT_TTL: Duration = Duration::from_secs(30);
+
+#[derive(Debug)]
+pub(crate) enum Job<T> {
+    /// Job is still running in the background
+    Running(Receiver<T>),
+    /// Job has finished with a result
+    Done(T),
+    /// Job has died without result
+    Failed,
+}
+
+impl<T: Send + 'static> Job<T> {
+    /// Run a call in a separate thread
+    ///
+    /// # Arguments
+    ///
+    /// * `call` - Call to run
+    ///
+    /// # Returns
+    ///
+    /// A running [`Job`]
+    pub(crate) fn spawn<F>(call: F) -> Self
+        where F: FnOnce() -> T + Send + 'static
+    {
+        let (sender, receiver) = mpsc::channel();
+
+        std::thread::spawn(move || {
+            let _ = sender.send(call());
+        });
+
+        Job::Running(receiver)
+    }
+
+    /// Check whether the job has finished without blocking
+    ///
+    /// # Returns
+    ///
+    /// Either [`true`] when the job has just finished or otherwise [`false`]
+    pub(crate) fn poll(&mut self) -> bool {
+        let Job::Running(receiver) = self else {
+            return false;
+        };
+
+        match receiver.try_recv() {
+            Ok(result) => *self = Job::Done(result),
+            Err(TryRecvError::Disconnected) => *self = Job::Failed,
+            Err(TryRecvError::Empty) => return false,
+        }
+
+        true
+    }
+
+    /// Wait for the job to finish, but at most for the given time
+    ///
+    /// # Arguments
+    ///
+    /// * `timeout` - Maximum time to wait
+    ///
+    /// # Returns
+    ///
+    /// Either [`true`] when the job has just finished or otherwise [`false`]
+    pub(crate) fn wait(&mut self, timeout: Duration) -> bool {
+        let Job::Running(receiver) = self else {
+            return false;
+        };
+
+        match receiver.recv_timeout(timeout) {
+            Ok(result) => *self = Job::Done(result),
+            Err(RecvTimeoutError::Disconnected) => *self = Job::Failed,
+            Err(RecvTimeoutError::Timeout) => return false,
+        }
+
+        true
+    }
+}
+
+#[derive(Debug, PartialEq)]
+pub(crate) enum JobStatus<T> {
+    /// Job is still running; the result is handed over on a later call
+    Pending,
+    /// Job has finished with a result
+    Done(T),
+    /// Job has died without result
+    Failed,
+    /// Too many jobs are running already
+    Busy,
+}
+
+#[derive(Debug)]
+pub(crate) struct Jobs<T> {
+    /// Jobs by key with the time they have finished
+    pub(crate) entries: HashMap<String, (Job<T>, Option<Instant>)>,
+    /// Results handed over during the current call of the plugin, so runs for other panels get them too
+    pub(crate) handed_over: HashMap<String, T>,
+    /// Id of the last job nobody waits for
+    pub(crate) last_detached_id: u64,
+}
+
+impl<T> Default for Jobs<T> {
+    fn default() -> Self {
+        Self {
+            entries: HashMap::new(),
+            handed_over: HashMap::new(),
+            last_detached_id: 0,
+        }
+    }
+}
+
+impl<T: Clone + Send + 'static> Jobs<T> {
+    /// Hand over the result of a finished job once or otherwise start it
+    ///
+    /// # Arguments
+    ///
+    /// * `key` - Key of the job
+    /// * `limit` - Maximum number of running jobs
+    /// * `call` - Call to run when no job is known for the key
+    ///
+    /// # Returns
+    ///
+    /// The [`JobStatus`] of the job
+    pub(crate) fn run<F>(&mut self, key: &str, limit: usize, call: F) -> JobStatus<T>
+        where F: FnOnce() -> T + Send + 'static
+    {
+        self.run_waiting(key, limit, Duration::ZERO, call)
+    }
+
+    /// Hand over the result of a finished job once or otherwise start it and wait a bit for it
+    ///
+    /// # Arguments
+    ///
+    /// * `key` - Key of the job
+    /// * `limit` - Maximum number of running jobs
+    /// * `wait` - Maximum time to wait for a new job
+    /// * `call` - Call to run when no job is known for the key
+    ///
+    /// # Returns
+    ///
+    /// The [`JobStatus`] of the job
+    pub(crate) fn run_waiting<F>(&mut self, key: &str, limit: usize, wait: Duration, call: F) -> JobStatus<T>
+        where F: FnOnce() -> T + Send + 'static
+    {
+        if let Some(result) = self.handed_over.get(key) {
+            return JobStatus::Done(result.clone());
+        }
+
+        let mut job = match self.entries.remove(key) {
+            Some((job, _)) => job,
+            None if limit <= self.running() => return JobStatus::Busy,
+            None => {
+                let mut job = Job::spawn(call);
+
+                // Short jobs finish right away like a plain call
+                if !wait.is_zero() {
+                    job.wait(wait);
+                }
+
+                job
+            },
+        };
+
+        job.poll();
+
+        // Results are handed over only to the current call, so the next one runs again
+        match job {
+            Job::Running(receiver) => {
+                self.entries.insert(key.to_string(), (Job::Running(receiver), None));
+
+                JobStatus::Pending
+            },
+            Job::Done(result) => {
+                self.handed_over.insert(key.to_string(), result.clone());
+
+                JobStatus::Done(result)
+            },
+            Job::Failed => JobStatus::Failed,
+        }
+    }
+
+    /// Start a job nobody waits for, so each call runs it again
+    ///
+    /// # Arguments
+    ///
+    /// * `limit` - Maximum number of running jobs
+    /// * `call` - Call to run
+    ///
+    /// # Returns
+    ///
+    /// Either [`true`] when the job has been started or otherwise [`false`] when too many are running
+    pub(crate) fn detach<F>(&mut self, limit: usize, call: F) -> bool
+        where F: FnOnce() -> T + Send + 'static
+    {
+        if limit <= self.running() {
+            return false;
+        }
+
+        // Keys of detached jobs start with a tab and cannot clash with function names
+        self.last_detached_id += 1;
+        self.entries.insert(format!("\t{}", self.last_detached_id), (Job::spawn(call), None));
+
+        true
+    }
+
+    /// Check the running jobs and drop results nobody has collected in time
+    ///
+    /// # Arguments
+    ///
+    /// * `now` - Current time
+    ///
+    /// # Returns
+    ///
+    /// Either [`true`] when any job has just finished or otherwise [`false`]
+    pub(crate) fn poll(&mut self, now: Instant) -> bool {
+        let mut has_finished = false;
+
+        for (job, finished_at) in self.entries.values_mut() {
+            if job.poll() {
+                *finished_at = Some(now);
+                has_finished = true;
+            }
+        }
+
+        self.entries.retain(|_, (_, finished_at)| finished_at
+            .is_none_or(|finished_at| now.saturating_duration_since(finished_at) < JOB_RESULT_TTL));
+
+        has_finished
+    }
+
+    /// Drop the results of finished jobs once the plugin had its chance to collect them
+    pub(crate) fn drop_finished(&mut self) {
+        self.entries.retain(|_, (job, _)| matches!(job, Job::Running(_)));
+        self.handed_over.clear();
+    }
+
+    /// Forget the results handed over to the last call of the plugin
+    pub(crate) fn drop_handed_over(&mut self) {
+        self.handed_over.clear();
+    }
+
+    /// Count the jobs that are still running
+    ///
+    /// # Returns
+    ///
+    /// The number of running jobs
+    pub(crate) fn running(&self) -> usize {
+        self.entries.values().filter(|(job, _)| matches!(job, Job::Running(_))).count()
+    }
+}
@@ -0,0 +1,2450 @@
+//!
+//! @package subtle-rs
+//!
+//! @file Plugin functions
+//! @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+//! @version $Id$
+//!
+//! This program can be distributed under the terms of the GNU GPLv3.
+//! See the file LICENSE for details.
+//!
+
+use std::fmt;
+use std::cell::{Cell, RefCell};
+use std::collections::{HashMap, HashSet};
+use std::path::PathBuf;
+use std::rc::Rc;
+use std::sync::{Arc, Mutex};
+use std::time::{Duration, Instant};
+use extism::UserData;
+use anyhow::{anyhow, Context, Result};
+use bitflags::bitflags;
+use derive_builder::Builder;
+use log::{debug, info, warn};
+use stdext::function_name;
+use serde::{Deserialize, Serialize};
+use x11rb::connection::Connection;
+use x11rb::protocol::xproto::{ConnectionExt, Rectangle};
+use x11rb::NONE;
+use crate::client::ClientFlags;
+use crate::config::{Config, MixedConfigVal};
+use crate::event;
+use crate::keyboard;
+use crate::keyboard::KeyboardLayout;
+use crate::selection;
+use crate::selection::{SelectionContent, SelectionKind};
+use crate::grab::{GrabAction, GrabFlags};
+use crate::markup;
+use crate::panel::PanelFlags;
+use crate::screen;
+use crate::style;
+use crate::subtle::Subtle;
+use crate::tagging::Tagging;
+use crate::view::View;
+
+/// Background job module
+mod job;
+/// Command module
+mod command;
+/// Http module
+mod http;
+/// Notification module
+mod notification;
+/// Time module
+mod time;
+/// System information module
+mod sys;
+/// Host function module
+mod host;
+
+pub(crate) use self::job::*;
+pub(crate) use self::command::*;
+pub(crate) use self::http::*;
+pub(crate) use self::notification::*;
+pub(crate) use self::time::*;
+pub(crate) use self::sys::*;
+pub(crate) use self::host::*;
+
+/// Default update interval in seconds
+const DEFAULT_INTERVAL: i32 = 60;
+
+/// Minimum time between two event-triggered runs
+const EVENT_DEBOUNCE: Duration = Duration::from_millis(250);
+
+/// Maximum size of the key/value store of each plugin in bytes
+const MAX_KV_SIZE: usize = 64 * 1024;
+
+/// Maximum length of client titles in chars
+const MAX_TITLE_LENGTH: usize = 256;
+
+/// Text shown in place of the output of failed runs
+const DEFAULT_ERROR_TEXT: &str = "!";
+
+/// Error code extism reports for traps, timeouts and failed host calls
+const HOST_ERROR_CODE: i32 = -1;
+
+/// Minimum delay between retries of failed runs
+const MIN_BACKOFF: Duration = Duration::from_secs(1);
+
+/// Maximum delay between retries of failed runs unless the interval is longer
+const MAX_BACKOFF: Duration = Duration::from_secs(300);
+
+/// Latest supported version of the run output envelope
+const OUTPUT_VERSION: u32 = 1;
+
+/// Global config values plugins may read
+const CONFIG_KEYS: [&str; 5] = ["locale", "units", "date_format", "time_format", "panel.separator"];
+
+bitflags! {
+    /// Config and state-flags for [`Plugin`]
+    #[derive(Default, Debug, Copy, Clone, PartialEq)]
+    pub(crate) struct PluginFlags: u32 {
+        /// Plugin exports on_click
+        const ON_CLICK = 1 << 0;
+        /// Plugin exports on_scroll
+        const ON_SCROLL = 1 << 1;
+        /// Plugin may run click actions of the output
+        const CLICK_ACTIONS = 1 << 2;
+        /// Plugin may access the selections
+        const CLIPBOARD = 1 << 3;
+        /// Plugin exports on_timer
+        const ON_TIMER = 1 << 4;
+    }
+}
+
+bitflags! {
+    /// Event kinds a [`Plugin`] can subscribe to
+    #[derive(Default, Debug, Copy, Clone, PartialEq)]
+    pub(crate) struct PluginEvents: u32 {
+        /// Focus has changed
+        const FOCUS_CHANGE = 1 << 0;
+        /// View has changed
+        const VIEW_CHANGE = 1 << 1;
+        /// Client has been added
+        const CLIENT_ADD = 1 << 2;
+        /// Client has been removed
+        const CLIENT_REMOVE = 1 << 3;
+    }
+}
+
+#[derive(Debug)]
+pub(crate) struct Plugin {
+    /// Config and state-flags
+    pub(crate) flags: Cell<PluginFlags>,
+    /// Name of the plugin
+    pub(crate) name: String,
+    /// Path or file url to wasm file
+    pub(crate) url: String,
+    /// Plugin config
+    pub(crate) config: HashMap<String, String>,
+    /// Update interval; zero means on demand only
+    pub(crate) interval: Cell<Duration>,
+    /// Update interval of the config used when the plugin exports none
+    pub(crate) config_interval: Duration,
+    /// Subscribed events
+    pub(crate) subscriptions: Cell<PluginEvents>,
+    /// Time of the last run
+    pub(crate) last_run: Cell<Option<Instant>>,
+    /// Time of the next scheduled update
+    pub(crate) next_update: Cell<Option<Instant>>,
+    /// Output of the last run per panel as screen index and bottom flag
+    pub(crate) outputs: RefCell<HashMap<(usize, bool), RunOutput>>,
+    /// Default alignment of the text inside of the minimum width
+    pub(crate) align: TextAlign,
+    /// Side and position of the panel items
+    pub(crate) placement: Cell<Placement>,
+    /// Side and position of the config used when the plugin exports none
+    pub(crate) config_placement: Placement,
+    /// Text shown in place of the output of failed runs
+    pub(crate) error_text: String,
+    /// Whether to keep the last output with the error text as marker on failed runs
+    pub(crate) keep_on_error: bool,
+    /// Number of consecutive failed runs
+    pub(crate) failures: Cell<u32>,
+    /// Earliest time of the next run after failed runs
+    pub(crate) retry_at: Cell<Option<Instant>>,
+    /// State shared with the host functions
+    pub(crate) state: Arc<Mutex<PluginState>>,
+    /// Extism plugin
+    pub(crate) plugin: Rc<RefCell<extism::Plugin>>,
+}
+
+#[derive(Builder)]
+#[builder(name = "PluginBuilder", build_fn(skip))]
+pub(crate) struct PluginBuilderSeed {
+    /// Name of the plugin
+    pub(crate) name: String,
+    /// Path or file url to wasm file
+    url: String,
+    /// Update interval in seconds
+    pub(crate) interval: i32,
+    /// Plugin config
+    pub(crate) config: HashMap<String, String>,
+    /// Whether the plugin may run commands
+    pub(crate) allow_exec: bool,
+    /// Hosts the plugin may send http requests to
+    pub(crate) allowed_hosts: Vec<String>,
+    /// Default alignment of the text inside of the minimum width
+    pub(crate) align: TextAlign,
+    /// Side of the panel items
+    pub(crate) side: PanelSide,
+    /// Position of the panel items inside of their side
+    pub(crate) position: i32,
+    /// Text shown in place of the output of failed runs
+    pub(crate) error_text: String,
+    /// Whether to keep the last output with the error text as marker on failed runs
+    pub(crate) keep_on_error: bool,
+    /// Cache of host calls shared by all plugins
+    pub(crate) cache: Arc<Mutex<HostCache>>,
+    /// Whitelisted global config values
+    pub(crate) config_values: HashMap<String, String>,
+}
+
+#[derive(Default, Debug, Copy, Clone, PartialEq, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub(crate) enum TextAlign {
+    /// Align text on the left
+    #[default]
+    Left,
+    /// Center text
+    Center,
+    /// Align text on the right
+    Right,
+}
+
+#[derive(Debug, Copy, Clone, PartialEq, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub(crate) enum PanelSide {
+    /// Arrange items on the left side
+    Left,
+    /// Arrange items in the center
+    Center,
+    /// Arrange items on the right side
+    Right,
+}
+
+impl PanelSide {
+    /// Get the positional flags of panel items on this side
+    ///
+    /// # Returns
+    ///
+    /// A [`PanelFlags`] with the positional flag
+    pub(crate) fn to_panel_flags(self) -> PanelFlags {
+        match self {
+            PanelSide::Left => PanelFlags::LEFT_POS,
+            PanelSide::Center => PanelFlags::CENTER_POS,
+            PanelSide::Right => PanelFlags::RIGHT_POS,
+        }
+    }
+}
+
+#[derive(Default, Debug, Copy, Clone, PartialEq, Deserialize)]
+pub(crate) struct Placement {
+    /// Side of the panel items; otherwise the prefix of the panel item is used
+    pub(crate) side: Option<PanelSide>,
+    /// Position inside of the side; items with higher positions are placed further right
+    pub(crate) position: Option<i32>,
+}
+
+#[derive(Debug, Clone, PartialEq, Deserialize)]
+pub(crate) struct RunOutput {
+    /// Version of the envelope; zero for plain output
+    pub(crate) version: u32,
+    /// Text to display
+    pub(crate) text: String,
+    /// Whether the text contains markup
+    #[serde(default = "default_markup")]
+    pub(crate) markup: bool,
+    /// Minimum width in cells of the font like set_min_width
+    #[serde(default)]
+    pub(crate) min_width: u16,
+    /// Alignment of the text when the minimum width exceeds it; unset to use the plugin default
+    #[serde(default)]
+    pub(crate) align: Option<TextAlign>,
+    /// Commands to run on click per mouse button instead of calling on_click
+    #[serde(default)]
+    pub(crate) click_actions: HashMap<u8, WmCommand>,
+    /// Whether this output replaces the output of a failed run
+    #[serde(skip)]
+    pub(crate) failed: bool,
+}
+
+#[derive(Debug, Serialize)]
+pub(crate) struct TimerEvent {
+    /// Id of the timer returned by schedule_callback
+    pub(crate) id: u32,
+}
+
+#[derive(Debug, Serialize)]
+pub(crate) struct ClickEvent {
+    /// Mouse button (1=left, 2=middle, 3=right)
+    pub(crate) button: u8,
+    /// X offset of the click within the plugin panel
+    pub(crate) x: i16,
+}
+
+#[derive(Debug, Copy, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "lowercase")]
+pub(crate) enum ScrollDirection {
+    /// Wheel turned up
+    Up,
+    /// Wheel turned down
+    Down,
+}
+
+impl ScrollDirection {
+    /// Get scroll direction of a mouse button
+    ///
+    /// # Arguments
+    ///
+    /// * `button` - Mouse button
+    ///
+    /// # Returns
+    ///
+    /// Either [`Some`] with the direction for wheel buttons or otherwise [`None`]
+    pub(crate) fn from_button(button: u8) -> Option<Self> {
+        match button {
+            4 => Some(Self::Up),
+            5 => Some(Self::Down),
+            _ => None,
+        }
+    }
+}
+
+#[derive(Debug, Serialize)]
+pub(crate) struct ScrollEvent {
+    /// Direction of the wheel
+    pub(crate) direction: ScrollDirection,
+    /// Number of wheel steps
+    pub(crate) delta: u32,
+}
+
+#[derive(Default, Debug)]
+pub(crate) struct KvStore {
+    /// Path of the backing file
+    pub(crate) path: Option<PathBuf>,
+    /// Stored values
+    pub(crate) values: HashMap<String, String>,
+}
+
+impl KvStore {
+    /// Load store from file or start empty
+    ///
+    /// # Arguments
+    ///
+    /// * `path` - Path of the backing file
+    ///
+    /// # Returns
+    ///
+    /// A new [`KvStore`]
+    pub(crate) fn load(path: PathBuf) -> Self {
+        let values = match std::fs::read_to_string(&path) {
+            Ok(content) => serde_json::from_str(&content).unwrap_or_else(|err| {
+                warn!("Cannot parse plugin store {:?}: {}", path, err);
+
+                HashMap::new()
+            }),
+            Err(_) => HashMap::new(),
+        };
+
+        Self {
+            path: Some(path),
+            values,
+        }
+    }
+
+    /// Set or remove a value and flush the store
+    ///
+    /// # Arguments
+    ///
+    /// * `key` - Key of the value
+    /// * `value` - Value to store; empty to remove the key
+    ///
+    /// # Returns
+    ///
+    /// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+    pub(crate) fn set(&mut self, key: &str, value: &str) -> Result<()> {
+        if value.is_empty() {
+            self.values.remove(key);
+        } else {
+            let size: usize = self.values.iter()
+                .filter(|(stored_key, _)| stored_key.as_str() != key)
+                .map(|(stored_key, stored_value)| stored_key.len() + stored_value.len())
+                .sum();
+
+            if MAX_KV_SIZE < size + key.len() + value.len() {
+                return Err(anyhow!("Store size limit of {} bytes exceeded", MAX_KV_SIZE));
+            }
+
+            self.values.insert(key.to_string(), value.to_string());
+        }
+
+        // Write to temp file first to keep the store intact on errors
+        if let Some(path) = self.path.as_ref() {
+            if let Some(parent) = path.parent() {
+                std::fs::create_dir_all(parent)?;
+            }
+
+            let tmp_path = path.with_extension("tmp");
+
+            std::fs::write(&tmp_path, serde_json::to_string(&self.values)?)?;
+            std::fs::rename(&tmp_path, path)?;
+        }
+
+        Ok(())
+    }
+}
+
+#[derive(Default, Debug)]
+pub(crate) struct HostCache {
+    /// Results of host calls by function name and arguments
+    pub(crate) values: HashMap<(String, String), String>,
+    /// Pointer of the current cycle or [`None`] when it hasn't been queried yet
+    pub(crate) pointer: Option<Option<PointerInfo>>,
+    /// Keyboard layout until Xkb reports a change or [`None`] when it hasn't been queried yet
+    pub(crate) keyboard_layout: Option<Option<KeyboardLayout>>,
+}
+
+impl HostCache {
+    /// Get the cached result of a host call or call it and cache the result
+    ///
+    /// # Arguments
+    ///
+    /// * `fn_name` - Name of the host function
+    /// * `args` - Arguments of the call
+    /// * `call` - Function to call on cache miss
+    ///
+    /// # Returns
+    ///
+    /// A [`Result`] with either [`String`] on success or otherwise [`anyhow::Error`]
+    pub(crate) fn get_or_insert_with<F>(&mut self, fn_name: &str, args: &str, call: F) -> Result<String>
+        where F: FnOnce() -> Result<String>
+    {
+        let key = (fn_name.to_string(), args.to_string());
+
+        if let Some(value) = self.values.get(&key) {
+            return Ok(value.clone());
+        }
+
+        // Errors aren't cached, so the next call tries again
+        let value = call()?;
+
+        self.values.insert(key, value.clone());
+
+        Ok(value)
+    }
+
+    /// Remove all cached results of a host function after it has changed
+    ///
+    /// # Arguments
+    ///
+    /// * `fn_name` - Name of the host function
+    pub(crate) fn invalidate(&mut self, fn_name: &str) {
+        self.values.retain(|(cached_fn_name, _), _| cached_fn_name != fn_name);
+    }
+
+    /// Get the pointer of the current cycle or query it once
+    ///
+    /// # Arguments
+    ///
+    /// * `query` - Function to query the pointer on cache miss
+    ///
+    /// # Returns
+    ///
+    /// Either [`Some`] with the [`PointerInfo`] or otherwise [`None`] when the pointer cannot be queried
+    pub(crate) fn pointer_or_insert_with<F>(&mut self, query: F) -> Option<PointerInfo>
+        where F: FnOnce() -> Option<PointerInfo>
+    {
+        self.pointer.get_or_insert_with(query).clone()
+    }
+
+    /// Get the cached keyboard layout or query it once
+    ///
+    /// # Arguments
+    ///
+    /// * `query` - Function to query the layout on cache miss
+    ///
+    /// # Returns
+    ///
+    /// Either [`Some`] with the [`KeyboardLayout`] or otherwise [`None`] when the layout cannot be queried
+    pub(crate) fn keyboard_layout_or_insert_with<F>(&mut self, query: F) -> Option<KeyboardLayout>
+        where F: FnOnce() -> Option<KeyboardLayout>
+    {
+        self.keyboard_layout.get_or_insert_with(query).clone()
+    }
+
+    /// Remove all cached results of the cycle; the keyboard layout is kept until Xkb reports a change
+    pub(crate) fn clear(&mut self) {
+        self.values.clear();
+        self.pointer = None;
+    }
+}
+
+#[derive(Default, Debug, Clone, PartialEq)]
+pub(crate) struct Capabilities {
+    /// Plugin may run commands
+    pub(crate) exec: bool,
+    /// Plugin may use the key/value store
+    pub(crate) kv: bool,
+    /// Plugin may send window manager commands
+    pub(crate) command: bool,
+    /// Plugin may read and set the selections
+    pub(crate) clipboard: bool,
+    /// Hosts the plugin may fetch urls from
+    pub(crate) http_hosts: Vec<String>,
+}
+
+/// Per-instance state shared with the host functions
+#[derive(Default, Debug)]
+pub(crate) struct PluginState {
+    /// Name of the plugin
+    pub(crate) name: String,
+    /// Capabilities declared in the manifest of the plugin
+    pub(crate) capabilities: Capabilities,
+    /// Whether the plugin may run commands
+    pub(crate) allow_exec: bool,
+    /// Hosts the plugin may send http requests to
+    pub(crate) allowed_hosts: Vec<String>,
+    /// Last cpu samples of this instance
+    pub(crate) cpu_samples: Vec<(i32, i32, i32)>,
+    /// Previous cpu times of this instance; the first entry is the aggregate
+    pub(crate) cpu_times: Vec<CpuTimes>,
+    /// Previous network samples of this instance per interface
+    pub(crate) net_samples: HashMap<String, NetSample>,
+    /// Previous cpu samples of this instance per process id
+    pub(crate) proc_samples: HashMap<u32, ProcSample>,
+    /// Tooltip text set by the plugin
+    pub(crate) tooltip: String,
+    /// Minimum width in cells set by the plugin
+    pub(crate) min_width_cells: u16,
+    /// Persistent key/value store
+    pub(crate) store: KvStore,
+    /// Window manager commands queued with the position of the calling panel
+    pub(crate) commands: Vec<(GrabFlags, GrabAction, (i16, i16))>,
+    /// Selection texts queued to be set
+    pub(crate) selection_requests: Vec<(SelectionKind, String)>,
+    /// Pending timers by id with their deadline
+    pub(crate) timers: HashMap<u32, Instant>,
+    /// Id of the last scheduled timer
+    pub(crate) last_timer_id: u32,
+    /// Snapshot of the window manager state at call time
+    pub(crate) context: HostContext,
+    /// Cache of host calls shared by all plugins
+    pub(crate) cache: Arc<Mutex<HostCache>>,
+    /// Whitelisted global config values
+    pub(crate) config_values: HashMap<String, String>,
+    /// Last good http responses by method and url with their time
+    pub(crate) http_cache: HashMap<String, (Instant, HttpResponse)>,
+    /// Http requests running in the background by method, url and body
+    pub(crate) http_jobs: Jobs<HttpResponse>,
+    /// Commands running in the background by command and arguments
+    pub(crate) command_jobs: Jobs<CommandOutput>,
+    /// Helpers running in the background by host function and arguments with their result as JSON
+    pub(crate) helper_jobs: Jobs<String>,
+    /// Last results of helper queries as JSON with the time they have been fetched
+    pub(crate) helper_values: HashMap<String, (Option<Instant>, String)>,
+}
+
+impl PluginState {
+    /// Check whether a capability has been declared and log refused calls
+    ///
+    /// # Arguments
+    ///
+    /// * `is_declared` - Whether the capability has been declared
+    /// * `function_name` - Name of the called host function
+    ///
+    /// # Returns
+    ///
+    /// Either [`true`] if the call is allowed or otherwise [`false`]
+    fn check_capability(&self, is_declared: bool, function_name: &str) -> bool {
+        if !is_declared {
+            warn!("Refused call of undeclared host function `{}` ({})", function_name, self.name);
+        }
+
+        is_declared
+    }
+
+    /// Get the last result of a helper query and refresh it in the background when it is outdated
+    ///
+    /// # Arguments
+    ///
+    /// * `key` - Host function and arguments of the query
+    /// * `call` - Call to fetch the result as JSON
+    ///
+    /// # Returns
+    ///
+    /// Either [`Some`] with the last result or [`None`] when none has been fetched yet
+    pub(crate) fn refresh_helper<F>(&mut self, key: &str, call: F) -> Option<String>
+        where F: FnOnce() -> String + Send + 'static
+    {
+        let is_fresh = self.helper_values.get(key)
+            .is_some_and(|(fetched_at, _)| fetched_at.is_some_and(|fetched_at| fetched_at.elapsed() < HELPER_REFRESH));
+
+        // Collect finished refreshes and start new ones only for outdated results
+        if !is_fresh || self.helper_jobs.entries.contains_key(key) {
+            match self.helper_jobs.run(key, MAX_HELPER_JOBS, call) {
+                JobStatus::Done(value) => {
+                    self.helper_values.insert(key.to_string(), (Some(Instant::now()), value));
+                },
+                JobStatus::Failed => warn!("Cannot run helper of plugin ({}): {}", self.name, key),
+                JobStatus::Pending | JobStatus::Busy => {},
+            }
+        }
+
+        self.helper_values.get(key).map(|(_, value)| value.clone())
+    }
+
+    /// Mark the last result of a helper query as outdated, so the next call refreshes it
+    ///
+    /// # Arguments
+    ///
+    /// * `key` - Host function and arguments of the query
+    pub(crate) fn outdate_helper(&mut self, key: &str) {
+        if let Some((fetched_at, _)) = self.helper_values.get_mut(key) {
+            *fetched_at = None;
+        }
+    }
+}
+
+#[derive(Default, Debug, Clone, Serialize)]
+pub(crate) struct ViewInfo {
+    /// Name of the view
+    pub(crate) name: String,
+    /// Index of the view
+    pub(crate) index: usize,
+    /// Names of the tags of this view
+    pub(crate) tag_names: Vec<String>,
+    /// Number of clients on this view
+    pub(crate) client_count: usize,
+    /// Whether any client on this view is urgent
+    pub(crate) urgent: bool,
+}
+
+#[derive(Default, Debug, Clone, PartialEq, Serialize)]
+pub(crate) struct ViewSummary {
+    /// Name of the view
+    pub(crate) name: String,
+    /// Index of the view
+    pub(crate) index: usize,
+    /// Whether this is the view of the focused screen
+    pub(crate) active: bool,
+    /// Whether the view has at least one client
+    pub(crate) occupied: bool,
+    /// Whether any client on this view is urgent
+    pub(crate) urgent: bool,
+    /// Number of clients on this view
+    pub(crate) client_count: usize,
+}
+
+#[derive(Default, Debug, Clone, Serialize)]
+pub(crate) struct ClientInfo {
+    /// Window id of the client
+    pub(crate) id: u32,
+    /// Sanitized title of the client
+    pub(crate) title: String,
+    /// Window class of the client
+    pub(crate) class: String,
+    /// Whether the client has the focus
+    pub(crate) focused: bool,
+    /// Whether the client is urgent
+    pub(crate) urgent: bool,
+    /// Whether the client is minimized; clients cannot be iconified yet
+    pub(crate) minimized: bool,
+}
+
+#[derive(Default, Debug, Clone, Serialize)]
+pub(crate) struct PointerInfo {
+    /// X position in root coordinates
+    pub(crate) x: i16,
+    /// Y position in root coordinates
+    pub(crate) y: i16,
+    /// Index of the screen under the pointer
+    pub(crate) monitor: Option<usize>,
+    /// Window under the pointer or [`None`] when over the root window
+    pub(crate) window_id: Option<u32>,
+    /// Window class when the window is a client
+    pub(crate) window_class: Option<String>,
+}
+
+#[derive(Default, Debug, Clone, Serialize)]
+pub(crate) struct ThemeColors {
+    /// Foreground color of panel items
+    pub(crate) foreground: Option<String>,
+    /// Background color of panel items
+    pub(crate) background: Option<String>,
+    /// Border color of panel items
+    pub(crate) border: Option<String>,
+    /// Named accent colors of the other styles
+    pub(crate) accents: HashMap<String, String>,
+}
+
+#[derive(Default, Debug, Copy, Clone, PartialEq, Serialize)]
+pub(crate) struct PanelGeometry {
+    /// X position of the panel in pixels
+    pub(crate) x: i16,
+    /// Y position of the panel in pixels
+    pub(crate) y: i16,
+    /// Width of the panel in pixels
+    pub(crate) width: u16,
+    /// Height of the panel in pixels
+    pub(crate) height: u16,
+    /// Index of the monitor the panel lives on
+    pub(crate) monitor_index: usize,
+    /// Total number of monitors
+    pub(crate) monitor_count: usize,
+    /// Whether this is the bottom panel; together with the monitor index it tells the panels apart
+    pub(crate) is_bottom: bool,
+}
+
+#[derive(Default, Debug, Clone)]
+pub(crate) struct HostContext {
+    /// Current view of the focused screen
+    pub(crate) current_view: Option<ViewInfo>,
+    /// Geometry of the calling panel
+    pub(crate) panel: Option<PanelGeometry>,
+    /// Names of all views
+    pub(crate) view_names: Vec<String>,
+    /// Summary of all views
+    pub(crate) views: Vec<ViewSummary>,
+    /// Clients of the current view
+    pub(crate) clients: Vec<ClientInfo>,
+    /// Colors of the current theme
+    pub(crate) theme: ThemeColors,
+    /// Current keyboard layout
+    pub(crate) keyboard_layout: Option<KeyboardLayout>,
+    /// Current pointer position
+    pub(crate) pointer: Option<PointerInfo>,
+    /// Last known selection contents; only for plugins with clipboard capability
+    pub(crate) selections: HashMap<SelectionKind, SelectionContent>,
+}
+
+/// Get path of the key/value store of the plugin inside of the data dir
+///
+/// # Arguments
+///
+/// * `name` - Name of the plugin
+///
+/// # Returns
+///
+/// Either [`Some`] with the path or otherwise [`None`] when no data dir can be found
+pub(crate) fn store_path(name: &str) -> Option<PathBuf> {
+    let data_dir = std::env::var_os("XDG_DATA_HOME")
+        .filter(|dir| !dir.is_empty())
+        .map(PathBuf::from)
+        .or_else(|| std::env::var_os("HOME").map(|home| PathBuf::from(home).join(".local/share")))?;
+
+    // Keep names from escaping the plugin dir
+    let file_name: String = name.chars()
+        .map(|ch| if ch.is_ascii_alphanumeric() || '-' == ch || '_' == ch { ch } else { '_' })
+        .collect();
+
+    Some(data_dir.join("subtle-rs").join("plugins").join(format!("{}.json", file_name)))
+}
+
+/// Sanitize text of windows for display in plugins
+///
+/// # Arguments
+///
+/// * `text` - Text to sanitize
+/// * `max_len` - Maximum length in chars
+///
+/// # Returns
+///
+/// A [`String`] without control chars which is truncated at the maximum length
+pub(crate) fn sanitize_text(text: &str, max_len: usize) -> String {
+    let mut chars = text.chars()
+        .filter(|ch| !ch.is_control() && char::REPLACEMENT_CHARACTER != *ch);
+
+    let mut sanitized: String = chars.by_ref().take(max_len).collect();
+
+    if chars.next().is_some() {
+        sanitized.pop();
+        sanitized.push('…');
+    }
+
+    sanitized
+}
+
+/// Parse the output of an export either as decimal string or as little-endian u32
+///
+/// # Arguments
+///
+/// * `output` - Output bytes of the export
+///
+/// # Returns
+///
+/// Either [`Some`] with the value or otherwise [`None`] when the output is empty or invalid
+pub(crate) fn parse_export_value(output: &[u8]) -> Option<u32> {
+    // Empty output means the value is missing and never on demand
+    if output.is_empty() {
+        None
+    } else if let Ok(value) = std::str::from_utf8(output) && let Ok(millis) = value.trim().parse::<u32>() {
+        Some(millis)
+    } else if let Ok(bytes) = <[u8; 4]>::try_from(output) {
+        Some(u32::from_le_bytes(bytes))
+    } else {
+        None
+    }
+}
+
+impl PluginBuilder {
+
+    /// Create a new instance
+    ///
+    /// # Arguments
+    ///
+    /// * `name` - Name of the plugin
+    /// * `url` - Url to wasm file
+    ///
+    /// # Returns
+    ///
+    /// A [`Result`] with either [`Plugin`] on success or otherwise [`anyhow::Error`]
+    pub(crate) fn build(&mut self) -> Result<Plugin> {
+        let url = self.url.clone().context("Url not set")?;
+
+        let name = self.name.clone().context("Name not set")?;
+        let config = self.config.take().unwrap_or_default();
+
+        let state = UserData::new(PluginState {
+            name: name.clone(),
+            allow_exec: self.allow_exec.unwrap_or(false),
+            allowed_hosts: self.allowed_hosts.take().unwrap_or_default(),
+            store: store_path(&name).map(KvStore::load).unwrap_or_default(),
+            cache: self.cache.clone().unwrap_or_default(),
+            config_values: self.config_values.take().unwrap_or_default(),
+            ..PluginState::default()
+        });
+
+        // Load wasm plugin
+        let mut plugin = load_wasm(&url, &config, &state)?;
+
+        // Check requested capabilities against the config
+        let flags = check_exports(&mut plugin, &state.get()?, &name);
+
+        let config_interval = Duration::from_secs(self.interval.unwrap_or(DEFAULT_INTERVAL).max(0) as u64);
+        let config_placement = Placement {
+            side: self.side,
+            position: self.position,
+        };
+
+        let (interval, subscriptions, placement) = probe_exports(&mut plugin, &name,
+                                                                 config_interval, config_placement);
+
+        debug!("{}: interval={:?}, subscriptions={:?}, placement={:?}, flags={:?}",
+            function_name!(), interval, subscriptions, placement, flags);
+
+        Ok(Plugin {
+            flags: Cell::new(flags),
+            name,
+            url,
+            config,
+            interval: Cell::new(interval),
+            config_interval,
+            subscriptions: Cell::new(subscriptions),
+            last_run: Cell::new(None),
+            next_update: Cell::new(Some(Instant::now())),
+            outputs: RefCell::new(HashMap::new()),
+            align: self.align.unwrap_or_default(),
+            placement: Cell::new(placement),
+            config_placement,
+            error_text: self.error_text.take().unwrap_or_else(|| String::from(DEFAULT_ERROR_TEXT)),
+            keep_on_error: self.keep_on_error.unwrap_or(false),
+            failures: Cell::new(0),
+            retry_at: Cell::new(None),
+            state: state.get()?,
+            plugin: Rc::new(RefCell::new(plugin)),
+        })
+    }
+}
+
+/// Check the manifest and the optional exports of the plugin
+///
+/// # Arguments
+///
+/// * `plugin` - Extism plugin to check
+/// * `state` - State shared with the host functions
+/// * `name` - Name of the plugin
+///
+/// # Returns
+///
+/// A [`PluginFlags`] with the flags of the capabilities and exports
+fn check_exports(plugin: &mut extism::Plugin, state: &Mutex<PluginState>, name: &str) -> PluginFlags {
+    let capabilities = read_manifest(plugin, name);
+
+    // Click actions are just commands
+    let mut flags = PluginFlags::empty();
+
+    if capabilities.command {
+        flags.insert(PluginFlags::CLICK_ACTIONS);
+    }
+
+    if capabilities.clipboard {
+        flags.insert(PluginFlags::CLIPBOARD);
+    }
+
+    if let Ok(mut state) = state.lock() {
+        for capability in find_ungranted_capabilities(&capabilities, state.allow_exec, &state.allowed_hosts) {
+            warn!("Plugin requests capability `{}`, which is not granted by the config ({})",
+                capability, name);
+        }
+
+        state.capabilities = capabilities;
+    }
+
+    // Check optional exports
+    if plugin.function_exists("on_click") {
+        flags.insert(PluginFlags::ON_CLICK);
+    }
+
+    if plugin.function_exists("on_scroll") {
+        flags.insert(PluginFlags::ON_SCROLL);
+    }
+
+    if plugin.function_exists("on_timer") {
+        flags.insert(PluginFlags::ON_TIMER);
+    }
+
+    debug!("{}: plugin={}, flags={:?}", function_name!(), name, flags);
+
+    flags
+}
+
+/// Read the capabilities from the optional manifest export of the plugin
+///
+/// # Arguments
+///
+/// * `plugin` - Extism plugin to call
+/// * `name` - Name of the plugin
+///
+/// # Returns
+///
+/// A [`Capabilities`] with the requested capabilities or none without manifest
+fn read_manifest(plugin: &mut extism::Plugin, name: &str) -> Capabilities {
+    if !plugin.function_exists("manifest") {
+        info!("Plugin has no manifest, host functions with capabilities are disabled ({})", name);
+
+        return Capabilities::default();
+    }
+
+    match plugin.call::<&str, &str>("manifest", "")
+        .and_then(|output| Ok(serde_json::from_str::<Vec<String>>(output)?))
+    {
+        Ok(capabilities) => parse_capabilities(&capabilities),
+        Err(err) => {
+            warn!("Cannot read plugin manifest ({}): {}", name, err);
+
+            Capabilities::default()
+        },
+    }
+}
+
+/// Read interval, subscriptions and placement from the optional exports of the plugin
+///
+/// # Arguments
+///
+/// * `plugin` - Extism plugin to call
+/// * `name` - Name of the plugin
+/// * `config_interval` - Interval of the config
+/// * `config_placement` - Placement of the config
+///
+/// # Returns
+///
+/// A tuple of interval, subscriptions and placement where exports win over the config
+fn probe_exports(plugin: &mut extism::Plugin, name: &str, config_interval: Duration,
+                 config_placement: Placement) -> (Duration, PluginEvents, Placement)
+{
+    // Prefer placement exported by the plugin over the config
+    let placement = read_placement(plugin, name);
+
+    let placement = Placement {
+        side: placement.side.or(config_placement.side),
+        position: placement.position.or(config_placement.position),
+    };
+
+    // Prefer interval exported by the plugin over the config
+    let interval = read_export_value(plugin, "interval")
+        .map(|millis| Duration::from_millis(millis as u64))
+        .unwrap_or(config_interval);
+
+    let subscriptions = read_export_value(plugin, "subscribe")
+        .map(PluginEvents::from_bits_truncate)
+        .unwrap_or_default();
+
+    (interval, subscriptions, placement)
+}
+
+/// Read the placement from the optional placement export of the plugin
+///
+/// # Arguments
+///
+/// * `plugin` - Extism plugin to call
+/// * `name` - Name of the plugin
+///
+/// # Returns
+///
+/// A [`Placement`] with the exported values or an empty one without export
+fn read_placement(plugin: &mut extism::Plugin, name: &str) -> Placement {
+    if !plugin.function_exists("placement") {
+        return Placement::default();
+    }
+
+    match plugin.call::<&str, &str>("placement", "")
+        .and_then(parse_placement)
+    {
+        Ok(placement) => placement,
+        Err(err) => {
+            warn!("Cannot read plugin placement ({}): {}", name, err);
+
+            Placement::default()
+        },
+    }
+}
+
+/// Parse placement like `{"side": "right", "position": 10}`
+///
+/// # Arguments
+///
+/// * `output` - Output of the placement export
+///
+/// # Returns
+///
+/// A [`Result`] with either [`Placement`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn parse_placement(output: &str) -> Result<Placement> {
+    Ok(serde_json::from_str::<Placement>(output)?)
+}
+
+/// Parse list of capabilities like `exec`, `kv`, `command`, `clipboard` or `http:example.com`
+///
+/// # Arguments
+///
+/// * `capabilities` - List of capabilities
+///
+/// # Returns
+///
+/// A [`Capabilities`] with the parsed capabilities
+pub(crate) fn parse_capabilities(capabilities: &[String]) -> Capabilities {
+    let mut parsed = Capabilities::default();
+
+    for capability in capabilities.iter() {
+        match capability.trim() {
+            "exec" => parsed.exec = true,
+            "kv" => parsed.kv = true,
+            "command" => parsed.command = true,
+            "clipboard" => parsed.clipboard = true,
+            capability => match capability.strip_prefix("http:") {
+                Some(host) if !host.is_empty() => parsed.http_hosts.push(host.to_string()),
+                _ => warn!("Unknown plugin capability `{}`", capability),
+            },
+        }
+    }
+
+    parsed
+}
+
+/// Find requested capabilities that are not granted by the config
+///
+/// # Arguments
+///
+/// * `capabilities` - Requested capabilities
+/// * `allow_exec` - Whether the config allows to run commands
+/// * `allowed_hosts` - Hosts allowed by the config
+///
+/// # Returns
+///
+/// A [`Vec`] with the ungranted capabilities
+pub(crate) fn find_ungranted_capabilities(capabilities: &Capabilities, allow_exec: bool,
+                                          allowed_hosts: &[String]) -> Vec<String>
+{
+    let mut ungranted = Vec::new();
+
+    if capabilities.exec && !allow_exec {
+        ungranted.push(String::from("exec"));
+    }
+
+    // Wildcards are only granted by the same wildcard
+    for host in capabilities.http_hosts.iter() {
+        let is_granted = allowed_hosts.iter().any(|allowed_host| allowed_host.eq_ignore_ascii_case(host))
+            || (!host.starts_with("*.") && is_host_allowed(allowed_hosts, &format!("https://{}/", host)));
+
+        if !is_granted {
+            ungranted.push(format!("http:{}", host));
+        }
+    }
+
+    ungranted
+}
+
+/// Read the value of an optional export of the plugin
+///
+/// # Arguments
+///
+/// * `plugin` - Extism plugin to call
+/// * `name` - Name of the export
+///
+/// # Returns
+///
+/// Either [`Some`] with the value or otherwise [`None`] when the export is missing
+fn read_export_value(plugin: &mut extism::Plugin, name: &str) -> Option<u32> {
+    if !plugin.function_exists(name) {
+        return None;
+    }
+
+    // Only the output carries the value, non-zero return codes are errors
+    match plugin.call::<&str, &[u8]>(name, "") {
+        Ok(output) if output.is_empty() => {
+            debug!("{}: name={}, missing", function_name!(), name);
+
+            None
+        },
+        Ok(output) => {
+            let value = parse_export_value(output);
+
+            if value.is_none() {
+                warn!("Invalid value of plugin export `{}`: {}", name, String::from_utf8_lossy(output));
+            }
+
+            value
+        },
+        Err(err) => {
+            warn!("Cannot read plugin export `{}`: {}", name, err);
+
+            None
+        },
+    }
+}
+
+impl Plugin {
+
+    /// Refresh the snapshot of the window manager state for the host functions
+    ///
+    /// # Arguments
+    ///
+    /// * `subtle` - Global state object
+    /// * `panel_id` - Calling panel as screen index and bottom flag
+    pub(crate) fn refresh_context(&self, subtle: &Subtle, panel_id: (usize, bool)) {
+        if let Ok(mut state) = self.state.lock() {
+            state.context = collect_context(subtle, panel_id);
+
+            // Don't copy the selections for every plugin
+            if self.flags.get().intersects(PluginFlags::CLIPBOARD) {
+                state.context.selections = subtle.selection.borrow().contents.clone();
+            }
+        }
+    }
+
+    /// Call the run method of the plugin
+    ///
+    /// # Arguments
+    ///
+    /// * `subtle` - Global state object
+    /// * `panel_id` - Calling panel as screen index and bottom flag
+    ///
+    /// # Returns
+    ///
+    /// A [`Result`] with either [`RunOutput`] on success or otherwise [`anyhow::Error`]
+    pub(crate) fn update(&self, subtle: &Subtle, panel_id: (usize, bool)) -> Result<RunOutput> {
+       self.refresh_context(subtle, panel_id);
+
+        // Non-zero return values mark the run as failed
+        let res: String = match self.plugin.borrow_mut().call_get_error_code::<&str, String>("run", "") {
+            Ok(res) => res,
+            Err((err, HOST_ERROR_CODE)) => return Err(anyhow!("Run aborted by host: {}", err)),
+            Err((err, code)) => return Err(anyhow!("Run failed with code {}: {}", code, err)),
+        };
+
+        debug!("{}: res={}", function_name!(), res);
+
+        let mut output = parse_run_output(&res);
+
+        if !output.click_actions.is_empty() {
+            self.validate_click_actions(&mut output.click_actions);
+        }
+
+        Ok(output)
+    }
+
+    /// Drop click actions that cannot be run and log them
+    ///
+    /// # Arguments
+    ///
+    /// * `click_actions` - Click actions of the output
+    fn validate_click_actions(&self, click_actions: &mut HashMap<u8, WmCommand>) {
+        let Ok(state) = self.state.lock() else {
+            return;
+        };
+
+        if !state.check_capability(self.flags.get().intersects(PluginFlags::CLICK_ACTIONS), "click_actions") {
+            click_actions.clear();
+
+            return;
+        }
+
+        let allow_exec = state.capabilities.exec && state.allow_exec;
+
+        click_actions.retain(|button, command| {
+            match parse_wm_command(command, &state.context.view_names, allow_exec) {
+                Ok(_) => true,
+                Err(err) => {
+                    warn!("Ignoring click action of button {} of plugin ({}): {:?}", button, self.name, err);
+
+                    false
+                },
+            }
+        });
+    }
+
+    /// Queue the click action of the given button if any
+    ///
+    /// # Arguments
+    ///
+    /// * `subtle` - Global state object
+    /// * `panel_id` - Clicked panel as screen index and bottom flag
+    /// * `button` - Mouse button
+    ///
+    /// # Returns
+    ///
+    /// Either [`true`] when the output has a click action for the button or otherwise [`false`]
+    fn run_click_action(&self, subtle: &Subtle, panel_id: (usize, bool), button: u8) -> bool {
+        let Some(command) = self.output(panel_id)
+            .and_then(|output| output.click_actions.get(&button).cloned()) else {
+            return false;
+        };
+
+        self.refresh_context(subtle, panel_id);
+
+        if let Ok(mut state) = self.state.lock() {
+            let allow_exec = state.capabilities.exec && state.allow_exec;
+
+            // Views might have changed since the output has been validated
+            match parse_wm_command(&command, &state.context.view_names, allow_exec) {
+                Ok((flag, action)) => {
+                    let position = state.context.panel.map_or((0, 0), |panel| (panel.x, panel.y));
+
+                    state.commands.push((flag, action, position));
+                },
+                Err(err) => warn!("Cannot run click action of plugin ({}): {:?}", self.name, err),
+            }
+        }
+
+        debug!("{}: button={}, action={}, arg={}", function_name!(), button, command.action, command.arg);
+
+        true
+    }
+
+    /// Call the on_click method of the plugin if exported
+    ///
+    /// # Arguments
+    ///
+    /// * `subtle` - Global state object
+    /// * `panel_id` - Clicked panel as screen index and bottom flag
+    /// * `button` - Mouse button
+    /// * `x` - X offset of the click within the plugin panel
+    ///
+    /// # Returns
+    ///
+    /// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+    pub(crate) fn click(&self, subtle: &Subtle, panel_id: (usize, bool), button: u8, x: i16) -> Result<()> {
+        invalidate_pointer(subtle);
+
+        // Prefer declarative click actions over on_click
+        if self.flags.get().intersects(PluginFlags::CLICK_ACTIONS) && self.run_click_action(subtle, panel_id, button) {
+            return Ok(());
+        }
+
+        if !self.flags.get().intersects(PluginFlags::ON_CLICK) {
+            return Ok(());
+        }
+
+        self.refresh_context(subtle, panel_id);
+        self.begin_job_call();
+
+        let input = serde_json::to_string(&ClickEvent { button, x })?;
+
+        let res: String = self.plugin.borrow_mut().call("on_click", input.as_str())?;
+
+        // Use output as new text or run the plugin again
+        if res.is_empty() {
+            self.next_update.set(Some(Instant::now()));
+        } else {
+            self.outputs.borrow_mut().insert(panel_id, parse_run_output(&res));
+        }
+
+        debug!("{}: button={}, x={}", function_name!(), button, x);
+
+        Ok(())
+    }
+
+    /// Call the on_scroll method of the plugin if exported and run it again
+    ///
+    /// # Arguments
+    ///
+    /// * `subtle` - Global state object
+    /// * `panel_id` - Scrolled panel as screen index and bottom flag
+    /// * `direction` - Direction of the wheel
+    ///
+    /// # Returns
+    ///
+    /// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+    pub(crate) fn scroll(&self, subtle: &Subtle, panel_id: (usize, bool), direction: ScrollDirection) -> Result<()> {
+        if !self.flags.get().intersects(PluginFlags::ON_SCROLL) {
+            return Ok(());
+        }
+
+        invalidate_pointer(subtle);
+
+        self.refresh_context(subtle, panel_id);
+        self.begin_job_call();
+
+        let input = serde_json::to_string(&ScrollEvent { direction, delta: 1 })?;
+
+        self.plugin.borrow_mut().call::<&str, &str>("on_scroll", input.as_str())?;
+
+        // Don't wait for the timer to display the change
+        self.next_update.set(Some(Instant::now()));
+
+        debug!("{}: direction={:?}", function_name!(), direction);
+
+        Ok(())
+    }
+
+    /// Call the on_timer method of the plugin for all expired timers and run it again
+    ///
+    /// # Arguments
+    ///
+    /// * `subtle` - Global state object
+    /// * `panel_id` - Panel of the plugin as screen index and bottom flag
+    /// * `now` - Current time
+    ///
+    /// # Returns
+    ///
+    /// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+    pub(crate) fn fire_timers(&self, subtle: &Subtle, panel_id: (usize, bool), now: Instant) -> Result<()> {
+        let mut timer_ids: Vec<u32> = {
+            let mut state = self.state.lock().map_err(|_| anyhow!("Failed to lock state"))?;
+
+            let timer_ids = state.timers.iter()
+                .filter(|(_, deadline)| **deadline <= now)
+                .map(|(timer_id, _)| *timer_id)
+                .collect::<Vec<u32>>();
+
+            state.timers.retain(|_, deadline| *deadline > now);
+
+            timer_ids
+        };
+
+        if timer_ids.is_empty() {
+            return Ok(());
+        }
+
+        if !self.flags.get().intersects(PluginFlags::ON_TIMER) {
+            warn!("Plugin schedules callbacks, but doesn't export on_timer ({})", self.name);
+
+            return Ok(());
+        }
+
+        timer_ids.sort();
+
+        self.refresh_context(subtle, panel_id);
+        self.begin_job_call();
+
+        let mut first_err = None;
+
+        // A failing timer must not swallow the others
+        for timer_id in timer_ids.iter() {
+            let input = serde_json::to_string(&TimerEvent { id: *timer_id })?;
+
+            if let Err(err) = self.plugin.borrow_mut().call::<&str, &str>("on_timer", input.as_str()) {
+                warn!("Cannot run timer of plugin ({}): id={}, {}", self.name, timer_id, err);
+
+                first_err.get_or_insert(err);
+            }
+        }
+
+        debug!("{}: plugin={}, timer_ids={:?}, failed={}", function_name!(), self.name, timer_ids, first_err.is_some());
+
+        if let Some(err) = first_err {
+            return Err(err);
+        }
+
+        // Don't wait for the interval to display the change
+        self.next_update.set(Some(now));
+
+        Ok(())
+    }
+
+    /// Get the next deadline of the pending timers
+    ///
+    /// # Returns
+    ///
+    /// Either [`Some`] with the deadline or [`None`] when no timer is pending
+    pub(crate) fn next_timer(&self) -> Option<Instant> {
+        self.state.lock().ok()
+            .and_then(|state| state.timers.values().min().copied())
+    }
+
+    /// Check the background jobs and schedule a run when any has finished
+    ///
+    /// # Arguments
+    ///
+    /// * `now` - Current time
+    pub(crate) fn poll_jobs(&self, now: Instant) {
+        let has_finished = self.state.lock()
+            .map(|mut state| {
+                let has_finished = state.http_jobs.poll(now);
+                let has_finished = state.command_jobs.poll(now) || has_finished;
+
+                state.helper_jobs.poll(now) || has_finished
+            })
+            .unwrap_or(false);
+
+        if has_finished {
+            self.next_update.set(Some(now));
+        }
+    }
+
+    /// Start a new call of the plugin, which runs jobs handed over to the last one again
+    pub(crate) fn begin_job_call(&self) {
+        if let Ok(mut state) = self.state.lock() {
+            state.http_jobs.drop_handed_over();
+            state.command_jobs.drop_handed_over();
+            state.helper_jobs.drop_handed_over();
+        }
+    }
+
+    /// Drop the results of background jobs the last run has not collected
+    pub(crate) fn drop_job_results(&self) {
+        if let Ok(mut state) = self.state.lock() {
+            state.http_jobs.drop_finished();
+            state.command_jobs.drop_finished();
+            state.helper_jobs.drop_finished();
+        }
+    }
+
+    /// Get the time of the next check of the background jobs
+    ///
+    /// # Arguments
+    ///
+    /// * `now` - Current time
+    ///
+    /// # Returns
+    ///
+    /// Either [`Some`] with the time or [`None`] when no job is running
+    pub(crate) fn next_job_poll(&self, now: Instant) -> Option<Instant> {
+        self.state.lock().ok()
+            .filter(|state| 0 < state.http_jobs.running() || 0 < state.command_jobs.running()
+                || 0 < state.helper_jobs.running())
+            .map(|_| now + JOB_POLL_INTERVAL)
+    }
+
+    /// Call the optional init method of the plugin once after loading
+    ///
+    /// # Returns
+    ///
+    /// Either [`true`] when the plugin is ready or otherwise [`false`] when it should be disabled
+    pub(crate) fn init(&self) -> bool {
+        let mut plugin = self.plugin.borrow_mut();
+
+        if !plugin.function_exists("init") {
+            return true;
+        }
+
+        // Non-zero return values disable the plugin
+        let is_ready = match plugin.call_get_error_code::<&str, &[u8]>("init", "") {
+            Ok(_) => true,
+            Err((err, code)) => {
+                warn!("Plugin init failed with code {} ({}): {}", code, self.name, err);
+
+                false
+            },
+        };
+
+        debug!("{}: plugin={}, is_ready={}", function_name!(), self.name, is_ready);
+
+        is_ready
+    }
+
+    /// Call the optional teardown method of the plugin before it is dropped
+    pub(crate) fn teardown(&self) {
+        let mut plugin = self.plugin.borrow_mut();
+
+        if plugin.function_exists("teardown")
+            && let Err(err) = plugin.call::<&str, &[u8]>("teardown", "")
+        {
+            warn!("Plugin teardown failed ({}): {}", self.name, err);
+        }
+
+        debug!("{}: plugin={}", function_name!(), self.name);
+    }
+
+    /// Reload the wasm file and swap the instance in place
+    ///
+    /// # Returns
+    ///
+    /// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`] when the old instance is kept
+    pub(crate) fn reload(&self) -> Result<()> {
+        // Load the new instance first, so a bad file keeps the old one running
+        let mut plugin = load_wasm(&self.url, &self.config, &UserData::Rust(self.state.clone()))?;
+
+        // Without run the new instance cannot show anything
+        if !plugin.function_exists("run") {
+            return Err(anyhow!("Reloaded plugin doesn't export run"));
+        }
+
+        let (old_capabilities, old_timers) = self.state.lock()
+            .map(|mut state| (state.capabilities.clone(), std::mem::take(&mut state.timers)))
+            .unwrap_or_default();
+
+        let flags = check_exports(&mut plugin, &self.state, &self.name);
+
+        // New exports might have changed in the meantime
+        let (interval, subscriptions, placement) = probe_exports(&mut plugin, &self.name,
+                                                                 self.config_interval, self.config_placement);
+
+        self.teardown();
+
+        let old_plugin = self.plugin.replace(plugin);
+        let old_flags = self.flags.replace(flags);
+        let old_interval = self.interval.replace(interval);
+        let old_subscriptions = self.subscriptions.replace(subscriptions);
+        let old_placement = self.placement.replace(placement);
+
+        if !self.init() {
+            // Restore the old instance and init it again after the teardown
+            drop(self.plugin.replace(old_plugin));
+            self.flags.set(old_flags);
+            self.interval.set(old_interval);
+            self.subscriptions.set(old_subscriptions);
+            self.placement.set(old_placement);
+
+            if let Ok(mut state) = self.state.lock() {
+                state.capabilities = old_capabilities;
+                state.timers = old_timers;
+            }
+
+            self.init();
+
+            return Err(anyhow!("Init of reloaded plugin failed"));
+        }
+
+        // Run right away to replace the old output
+        self.failures.set(0);
+        self.retry_at.set(None);
+        self.next_update.set(Some(Instant::now()));
+
+        debug!("{}: plugin={}, url={}, flags={:?}, interval={:?}, subscriptions={:?}, placement={:?}",
+            function_name!(), self.name, self.url, flags, interval, subscriptions, placement);
+
+        Ok(())
+    }
+
+    /// Get the output of the last run for the given panel
+    ///
+    /// # Arguments
+    ///
+    /// * `panel_id` - Panel as screen index and bottom flag
+    ///
+    /// # Returns
+    ///
+    /// Either [`Some`] with the output or otherwise [`None`]
+    pub(crate) fn output(&self, panel_id: (usize, bool)) -> Option<RunOutput> {
+        self.outputs.borrow().get(&panel_id).cloned()
+    }
+
+    /// Get the minimum width set by the plugin
+    ///
+    /// # Returns
+    ///
+    /// The minimum width in cells or zero when unset
+    pub(crate) fn min_width_cells(&self) -> u16 {
+        self.state.lock().map(|state| state.min_width_cells).unwrap_or_default()
+    }
+
+    /// Get the current tooltip of the plugin
+    ///
+    /// # Returns
+    ///
+    /// A [`String`] with the tooltip or empty when unset
+    pub(crate) fn tooltip(&self) -> String {
+        self.state.lock().map(|state| state.tooltip.clone()).unwrap_or_default()
+    }
+
+    /// Check whether the plugin is due for an update
+    ///
+    /// # Arguments
+    ///
+    /// * `now` - Current time
+    ///
+    /// # Returns
+    ///
+    /// Either [`true`] when the plugin is due or otherwise [`false`]
+    pub(crate) fn is_due(&self, now: Instant) -> bool {
+        self.next_update.get().is_some_and(|next_update| next_update <= now)
+    }
+
+    /// Request an update for subscribed events and throttle bursts
+    ///
+    /// # Arguments
+    ///
+    /// * `events` - Events that happened
+    /// * `now` - Current time
+    pub(crate) fn notify(&self, events: PluginEvents, now: Instant) {
+        if !self.subscriptions.get().intersects(events) {
+            return;
+        }
+
+        // Run immediately unless the last run is too recent or has failed
+        let due = self.last_run.get()
+            .map_or(now, |last_run| (last_run + EVENT_DEBOUNCE).max(now));
+        let due = self.retry_at.get().map_or(due, |retry_at| retry_at.max(due));
+
+        self.next_update.set(Some(self.next_update.get().map_or(due, |next_update| next_update.min(due))));
+    }
+
+    /// Schedule next update based on the interval
+    ///
+    /// # Arguments
+    ///
+    /// * `now` - Current time
+    pub(crate) fn schedule(&self, now: Instant) {
+        self.failures.set(0);
+        self.retry_at.set(None);
+        self.last_run.set(Some(now));
+        self.next_update.set(if self.interval.get().is_zero() {
+            None
+        } else {
+            Some(now + self.interval.get())
+        });
+    }
+
+    /// Delay the next update after a failed run
+    ///
+    /// # Arguments
+    ///
+    /// * `now` - Current time
+    pub(crate) fn backoff(&self, now: Instant) {
+        self.failures.set(self.failures.get().saturating_add(1));
+
+        let retry_at = now + calc_backoff(self.interval.get(), self.failures.get());
+
+        self.retry_at.set(Some(retry_at));
+        self.last_run.set(Some(now));
+        self.next_update.set(if self.interval.get().is_zero() {
+            None
+        } else {
+            Some(retry_at)
+        });
+
+        debug!("{}: plugin={}, failures={}, retry_at={:?}",
+            function_name!(), self.name, self.failures.get(), retry_at);
+    }
+}
+
+impl fmt::Display for Plugin {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "name={}, interval={:?}", self.name, self.interval.get())
+    }
+}
+
+/// Default value of the markup field of the envelope
+///
+/// # Returns
+///
+/// Always [`true`]
+fn default_markup() -> bool {
+    true
+}
+
+/// Parse output of the run method either as envelope or as plain text
+///
+/// # Arguments
+///
+/// * `output` - Output of the plugin
+///
+/// # Returns
+///
+/// A [`RunOutput`] with version zero for plain text
+pub(crate) fn parse_run_output(output: &str) -> RunOutput {
+    // Only objects with a known version are envelopes, everything else is text
+    if output.trim_start().starts_with('{')
+        && let Ok(envelope) = serde_json::from_str::<RunOutput>(output)
+    {
+        if (1..=OUTPUT_VERSION).contains(&envelope.version) {
+            return envelope;
+        }
+
+        warn!("Unsupported plugin output version ({})", envelope.version);
+    }
+
+    RunOutput {
+        version: 0,
+        text: output.to_string(),
+        markup: true,
+        min_width: 0,
+        align: None,
+        click_actions: HashMap::new(),
+        failed: false,
+    }
+}
+
+/// Create the output shown in place of a failed run
+///
+/// # Arguments
+///
+/// * `last_output` - Output of the last successful run if any
+/// * `error_text` - Text to show as error
+/// * `color` - Optional color of the error text
+/// * `keep_on_error` - Whether to keep the last output with the error text as marker
+///
+/// # Returns
+///
+/// A [`RunOutput`] marked as failed
+pub(crate) fn error_output(last_output: Option<&RunOutput>, error_text: &str,
+                           color: Option<&str>, keep_on_error: bool) -> RunOutput
+{
+    let marker = match color {
+        Some(color) => format!("<fg={}>{}</fg>", color, markup::escape(error_text)),
+        None => markup::escape(error_text),
+    };
+
+    let text = match last_output {
+        Some(last_output) if keep_on_error && last_output.markup => format!("{} {}", last_output.text, marker),
+        Some(last_output) if keep_on_error => format!("{} {}", markup::escape(&last_output.text), marker),
+        _ => marker,
+    };
+
+    // Keep the reserved width, so the panel doesn't jump
+    RunOutput {
+        version: 0,
+        text,
+        markup: true,
+        min_width: last_output.map_or(0, |last_output| last_output.min_width),
+        align: last_output.and_then(|last_output| last_output.align),
+        click_actions: last_output.map(|last_output| last_output.click_actions.clone()).unwrap_or_default(),
+        failed: true,
+    }
+}
+
+/// Calculate the delay until failed runs are retried
+///
+/// # Arguments
+///
+/// * `interval` - Update interval of the plugin
+/// * `failures` - Number of consecutive failed runs
+///
+/// # Returns
+///
+/// The delay, which doubles with each failure and is capped to the larger of the interval and [`MAX_BACKOFF`]
+pub(crate) fn calc_backoff(interval: Duration, failures: u32) -> Duration {
+    let factor = 1u32 << failures.saturating_sub(1).min(16);
+
+    interval.max(MIN_BACKOFF)
+        .saturating_mul(factor)
+        .min(interval.max(MAX_BACKOFF))
+}
+
+/// Convert config value to string and encode non-scalar values as JSON
+///
+/// # Arguments
+///
+/// * `value` - Config value to convert
+///
+/// # Returns
+///
+/// A [`String`] with the converted value
+fn config_value_to_string(value: &MixedConfigVal) -> String {
+    match value {
+        MixedConfigVal::S(_) | MixedConfigVal::I(_) | MixedConfigVal::F(_) | MixedConfigVal::B(_) => String::from(value),
+        _ => serde_json::to_string(value).unwrap_or_default(),
+    }
+}
+
+/// Collect the whitelisted global config values
+///
+/// # Arguments
+///
+/// * `config` - Config values read either from args or config file
+///
+/// # Returns
+///
+/// A [`HashMap`] with the config values as strings
+fn collect_config_values(config: &Config) -> HashMap<String, String> {
+    let mut config_values: HashMap<String, String> = config.subtle.iter()
+        .filter(|(key, _)| CONFIG_KEYS.contains(&key.as_str()))
+        .map(|(key, value)| (key.clone(), config_value_to_string(value)))
+        .collect();
+
+    // The separator text is set in the separator style
+    if let Some(MixedConfigVal::S(separator)) = config.styles.iter()
+        .find(|style| matches!(style.get("kind"), Some(MixedConfigVal::S(kind)) if "separator" == kind))
+        .and_then(|style| style.get("separator"))
+    {
+        config_values.insert(String::from("panel.separator"), separator.clone());
+    }
+
+    config_values
+}
+
+/// Look up a global config value
+///
+/// # Arguments
+///
+/// * `config_values` - Collected global config values
+/// * `key` - Key of the config value
+///
+/// # Returns
+///
+/// A [`String`] with the value or an empty string when unset or not whitelisted
+pub(crate) fn lookup_config_value(config_values: &HashMap<String, String>, key: &str) -> String {
+    if !CONFIG_KEYS.contains(&key) {
+        return String::new();
+    }
+
+    config_values.get(key).cloned().unwrap_or_default()
+}
+
+/// Check config and init all plugin related options
+///
+/// # Arguments
+///
+/// * `config` - Config values read either from args or config file
+/// * `subtle` - Global state object
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn init(config: &Config, subtle: &mut Subtle) -> Result<()> {
+    let config_values = collect_config_values(config);
+    let mut names: HashSet<String> = HashSet::new();
+
+    for values in config.plugins.iter() {
+        let mut builder = PluginBuilder::default();
+
+        if let Some(MixedConfigVal::S(value)) = values.get("name") {
+            builder.name(value.to_string());
+        }
+
+        if let Some(MixedConfigVal::S(value)) = values.get("url") {
+            builder.url(value.to_string());
+        }
+
+        if let Some(MixedConfigVal::I(value)) = values.get("interval") {
+            builder.interval(*value);
+        }
+
+        if let Some(MixedConfigVal::B(value)) = values.get("allow_exec") {
+            builder.allow_exec(*value);
+        }
+
+        if let Some(MixedConfigVal::VS(value)) = values.get("allowed_hosts") {
+            builder.allowed_hosts(value.clone());
+        }
+
+        if let Some(MixedConfigVal::S(value)) = values.get("error_text") {
+            builder.error_text(value.to_string());
+        }
+
+        if let Some(MixedConfigVal::B(value)) = values.get("keep_on_error") {
+            builder.keep_on_error(*value);
+        }
+
+        builder.cache(subtle.plugin_cache.clone());
+        builder.config_values(config_values.clone());
+
+        if let Some(MixedConfigVal::S(value)) = values.get("align") {
+            match value.as_str() {
+                "left" => { builder.align(TextAlign::Left); },
+                "center" => { builder.align(TextAlign::Center); },
+                "right" => { builder.align(TextAlign::Right); },
+                _ => warn!("Unknown plugin alignment `{}`", value),
+            }
+        }
+
+        if let Some(MixedConfigVal::S(value)) = values.get("side") {
+            match value.as_str() {
+                "left" => { builder.side(PanelSide::Left); },
+                "center" => { builder.side(PanelSide::Center); },
+                "right" => { builder.side(PanelSide::Right); },
+                _ => warn!("Unknown plugin side `{}`", value),
+            }
+        }
+
+        if let Some(MixedConfigVal::I(value)) = values.get("position") {
+            builder.position(*value);
+        }
+
+        if let Some(MixedConfigVal::MSS(values)) = values.get("config") {
+            let config: HashMap<String, String> = values.iter()
+                .map(|entry| (String::from(entry.0), config_value_to_string(entry.1)))
+                .collect();
+
+            builder.config(config);
+        }
+
+        // Panels refer to plugins by name, so each instance needs its own
+        if let Some(name) = builder.name.as_ref()
+            && !names.insert(name.clone())
+        {
+            warn!("Skipping plugin with duplicate name, each [[plugin]] needs a unique one ({})", name);
+
+            continue;
+        }
+
+        // Finally create actual plugin
+        let plugin = builder.build()?;
+
+        if !plugin.init() {
+            info!("Disabled plugin ({})", plugin.name);
+
+            continue;
+        }
+
+        info!("Loaded plugin ({})", plugin.name);
+
+        subtle.plugins.push(plugin);
+    }
+
+    debug!("{}", function_name!());
+
+    Ok(())
+}
+
+/// Collect info about the view of the given screen
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+/// * `screen_idx` - Screen index
+///
+/// # Returns
+///
+/// Either [`Some`] with the [`ViewInfo`] or otherwise [`None`]
+fn collect_view_info(subtle: &Subtle, screen_idx: usize) -> Option<ViewInfo> {
+    let view_idx = subtle.screens.get(screen_idx)?.view_idx.get();
+    let view = subtle.views.get(usize::try_from(view_idx).ok()?)?;
+
+    let tag_names = subtle.tags.iter().enumerate()
+        .filter(|(tag_idx, _)| view.tags.intersects(Tagging::from_bits_retain(1 << tag_idx)))
+        .map(|(_, tag)| tag.name.clone())
+        .collect();
+
+    let client_count = subtle.clients.borrow().iter()
+        .filter(|client| client.is_alive() && client.tags.intersects(view.tags))
+        .count();
+
+    Some(ViewInfo {
+        name: view.name.clone(),
+        index: view_idx as usize,
+        tag_names,
+        client_count,
+        urgent: subtle.urgent_tags.get().intersects(view.tags),
+    })
+}
+
+/// Summarize all views with the tags of the clients
+///
+/// # Arguments
+///
+/// * `views` - List of all views
+/// * `client_tags` - Tags of all alive clients
+/// * `urgent_tags` - Tags of all urgent clients
+/// * `active_idx` - Index of the view of the focused screen
+///
+/// # Returns
+///
+/// A [`Vec`] of [`ViewSummary`] in the order of the views
+pub(crate) fn summarize_views(views: &[View], client_tags: &[Tagging], urgent_tags: Tagging,
+                              active_idx: Option<usize>) -> Vec<ViewSummary>
+{
+    views.iter().enumerate()
+        .map(|(view_idx, view)| {
+            let client_count = client_tags.iter()
+                .filter(|tags| tags.intersects(view.tags))
+                .count();
+
+            ViewSummary {
+                name: view.name.clone(),
+                index: view_idx,
+                active: Some(view_idx) == active_idx,
+                occupied: 0 < client_count,
+                urgent: urgent_tags.intersects(view.tags),
+                client_count,
+            }
+        })
+        .collect()
+}
+
+/// Collect info about the clients on the view of the given screen
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+/// * `screen_idx` - Screen index
+///
+/// # Returns
+///
+/// A [`Vec`] of [`ClientInfo`] in the order of the window manager
+fn collect_client_info(subtle: &Subtle, screen_idx: usize) -> Vec<ClientInfo> {
+    let Some(view) = subtle.screens.get(screen_idx)
+        .and_then(|screen| usize::try_from(screen.view_idx.get()).ok())
+        .and_then(|view_idx| subtle.views.get(view_idx)) else {
+        return Vec::new();
+    };
+
+    let focus_win = subtle.find_focus_win();
+
+    subtle.clients.borrow().iter()
+        .filter(|client| client.is_alive()
+            && (client.tags.intersects(view.tags) || client.flags.intersects(ClientFlags::MODE_STICK))
+            && !client.flags.intersects(ClientFlags::TYPE_DESKTOP | ClientFlags::TYPE_DOCK))
+        .map(|client| ClientInfo {
+            id: client.win,
+            title: sanitize_text(&client.name, MAX_TITLE_LENGTH),
+            class: sanitize_text(&client.klass, MAX_TITLE_LENGTH),
+            focused: client.win == focus_win,
+            urgent: client.flags.intersects(ClientFlags::MODE_URGENT),
+            minimized: false,
+        })
+        .collect()
+}
+
+/// Collect position of the pointer and the window below
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+///
+/// # Returns
+///
+/// Either [`Some`] with the [`PointerInfo`] or otherwise [`None`] when the pointer cannot be queried
+fn collect_pointer(subtle: &Subtle) -> Option<PointerInfo> {
+    let conn = subtle.conn.get()?;
+
+    let default_screen = &conn.setup().roots[subtle.screen_num];
+
+    let reply = conn.query_pointer(default_screen.root).ok()?.reply().ok()?;
+
+    // Only report pointers on our screen
+    if !reply.same_screen {
+        return None;
+    }
+
+    let window_id = (NONE != reply.child).then_some(reply.child);
+
+    Some(PointerInfo {
+        x: reply.root_x,
+        y: reply.root_y,
+        monitor: subtle.find_screen_by_xy(reply.root_x, reply.root_y).map(|(screen_idx, _)| screen_idx),
+        window_id,
+        window_class: window_id.and_then(|win| subtle.find_client(win))
+            .map(|client| sanitize_text(&client.klass, MAX_TITLE_LENGTH)),
+    })
+}
+
+/// Get the pointer of the current cycle and query it only once for all plugins
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+///
+/// # Returns
+///
+/// Either [`Some`] with the [`PointerInfo`] or otherwise [`None`] when the pointer cannot be queried
+fn cached_pointer(subtle: &Subtle) -> Option<PointerInfo> {
+    match subtle.plugin_cache.lock() {
+        Ok(mut cache) => cache.pointer_or_insert_with(|| collect_pointer(subtle)),
+        Err(_) => collect_pointer(subtle),
+    }
+}
+
+/// Get the cached keyboard layout and query it only after it has changed
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+///
+/// # Returns
+///
+/// Either [`Some`] with the [`KeyboardLayout`] or otherwise [`None`] when the layout cannot be queried
+fn cached_keyboard_layout(subtle: &Subtle) -> Option<KeyboardLayout> {
+    match subtle.plugin_cache.lock() {
+        Ok(mut cache) => cache.keyboard_layout_or_insert_with(|| keyboard::query_layout(subtle).ok()),
+        Err(_) => keyboard::query_layout(subtle).ok(),
+    }
+}
+
+/// Drop the cached keyboard layout after Xkb reported a change
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+pub(crate) fn invalidate_keyboard_layout(subtle: &Subtle) {
+    if let Ok(mut cache) = subtle.plugin_cache.lock() {
+        cache.keyboard_layout = None;
+    }
+
+    debug!("{}", function_name!());
+}
+
+/// Drop the pointer of the current cycle, so input events see where the pointer is now
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+fn invalidate_pointer(subtle: &Subtle) {
+    if let Ok(mut cache) = subtle.plugin_cache.lock() {
+        cache.pointer = None;
+    }
+}
+
+/// Collect the colors of the current styles
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+///
+/// # Returns
+///
+/// A [`ThemeColors`] with hex color strings
+fn collect_theme(subtle: &Subtle) -> ThemeColors {
+    // Plugin items are drawn with the views style
+    let accents = [
+        ("active", &subtle.views_active_style),
+        ("occupied", &subtle.views_occupied_style),
+        ("visible", &subtle.views_visible_style),
+        ("urgent", &subtle.urgent_style),
+        ("title", &subtle.title_style),
+        ("separator", &subtle.separator_style),
+    ].into_iter()
+        .filter_map(|(name, accent_style)| style::pixel_to_hex(accent_style.fg)
+            .map(|color| (name.to_string(), color)))
+        .collect();
+
+    ThemeColors {
+        foreground: style::pixel_to_hex(subtle.views_style.fg),
+        background: style::pixel_to_hex(subtle.views_style.bg),
+        border: style::pixel_to_hex(subtle.views_style.top),
+        accents,
+    }
+}
+
+/// Convert a minimum width in cells of the font to pixels
+///
+/// # Arguments
+///
+/// * `cells` - Minimum width in cells
+/// * `column_width` - Width of a single cell of the font
+/// * `spacing` - Horizontal spacing of the panel item
+///
+/// # Returns
+///
+/// The minimum width in pixels or `0` when no width is reserved
+pub(crate) fn calc_min_width(cells: u16, column_width: u16, spacing: u16) -> u16 {
+    if 0 == cells {
+        0
+    } else {
+        cells.saturating_mul(column_width).saturating_add(spacing)
+    }
+}
+
+/// Calculate the geometry of the top or bottom panel of a screen
+///
+/// # Arguments
+///
+/// * `base` - Base geometry of the screen
+/// * `panel_height` - Height of the panel
+/// * `is_bottom` - Whether the panel is at the bottom
+/// * `monitor_index` - Index of the screen
+/// * `monitor_count` - Total number of screens
+///
+/// # Returns
+///
+/// A [`PanelGeometry`] of the panel
+pub(crate) fn calc_panel_geometry(base: &Rectangle, panel_height: u16, is_bottom: bool,
+                                  monitor_index: usize, monitor_count: usize) -> PanelGeometry
+{
+    PanelGeometry {
+        x: base.x,
+        y: if is_bottom {
+            base.y + base.height as i16 - panel_height as i16
+        } else {
+            base.y
+        },
+        width: base.width,
+        height: panel_height,
+        monitor_index,
+        monitor_count,
+        is_bottom,
+    }
+}
+
+/// Find all panels that show the given plugin
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+/// * `plugin_idx` - Index of the plugin
+///
+/// # Returns
+///
+/// A [`Vec`] of panels as screen index and bottom flag
+fn find_plugin_panels(subtle: &Subtle, plugin_idx: usize) -> Vec<(usize, bool)> {
+    let mut panel_ids = Vec::new();
+
+    for (screen_idx, screen) in subtle.screens.iter().enumerate() {
+        for panel in screen.panels.iter() {
+            let panel_id = (screen_idx, panel.is_bottom);
+
+            // Items on the same panel share the geometry
+            if panel.flags.intersects(PanelFlags::PLUGIN) && plugin_idx == panel.plugin_idx
+                && !panel_ids.contains(&panel_id)
+            {
+                panel_ids.push(panel_id);
+            }
+        }
+    }
+
+    panel_ids
+}
+
+/// Collect snapshot of the window manager state
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+/// * `panel_id` - Calling panel as screen index and bottom flag
+///
+/// # Returns
+///
+/// A [`HostContext`] with the current state
+pub(crate) fn collect_context(subtle: &Subtle, panel_id: (usize, bool)) -> HostContext {
+    // Prefer screen of the focus client
+    let screen_idx = subtle.find_focus_client()
+        .and_then(|client| usize::try_from(client.screen_idx).ok())
+        .unwrap_or(0);
+
+    HostContext {
+        current_view: collect_view_info(subtle, screen_idx),
+        panel: subtle.screens.get(panel_id.0).map(|screen| calc_panel_geometry(&screen.base,
+            subtle.panel_height, panel_id.1, panel_id.0, subtle.screens.len())),
+        view_names: subtle.views.iter().map(|view| view.name.clone()).collect(),
+        views: summarize_views(&subtle.views,
+            &subtle.clients.borrow().iter()
+                .filter(|client| client.is_alive())
+                .map(|client| client.tags)
+                .collect::<Vec<Tagging>>(),
+            subtle.urgent_tags.get(),
+            subtle.screens.get(screen_idx).and_then(|screen| usize::try_from(screen.view_idx.get()).ok())),
+        clients: collect_client_info(subtle, screen_idx),
+        theme: collect_theme(subtle),
+        keyboard_layout: cached_keyboard_layout(subtle),
+        pointer: cached_pointer(subtle),
+        selections: HashMap::new(),
+    }
+}
+
+/// Run all plugins that are due
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+///
+/// # Returns
+///
+/// A [`Result`] with either [`bool`] whether any plugin has been updated on success or otherwise [`anyhow::Error`]
+pub(crate) fn update(subtle: &Subtle) -> Result<bool> {
+    let now = Instant::now();
+    let mut updated = false;
+
+    // Start each cycle with fresh values
+    if let Ok(mut cache) = subtle.plugin_cache.lock() {
+        cache.clear();
+    }
+
+    let error_color = style::pixel_to_hex(subtle.urgent_style.fg);
+
+    // Refresh the selections for the next run, answers arrive as events
+    if subtle.plugins.iter().any(|plugin| plugin.flags.get().intersects(PluginFlags::CLIPBOARD) && plugin.is_due(now))
+        && let Err(err) = selection::request(subtle)
+    {
+        warn!("Cannot request selections: {}", err);
+    }
+
+    // Run plugins again once their background jobs have finished
+    for plugin in subtle.plugins.iter() {
+        plugin.poll_jobs(now);
+    }
+
+    // Timers have no panel, so just use the first one of the plugin
+    for (plugin_idx, plugin) in subtle.plugins.iter().enumerate() {
+        let panel_id = find_plugin_panels(subtle, plugin_idx).first().copied().unwrap_or((0, false));
+
+        if let Err(err) = plugin.fire_timers(subtle, panel_id, now) {
+            warn!("Cannot run timers of plugin ({}): {}", plugin.name, err);
+
+            plugin.backoff(now);
+        }
+    }
+
+    for (plugin_idx, plugin) in subtle.plugins.iter().enumerate().filter(|(_, plugin)| plugin.is_due(now)) {
+        let mut has_failed = false;
+
+        plugin.begin_job_call();
+
+        // Run once per panel, so each one gets its own geometry and the same job results
+        for panel_id in find_plugin_panels(subtle, plugin_idx) {
+            match plugin.update(subtle, panel_id) {
+                Ok(res) => {
+                    plugin.outputs.borrow_mut().insert(panel_id, res);
+
+                    updated = true;
+                },
+                Err(err) => {
+                    warn!("Cannot update plugin ({}): {}", plugin.name, err);
+
+                    has_failed = true;
+
+                    // Replace the output only once to keep the last good text
+                    let mut outputs = plugin.outputs.borrow_mut();
+
+                    if !outputs.get(&panel_id).is_some_and(|output| output.failed) {
+                        let output = error_output(outputs.get(&panel_id), &plugin.error_text,
+                                                  error_color.as_deref(), plugin.keep_on_error);
+
+                        outputs.insert(panel_id, output);
+
+                        updated = true;
+                    }
+                },
+            }
+        }
+
+        // Results are meant for the run right after the job has finished, later calls start again
+        plugin.drop_job_results();
+
+        if has_failed {
+            plugin.backoff(now);
+        } else {
+            plugin.schedule(now);
+        }
+    }
+
+    debug!("{}: updated={}", function_name!(), updated);
+
+    Ok(updated)
+}
+
+/// Notify all plugins about events
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+/// * `events` - Events that happened
+pub(crate) fn notify(subtle: &Subtle, events: PluginEvents) {
+    let now = Instant::now();
+
+    for plugin in subtle.plugins.iter() {
+        plugin.notify(events, now);
+    }
+
+    debug!("{}: events={:?}", function_name!(), events);
+}
+
+/// Calculate the time until the next plugin is due
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+///
+/// # Returns
+///
+/// Either [`Some`] with the timeout or [`None`] when no plugin is scheduled
+pub(crate) fn next_timeout(subtle: &Subtle) -> Option<Duration> {
+    let now = Instant::now();
+
+    subtle.plugins.iter()
+        .flat_map(|plugin| [plugin.next_update.get(), plugin.next_timer(), plugin.next_job_poll(now)])
+        .flatten()
+        .min()
+        .map(|next_update| next_update.saturating_duration_since(now))
+}
+
+/// Run all window manager commands queued by plugins
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+pub(crate) fn dispatch(subtle: &Subtle) {
+    for plugin in subtle.plugins.iter() {
+        let commands = plugin.state.lock()
+            .map(|mut state| std::mem::take(&mut state.commands))
+            .unwrap_or_default();
+
+        for (flag, action, (x, y)) in commands {
+            if let Err(err) = event::handle_grab(subtle, flag, &action, x, y) {
+                warn!("Cannot run command of plugin ({}): {}", plugin.name, err);
+            }
+
+            // Update the indicator right away
+            if GrabFlags::KEYBOARD_LAYOUT == flag {
+                plugin.next_update.set(Some(Instant::now()));
+            }
+        }
+
+        let selection_requests = plugin.state.lock()
+            .map(|mut state| std::mem::take(&mut state.selection_requests))
+            .unwrap_or_default();
+
+        for (kind, text) in selection_requests {
+            if let Err(err) = selection::set(subtle, kind, &text) {
+                warn!("Cannot set selection of plugin ({}): {}", plugin.name, err);
+            }
+        }
+    }
+}
+
+/// Reload plugins from disk and keep the old instances of failed ones
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+/// * `name` - Name of the plugin or empty for all plugins
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn reload(subtle: &Subtle, name: &str) -> Result<()> {
+    if !name.is_empty() && !subtle.plugins.iter().any(|plugin| plugin.name.eq(name)) {
+        return Err(anyhow!("Unknown plugin `{}`", name));
+    }
+
+    for (plugin_idx, plugin) in subtle.plugins.iter().enumerate()
+        .filter(|(_, plugin)| name.is_empty() || plugin.name.eq(name))
+    {
+        match plugin.reload() {
+            Ok(_) => {
+                // Move the panel items when the placement has changed
+                screen::place_plugin_panels(subtle, plugin_idx);
+
+                info!("Reloaded plugin ({})", plugin.name);
+            },
+            Err(err) => warn!("Cannot reload plugin, keeping old instance ({}): {}", plugin.name, err),
+        }
+    }
+
+    debug!("{}: name={}", function_name!(), name);
+
+    Ok(())
+}
+
+/// Tidy up afterwards
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+pub(crate) fn finish(subtle: &Subtle) {
+    for plugin in subtle.plugins.iter() {
+        plugin.teardown();
+    }
+
+    debug!("{}", function_name!());
+}
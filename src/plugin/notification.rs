@@ -0,0 +1,167 @@
+//!
+//! @package subtle-rs
+//!
+//! @file Plugin notification functions
+//! @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+//! @version $Id$
+//!
+//! This program can be distributed under the terms of the GNU GPLv3.
+//! See the file LICENSE for details.
+//!
+
+use std::time::Duration;
+use anyhow::{anyhow, Result};
+use log::warn;
+use serde::{Deserialize, Serialize};
+use crate::plugin::command::run_command;
+
+/// Timeout of notification daemon calls
+const NOTIFY_TIMEOUT: Duration = Duration::from_millis(1000);
+
+#[derive(Debug, Deserialize)]
+pub(crate) struct NotifyRequest {
+    /// Summary of the notification
+    pub(crate) summary: String,
+    /// Body of the notification
+    #[serde(default)]
+    pub(crate) body: String,
+    /// Urgency (low, normal or critical)
+    #[serde(default = "default_urgency")]
+    pub(crate) urgency: String,
+    /// Expiration timeout in milliseconds; server default when unset
+    pub(crate) timeout_ms: Option<i32>,
+    /// Icon name or path
+    #[serde(default)]
+    pub(crate) icon: String,
+}
+
+#[repr(i32)]
+#[derive(Debug, Copy, Clone, PartialEq)]
+pub(crate) enum NotifyError {
+    /// No notification daemon is running
+    NotAvailable = 1,
+    /// Request is invalid
+    InvalidRequest = 2,
+}
+
+#[derive(Default, Debug, Serialize)]
+pub(crate) struct NotifyResult {
+    /// Id of the notification
+    pub(crate) id: u32,
+    /// Zero on success or otherwise the error code
+    pub(crate) code: i32,
+    /// Error message on failure
+    pub(crate) error: Option<String>,
+    /// Whether the call is still running in the background
+    pub(crate) pending: bool,
+}
+
+/// Default urgency of notifications
+///
+/// # Returns
+///
+/// A [`String`] with the urgency
+fn default_urgency() -> String {
+    "normal".into()
+}
+
+/// Parse urgency of notifications into the level of the spec
+///
+/// # Arguments
+///
+/// * `urgency` - Urgency to parse
+///
+/// # Returns
+///
+/// Either [`Some`] with the level or otherwise [`None`] when unknown
+pub(crate) fn parse_urgency(urgency: &str) -> Option<u8> {
+    match urgency {
+        "low" => Some(0),
+        "normal" => Some(1),
+        "critical" => Some(2),
+        _ => None,
+    }
+}
+
+/// Quote and escape text as GVariant string
+///
+/// # Arguments
+///
+/// * `text` - Text to escape
+///
+/// # Returns
+///
+/// A [`String`] with the quoted text
+pub(crate) fn escape_gvariant_string(text: &str) -> String {
+    let mut escaped = String::with_capacity(text.len() + 2);
+
+    escaped.push('"');
+
+    for ch in text.chars() {
+        match ch {
+            '"' => escaped.push_str("\\\""),
+            '\\' => escaped.push_str("\\\\"),
+            '\n' => escaped.push_str("\\n"),
+            '\t' => escaped.push_str("\\t"),
+            ch if ch.is_control() => {},
+            ch => escaped.push(ch),
+        }
+    }
+
+    escaped.push('"');
+
+    escaped
+}
+
+/// Parse the notification id from the reply of `gdbus` like `(uint32 42,)`
+///
+/// # Arguments
+///
+/// * `output` - Output of the call
+///
+/// # Returns
+///
+/// Either [`Some`] with the id or otherwise [`None`]
+pub(crate) fn parse_notification_id(output: &str) -> Option<u32> {
+    output.trim()
+        .strip_prefix("(uint32 ")?
+        .strip_suffix(",)")?
+        .parse::<u32>().ok()
+}
+
+/// Call a method of the notification daemon via `gdbus`
+///
+/// # Arguments
+///
+/// * `method` - Name of the method
+/// * `args` - Arguments of the method in GVariant text format
+///
+/// # Returns
+///
+/// A [`Result`] with either the reply on success or otherwise [`anyhow::Error`]
+pub(crate) fn run_notification_call(method: &str, args: &[String]) -> Result<String> {
+    let mut call_args: Vec<String> = [
+        "call", "--session",
+        "--dest", "org.freedesktop.Notifications",
+        "--object-path", "/org/freedesktop/Notifications",
+        "--method",
+    ].into_iter().map(String::from).collect();
+
+    call_args.push(format!("org.freedesktop.Notifications.{}", method));
+    call_args.extend_from_slice(args);
+
+    // Don't block the event loop when no daemon can be activated
+    let output = run_command("gdbus", &call_args, NOTIFY_TIMEOUT)?;
+
+    if output.timed_out {
+        warn!("Notification daemon not responding after {}ms", NOTIFY_TIMEOUT.as_millis());
+
+        return Err(anyhow!("Notification daemon not responding"));
+    }
+
+    if 0 != output.exit_code {
+        return Err(anyhow!("Notification daemon not available: {}", output.stderr.trim()));
+    }
+
+    Ok(output.stdout)
+}
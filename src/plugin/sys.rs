@@ -0,0 +1,1495 @@
+//!
+//! @package subtle-rs
+//!
+//! @file Plugin system information functions
+//! @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+//! @version $Id$
+//!
+//! This program can be distributed under the terms of the GNU GPLv3.
+//! See the file LICENSE for details.
+//!
+
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+use std::time::{Duration, Instant};
+use anyhow::{anyhow, Context, Result};
+use log::{debug, warn};
+use stdext::function_name;
+use serde::{Deserialize, Serialize};
+use crate::plugin::command::run_helper;
+
+/// Timeout of sound server queries
+const VOLUME_TIMEOUT: Duration = Duration::from_millis(1000);
+
+/// Name of the default sink of the sound server
+pub(crate) const DEFAULT_SINK: &str = "@DEFAULT_SINK@";
+
+/// Timeout of media player queries
+const MEDIA_TIMEOUT: Duration = Duration::from_millis(1000);
+
+/// Format of the media player status with tab separated fields
+const MEDIA_FORMAT: &str = "{{playerName}}\t{{lc(status)}}\t{{artist}}\t{{title}}\t{{album}}\t{{position}}\t{{mpris:length}}";
+
+/// Timeout of wifi queries
+const WIFI_TIMEOUT: Duration = Duration::from_millis(1000);
+
+/// Maximum number of helpers of each plugin running in the background
+pub(crate) const MAX_HELPER_JOBS: usize = 4;
+
+/// Time after which the last result of a helper query is refreshed
+pub(crate) const HELPER_REFRESH: Duration = Duration::from_millis(500);
+
+/// Base path of the power supply class
+pub(crate) const POWER_SUPPLY_PATH: &str = "/sys/class/power_supply";
+
+/// Base path of the hwmon class
+pub(crate) const HWMON_PATH: &str = "/sys/class/hwmon";
+
+/// Base path of the backlight class
+pub(crate) const BACKLIGHT_PATH: &str = "/sys/class/backlight";
+
+/// Base path of the network class
+pub(crate) const NET_PATH: &str = "/sys/class/net";
+
+/// Base path of the process info
+pub(crate) const PROC_PATH: &str = "/proc";
+
+/// Clock ticks per second of the cpu times in `/proc`
+const CLOCK_TICKS: f64 = 100.0;
+
+/// Maximum length of process names in `/proc/<pid>/comm`
+const MAX_COMM_LEN: usize = 15;
+
+#[derive(Default, Debug, Serialize)]
+pub(crate) struct MemoryInfo {
+    /// Total memory in KiB
+    pub(crate) total_kb: u64,
+    /// Available memory in KiB
+    pub(crate) available_kb: u64,
+    /// Used memory (total minus available) in KiB
+    pub(crate) used_kb: u64,
+    /// Total swap in KiB
+    pub(crate) swap_total_kb: u64,
+    /// Used swap in KiB
+    pub(crate) swap_used_kb: u64,
+    /// All raw values of `/proc/meminfo` in KiB
+    pub(crate) raw: HashMap<String, u64>,
+}
+
+#[derive(Default, Debug, Copy, Clone, PartialEq, Serialize)]
+pub(crate) struct LoadAverage {
+    /// Load average of the last minute
+    pub(crate) one: f64,
+    /// Load average of the last five minutes
+    pub(crate) five: f64,
+    /// Load average of the last fifteen minutes
+    pub(crate) fifteen: f64,
+    /// Number of currently running processes
+    pub(crate) running_procs: u32,
+    /// Total number of processes
+    pub(crate) total_procs: u32,
+}
+
+#[derive(Default, Debug, Copy, Clone, PartialEq)]
+pub(crate) struct CpuTimes {
+    /// Busy jiffies
+    pub(crate) busy: u64,
+    /// Total jiffies
+    pub(crate) total: u64,
+}
+
+#[derive(Default, Debug, Serialize)]
+pub(crate) struct CpuUsage {
+    /// Busy percentage of all cores
+    pub(crate) aggregate: f64,
+    /// Busy percentage per core
+    pub(crate) cores: Vec<f64>,
+    /// Whether the values are averages since boot due to missing previous sample
+    pub(crate) warming_up: bool,
+}
+
+#[derive(Debug, Copy, Clone, PartialEq)]
+pub(crate) struct ProcSample {
+    /// Cpu time of the process in clock ticks
+    pub(crate) ticks: u64,
+    /// Time of the sample
+    pub(crate) time: Instant,
+}
+
+#[derive(Default, Debug, Clone, PartialEq)]
+pub(crate) struct ProcStat {
+    /// Name of the process
+    pub(crate) name: String,
+    /// State of the process
+    pub(crate) state: String,
+    /// Cpu time of the process in clock ticks
+    pub(crate) ticks: u64,
+}
+
+#[derive(Default, Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub(crate) struct ProcCounters {
+    /// Id of the process
+    pub(crate) pid: u32,
+    /// Cpu time of the process in clock ticks
+    pub(crate) ticks: u64,
+    /// Resident memory in kilobytes
+    pub(crate) rss_kb: u64,
+    /// State like running, sleeping or zombie
+    pub(crate) state: String,
+}
+
+#[derive(Default, Debug, Clone, PartialEq, Serialize)]
+pub(crate) struct ProcessInfo {
+    /// Id of the process
+    pub(crate) pid: u32,
+    /// Name of the process
+    pub(crate) name: String,
+    /// Busy percentage of one core since the last call
+    pub(crate) cpu_percent: f64,
+    /// Resident memory in kilobytes
+    pub(crate) rss_kb: u64,
+    /// State like running, sleeping or zombie
+    pub(crate) state: String,
+    /// Whether the cpu percentage is zero due to missing previous sample
+    pub(crate) warming_up: bool,
+}
+
+#[derive(Debug, Copy, Clone, PartialEq)]
+pub(crate) struct NetSample {
+    /// Received bytes
+    pub(crate) rx_bytes: u64,
+    /// Transmitted bytes
+    pub(crate) tx_bytes: u64,
+    /// Time of the sample
+    pub(crate) time: Instant,
+}
+
+#[derive(Default, Debug, Serialize)]
+pub(crate) struct NetThroughput {
+    /// Name of the interface
+    pub(crate) iface: String,
+    /// Whether the interface exists
+    pub(crate) present: bool,
+    /// Received bytes per second
+    pub(crate) rx_bytes_per_sec: f64,
+    /// Transmitted bytes per second
+    pub(crate) tx_bytes_per_sec: f64,
+    /// Total received bytes
+    pub(crate) rx_total: u64,
+    /// Total transmitted bytes
+    pub(crate) tx_total: u64,
+    /// Whether the rates are zero due to missing previous sample
+    pub(crate) warming_up: bool,
+}
+
+#[derive(Default, Debug, Clone, PartialEq, Serialize)]
+pub(crate) struct WifiStatus {
+    /// Name of the interface
+    pub(crate) iface: String,
+    /// Whether the interface is associated with an access point
+    pub(crate) connected: bool,
+    /// Name of the network
+    pub(crate) ssid: String,
+    /// Signal quality in percent
+    pub(crate) signal_percent: u8,
+    /// Receive bitrate in MBit/s
+    pub(crate) bitrate_mbps: f64,
+    /// Frequency of the channel in MHz
+    pub(crate) frequency_mhz: u32,
+    /// Whether the status is outdated until the helper running in the background is done
+    pub(crate) pending: bool,
+}
+
+#[derive(Default, Debug, Serialize)]
+pub(crate) struct DiskUsage {
+    /// Total size of the filesystem in bytes
+    pub(crate) total_bytes: u64,
+    /// Used bytes
+    pub(crate) used_bytes: u64,
+    /// Bytes available to unprivileged users
+    pub(crate) available_bytes: u64,
+    /// Used percentage without the reserved blocks like `df`
+    pub(crate) percent: u8,
+    /// Error message when the path cannot be read
+    pub(crate) error: Option<String>,
+}
+
+#[derive(Default, Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub(crate) struct VolumeState {
+    /// Whether a sound server could be reached
+    pub(crate) present: bool,
+    /// Volume of the default sink in percent
+    pub(crate) percent: u8,
+    /// Whether the default sink is muted
+    pub(crate) muted: bool,
+    /// Error message when the sound server cannot be queried or set
+    pub(crate) error: Option<String>,
+    /// Whether the state is outdated until the helper running in the background is done
+    pub(crate) pending: bool,
+}
+
+#[derive(Default, Debug, Deserialize)]
+pub(crate) struct VolumeRequest {
+    /// Absolute volume in percent
+    pub(crate) percent: Option<i32>,
+    /// Relative change of the volume in percent
+    pub(crate) delta: Option<i32>,
+    /// Whether to toggle the mute state
+    #[serde(default)]
+    pub(crate) toggle_mute: bool,
+}
+
+#[derive(Default, Debug, Clone, PartialEq, Serialize)]
+pub(crate) struct BrightnessStatus {
+    /// Whether a backlight device could be found
+    pub(crate) present: bool,
+    /// Name of the device (e.g. intel_backlight)
+    pub(crate) name: Option<String>,
+    /// Brightness in percent
+    pub(crate) percent: u8,
+    /// Raw brightness value of the device
+    pub(crate) raw: u32,
+    /// Maximum raw brightness value of the device
+    pub(crate) max_raw: u32,
+    /// Error message when the brightness cannot be set
+    pub(crate) error: Option<String>,
+}
+
+#[derive(Default, Debug, Deserialize)]
+pub(crate) struct BrightnessRequest {
+    /// Name of the device or empty to use the first one
+    #[serde(default)]
+    pub(crate) device: String,
+    /// Absolute brightness in percent
+    pub(crate) percent: Option<i32>,
+    /// Relative change of the brightness in percent
+    pub(crate) delta: Option<i32>,
+}
+
+#[derive(Default, Debug, Clone, PartialEq, Serialize)]
+pub(crate) struct MailboxCount {
+    /// Path of the Maildir
+    pub(crate) path: String,
+    /// Whether the path is a Maildir
+    pub(crate) present: bool,
+    /// Number of messages in new
+    pub(crate) new: u32,
+    /// Number of new messages and messages in cur without seen flag
+    pub(crate) unread: u32,
+    /// Number of all messages
+    pub(crate) total: u32,
+}
+
+#[derive(Default, Debug, Clone, PartialEq, Serialize)]
+pub(crate) struct MailCount {
+    /// Number of messages in new of all accounts
+    pub(crate) new: u32,
+    /// Number of unread messages of all accounts
+    pub(crate) unread: u32,
+    /// Number of all messages of all accounts
+    pub(crate) total: u32,
+    /// Counts per account
+    pub(crate) accounts: Vec<MailboxCount>,
+}
+
+#[derive(Default, Debug, Clone, PartialEq, Serialize)]
+pub(crate) struct Temperature {
+    /// Label of the sensor (e.g. Package id 0)
+    pub(crate) label: String,
+    /// Name of the chip of the sensor (e.g. coretemp)
+    pub(crate) chip: String,
+    /// Current temperature in degree celsius
+    pub(crate) celsius: f64,
+    /// High threshold in degree celsius
+    pub(crate) high: Option<f64>,
+    /// Critical threshold in degree celsius
+    pub(crate) critical: Option<f64>,
+}
+
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub(crate) struct MediaStatus {
+    /// Name of the player or [`None`] when no player is running
+    pub(crate) player: Option<String>,
+    /// Playback status (playing, paused or stopped)
+    pub(crate) status: String,
+    /// Artist of the current track
+    pub(crate) artist: String,
+    /// Title of the current track
+    pub(crate) title: String,
+    /// Album of the current track
+    pub(crate) album: String,
+    /// Playback position in seconds
+    pub(crate) position_secs: Option<u64>,
+    /// Length of the current track in seconds
+    pub(crate) length_secs: Option<u64>,
+    /// Error message when the players cannot be queried
+    pub(crate) error: Option<String>,
+    /// Whether the status is outdated until the helper running in the background is done
+    pub(crate) pending: bool,
+}
+
+impl Default for MediaStatus {
+    fn default() -> Self {
+        Self {
+            player: None,
+            status: "stopped".into(),
+            artist: String::new(),
+            title: String::new(),
+            album: String::new(),
+            position_secs: None,
+            length_secs: None,
+            error: None,
+            pending: false,
+        }
+    }
+}
+
+#[derive(Debug, Deserialize)]
+pub(crate) struct MediaControl {
+    /// Action to run (play_pause, next or previous)
+    pub(crate) action: String,
+}
+
+#[derive(Default, Debug, Serialize)]
+pub(crate) struct BatteryStatus {
+    /// Whether a battery could be found
+    pub(crate) present: bool,
+    /// Name of the battery (e.g. BAT0)
+    pub(crate) name: Option<String>,
+    /// Charge level in percent
+    pub(crate) percent: Option<u8>,
+    /// Whether the battery is charging
+    pub(crate) charging: bool,
+    /// Estimated seconds until empty or full
+    pub(crate) time_remaining_secs: Option<u64>,
+    /// Current power draw in watts
+    pub(crate) power_draw_watts: Option<f64>,
+}
+
+/// Parse memory info from the content of `/proc/meminfo`
+///
+/// # Arguments
+///
+/// * `meminfo` - Content of `/proc/meminfo`
+///
+/// # Returns
+///
+/// A [`MemoryInfo`] with the parsed values
+pub(crate) fn parse_memory_info(meminfo: &str) -> MemoryInfo {
+    let raw: HashMap<String, u64> = meminfo.lines()
+        .filter_map(|line| line.split_once(':'))
+        .filter_map(|(key, value)| value.split_whitespace().next()
+            .and_then(|value| value.parse::<u64>().ok())
+            .map(|value| (key.trim().to_string(), value)))
+        .collect();
+
+    let value = |key: &str| raw.get(key).copied().unwrap_or(0);
+
+    // Free memory is misleading due to caches, so rely on available
+    let total_kb = value("MemTotal");
+    let available_kb = value("MemAvailable");
+    let swap_total_kb = value("SwapTotal");
+    let swap_free_kb = value("SwapFree");
+
+    MemoryInfo {
+        total_kb,
+        available_kb,
+        used_kb: total_kb.saturating_sub(available_kb),
+        swap_total_kb,
+        swap_used_kb: swap_total_kb.saturating_sub(swap_free_kb),
+        raw,
+    }
+}
+
+/// Parse load average from the content of `/proc/loadavg`
+///
+/// # Arguments
+///
+/// * `loadavg` - Content of `/proc/loadavg`
+///
+/// # Returns
+///
+/// Either [`Some`] with the [`LoadAverage`] or otherwise [`None`]
+pub(crate) fn parse_load_average(loadavg: &str) -> Option<LoadAverage> {
+    // Format is fixed by the kernel: 0.52 0.58 0.59 2/1234 56789
+    let mut fields = loadavg.split_whitespace();
+
+    let one = fields.next()?.parse::<f64>().ok()?;
+    let five = fields.next()?.parse::<f64>().ok()?;
+    let fifteen = fields.next()?.parse::<f64>().ok()?;
+    let (running_procs, total_procs) = fields.next()?.split_once('/')?;
+
+    Some(LoadAverage {
+        one,
+        five,
+        fifteen,
+        running_procs: running_procs.parse().ok()?,
+        total_procs: total_procs.parse().ok()?,
+    })
+}
+
+/// Parse seconds since boot from the content of `/proc/uptime`
+///
+/// # Arguments
+///
+/// * `uptime` - Content of `/proc/uptime`
+///
+/// # Returns
+///
+/// Either [`Some`] with the full seconds or otherwise [`None`]
+pub(crate) fn parse_uptime(uptime: &str) -> Option<u64> {
+    uptime.split_whitespace().next()?
+        .parse::<f64>().ok()
+        .map(|secs| secs as u64)
+}
+
+/// Parse cpu times from the content of `/proc/stat`
+///
+/// # Arguments
+///
+/// * `stat` - Content of `/proc/stat`
+///
+/// # Returns
+///
+/// A [`Vec`] of [`CpuTimes`] with the aggregate first and then each core
+pub(crate) fn parse_cpu_times(stat: &str) -> Vec<CpuTimes> {
+    stat.lines()
+        .filter(|line| line.starts_with("cpu"))
+        .map(|line| {
+            let values: Vec<u64> = line.split_whitespace()
+                .skip(1)
+                .map(|value| value.parse::<u64>().unwrap_or(0))
+                .collect();
+
+            // Guest times are already included in user and nice
+            let total: u64 = values.iter().take(8).sum();
+            let idle = values.get(3).copied().unwrap_or(0) + values.get(4).copied().unwrap_or(0);
+
+            CpuTimes {
+                busy: total.saturating_sub(idle),
+                total,
+            }
+        })
+        .collect()
+}
+
+/// Calculate busy percentage between two samples
+///
+/// # Arguments
+///
+/// * `prev_times` - Previous sample or empty on first call
+/// * `cur_times` - Current sample
+///
+/// # Returns
+///
+/// A [`CpuUsage`] with the percentages
+pub(crate) fn calc_cpu_usage(prev_times: &[CpuTimes], cur_times: &[CpuTimes]) -> CpuUsage {
+    let warming_up = prev_times.len() != cur_times.len();
+
+    let percentages: Vec<f64> = cur_times.iter().enumerate()
+        .map(|(idx, cur)| {
+            let prev = if warming_up { CpuTimes::default() } else { prev_times[idx] };
+
+            let busy = cur.busy.saturating_sub(prev.busy);
+            let total = cur.total.saturating_sub(prev.total);
+
+            if 0 == total { 0.0 } else { (busy as f64 / total as f64 * 100.0).clamp(0.0, 100.0) }
+        })
+        .collect();
+
+    CpuUsage {
+        aggregate: percentages.first().copied().unwrap_or(0.0),
+        cores: percentages.into_iter().skip(1).collect(),
+        warming_up,
+    }
+}
+
+/// Parse name, state and cpu time from the content of `/proc/<pid>/stat`
+///
+/// # Arguments
+///
+/// * `stat` - Content of `/proc/<pid>/stat`
+///
+/// # Returns
+///
+/// Either [`Some`] with the [`ProcStat`] or otherwise [`None`]
+pub(crate) fn parse_proc_stat(stat: &str) -> Option<ProcStat> {
+    // Names can contain spaces and parentheses, so split at the last one
+    let (head, tail) = stat.rsplit_once(')')?;
+    let (_, name) = head.split_once('(')?;
+
+    let values: Vec<&str> = tail.split_whitespace().collect();
+
+    // User and system time are the fields 14 and 15
+    let utime = values.get(11)?.parse::<u64>().ok()?;
+    let stime = values.get(12)?.parse::<u64>().ok()?;
+
+    let state = match *values.first()? {
+        "R" => "running",
+        "S" => "sleeping",
+        "D" => "disk_sleep",
+        "Z" => "zombie",
+        "T" => "stopped",
+        "t" => "tracing_stop",
+        "X" | "x" => "dead",
+        "I" => "idle",
+        _ => "unknown",
+    };
+
+    Some(ProcStat {
+        name: name.to_string(),
+        state: state.to_string(),
+        ticks: utime + stime,
+    })
+}
+
+/// Parse resident memory from the content of `/proc/<pid>/status`
+///
+/// # Arguments
+///
+/// * `status` - Content of `/proc/<pid>/status`
+///
+/// # Returns
+///
+/// Either [`Some`] with the kilobytes or otherwise [`None`] for kernel threads
+pub(crate) fn parse_vm_rss(status: &str) -> Option<u64> {
+    status.lines()
+        .find_map(|line| line.strip_prefix("VmRSS:"))?
+        .split_whitespace().next()?
+        .parse::<u64>().ok()
+}
+
+/// Calculate busy percentage of one core between two samples of a process
+///
+/// # Arguments
+///
+/// * `prev_sample` - Previous sample or [`None`] on first call
+/// * `cur_sample` - Current sample
+///
+/// # Returns
+///
+/// Either [`Some`] with the percentage or otherwise [`None`] without previous sample
+pub(crate) fn calc_proc_cpu(prev_sample: Option<&ProcSample>, cur_sample: &ProcSample) -> Option<f64> {
+    let prev_sample = prev_sample?;
+
+    let secs = cur_sample.time.saturating_duration_since(prev_sample.time).as_secs_f64();
+    let ticks = cur_sample.ticks.saturating_sub(prev_sample.ticks);
+
+    Some(if 0.0 == secs { 0.0 } else { (ticks as f64 / CLOCK_TICKS / secs * 100.0).max(0.0) })
+}
+
+/// Find processes by name and read their counters
+///
+/// # Arguments
+///
+/// * `base_path` - Base path of the process info
+/// * `name` - Name of the process
+///
+/// # Returns
+///
+/// A [`Vec`] of [`ProcCounters`] sorted by process id, which is empty when no process matches
+pub(crate) fn scan_processes(base_path: &Path, name: &str) -> Vec<ProcCounters> {
+    if name.is_empty() {
+        return Vec::new();
+    }
+
+    let mut counters: Vec<ProcCounters> = std::fs::read_dir(base_path).into_iter()
+        .flatten()
+        .flatten()
+        .filter_map(|entry| entry.file_name().to_str()?.parse::<u32>().ok())
+        .filter_map(|pid| {
+            let pid_path = base_path.join(pid.to_string());
+            let stat = parse_proc_stat(&std::fs::read_to_string(pid_path.join("stat")).ok()?)?;
+
+            // Names are truncated, so check the command line for longer ones
+            let is_match = stat.name == name || (MAX_COMM_LEN == stat.name.len() && name.starts_with(&stat.name)
+                && std::fs::read_to_string(pid_path.join("cmdline")).ok()
+                    .is_some_and(|cmdline| cmdline.split('\0').next()
+                        .and_then(|arg| arg.rsplit('/').next()) == Some(name)));
+
+            if !is_match {
+                return None;
+            }
+
+            Some(ProcCounters {
+                pid,
+                ticks: stat.ticks,
+                rss_kb: std::fs::read_to_string(pid_path.join("status")).ok()
+                    .and_then(|status| parse_vm_rss(&status))
+                    .unwrap_or(0),
+                state: stat.state,
+            })
+        })
+        .collect();
+
+    counters.sort_by_key(|counter| counter.pid);
+
+    debug!("{}: name={}, processes={}", function_name!(), name, counters.len());
+
+    counters
+}
+
+/// Calculate the info of processes from their counters and the previous samples
+///
+/// # Arguments
+///
+/// * `base_path` - Base path of the process info
+/// * `name` - Name of the process
+/// * `counters` - Current counters of the processes
+/// * `samples` - Previous cpu samples per process id; updated in place
+/// * `now` - Current time
+///
+/// # Returns
+///
+/// A [`Vec`] of [`ProcessInfo`] in the order of the counters
+pub(crate) fn calc_processes(base_path: &Path, name: &str, counters: &[ProcCounters],
+                             samples: &mut HashMap<u32, ProcSample>, now: Instant) -> Vec<ProcessInfo>
+{
+    // Drop samples of processes that are gone
+    samples.retain(|pid, _| base_path.join(pid.to_string()).is_dir());
+
+    counters.iter()
+        .map(|counter| {
+            let cur_sample = ProcSample {
+                ticks: counter.ticks,
+                time: now,
+            };
+
+            let cpu_percent = calc_proc_cpu(samples.get(&counter.pid), &cur_sample);
+
+            samples.insert(counter.pid, cur_sample);
+
+            ProcessInfo {
+                pid: counter.pid,
+                name: name.to_string(),
+                cpu_percent: cpu_percent.unwrap_or(0.0),
+                rss_kb: counter.rss_kb,
+                state: counter.state.clone(),
+                warming_up: cpu_percent.is_none(),
+            }
+        })
+        .collect()
+}
+
+/// Find the interface of the default route
+///
+/// # Arguments
+///
+/// * `route` - Content of `/proc/net/route`
+///
+/// # Returns
+///
+/// Either [`Some`] with the interface with the lowest metric or otherwise [`None`]
+pub(crate) fn parse_default_route(route: &str) -> Option<String> {
+    const RTF_UP: u32 = 0x1;
+
+    route.lines()
+        .skip(1)
+        .filter_map(|line| {
+            let fields: Vec<&str> = line.split_whitespace().collect();
+
+            // Iface Destination Gateway Flags RefCnt Use Metric ...
+            let flags = u32::from_str_radix(fields.get(3)?, 16).ok()?;
+            let metric = fields.get(6)?.parse::<u32>().ok()?;
+
+            ("00000000" == *fields.get(1)? && 0 != flags & RTF_UP)
+                .then(|| (metric, fields[0].to_string()))
+        })
+        .min()
+        .map(|(_, iface)| iface)
+}
+
+/// Find the first wireless interface
+///
+/// # Arguments
+///
+/// * `base_path` - Base path of the network class
+///
+/// # Returns
+///
+/// Either [`Some`] with the name of the interface or otherwise [`None`]
+pub(crate) fn find_wireless_iface(base_path: &Path) -> Option<String> {
+    let mut ifaces: Vec<String> = std::fs::read_dir(base_path).ok()?
+        .filter_map(|entry| entry.ok())
+        .filter(|entry| entry.path().join("wireless").is_dir())
+        .map(|entry| entry.file_name().to_string_lossy().into_owned())
+        .collect();
+
+    ifaces.sort();
+    ifaces.into_iter().next()
+}
+
+/// Convert signal strength to quality in percent
+///
+/// # Arguments
+///
+/// * `dbm` - Signal strength in dBm
+///
+/// # Returns
+///
+/// The quality in percent, where -100 dBm and below is 0 and -50 dBm and above is 100
+fn signal_to_percent(dbm: f64) -> u8 {
+    (2.0 * (dbm + 100.0)).clamp(0.0, 100.0).round() as u8
+}
+
+/// Parse the output of `iw dev <iface> link`
+///
+/// # Arguments
+///
+/// * `iface` - Name of the interface
+/// * `output` - Output of the command
+///
+/// # Returns
+///
+/// A [`WifiStatus`] which is marked as not connected when not associated
+pub(crate) fn parse_iw_link(iface: &str, output: &str) -> WifiStatus {
+    let mut status = WifiStatus {
+        iface: iface.to_string(),
+        ..WifiStatus::default()
+    };
+
+    if !output.starts_with("Connected to") {
+        return status;
+    }
+
+    status.connected = true;
+
+    for line in output.lines().skip(1) {
+        let Some((key, value)) = line.trim().split_once(':') else {
+            continue;
+        };
+
+        let value = value.trim();
+        let number = value.split_whitespace().next()
+            .and_then(|number| number.parse::<f64>().ok());
+
+        match key {
+            "SSID" => status.ssid = value.to_string(),
+            "freq" => status.frequency_mhz = number.map_or(0, |freq| freq.round() as u32),
+            "signal" => status.signal_percent = number.map_or(0, signal_to_percent),
+            "rx bitrate" => status.bitrate_mbps = number.unwrap_or(0.0),
+            _ => {},
+        }
+    }
+
+    status
+}
+
+/// Parse the link quality from `/proc/net/wireless`
+///
+/// # Arguments
+///
+/// * `iface` - Name of the interface
+/// * `wireless` - Content of `/proc/net/wireless`
+///
+/// # Returns
+///
+/// Either [`Some`] with the [`WifiStatus`] or otherwise [`None`] when the interface isn't listed
+pub(crate) fn parse_proc_wireless(iface: &str, wireless: &str) -> Option<WifiStatus> {
+    const MAX_LINK_QUALITY: f64 = 70.0;
+
+    let fields: Vec<&str> = wireless.lines()
+        .skip(2)
+        .map(|line| line.split_whitespace().collect::<Vec<&str>>())
+        .find(|fields| fields.first().is_some_and(|name| name.trim_end_matches(':') == iface))?;
+
+    // Iface Status Link Level Noise ...
+    let link = fields.get(2)?.trim_end_matches('.').parse::<f64>().ok()?;
+
+    Some(WifiStatus {
+        iface: iface.to_string(),
+        connected: 0.0 < link,
+        signal_percent: (link * 100.0 / MAX_LINK_QUALITY).clamp(0.0, 100.0).round() as u8,
+        ..WifiStatus::default()
+    })
+}
+
+/// Read status of the wireless interface
+///
+/// # Arguments
+///
+/// * `iface` - Name of the interface
+///
+/// # Returns
+///
+/// A [`WifiStatus`] which is marked as not connected for wired or downed interfaces
+pub(crate) fn read_wifi_status(iface: &str) -> WifiStatus {
+    let disconnected = WifiStatus {
+        iface: iface.to_string(),
+        ..WifiStatus::default()
+    };
+
+    // Keep names from escaping the class dir
+    if iface.is_empty() || iface.contains('/') || iface.starts_with('.') {
+        return disconnected;
+    }
+
+    let is_up = std::fs::read_to_string(Path::new(NET_PATH).join(iface).join("operstate"))
+        .is_ok_and(|operstate| "up" == operstate.trim());
+
+    if !is_up {
+        return disconnected;
+    }
+
+    // Prefer iw, which talks nl80211, and fall back to the wireless extensions
+    if let Some(output) = run_helper("iw", &["dev", iface, "link"], WIFI_TIMEOUT).ok()
+        .filter(|output| 0 == output.exit_code)
+    {
+        return parse_iw_link(iface, &output.stdout);
+    }
+
+    std::fs::read_to_string("/proc/net/wireless").ok()
+        .and_then(|wireless| parse_proc_wireless(iface, &wireless))
+        .unwrap_or(disconnected)
+}
+
+/// Read the byte counters of the interface
+///
+/// # Arguments
+///
+/// * `base_path` - Base path of the network class
+/// * `iface` - Name of the interface
+///
+/// # Returns
+///
+/// Either [`Some`] with the [`NetSample`] or otherwise [`None`] when the interface is missing
+pub(crate) fn read_net_sample(base_path: &Path, iface: &str) -> Option<NetSample> {
+    // Keep names from escaping the class dir
+    if iface.is_empty() || iface.contains('/') || iface.starts_with('.') {
+        return None;
+    }
+
+    let statistics_path = base_path.join(iface).join("statistics");
+
+    Some(NetSample {
+        rx_bytes: read_sysfs_value(&statistics_path, "rx_bytes")?,
+        tx_bytes: read_sysfs_value(&statistics_path, "tx_bytes")?,
+        time: Instant::now(),
+    })
+}
+
+/// Calculate transfer rates between two samples
+///
+/// # Arguments
+///
+/// * `iface` - Name of the interface
+/// * `prev_sample` - Previous sample or [`None`] on first call
+/// * `cur_sample` - Current sample
+///
+/// # Returns
+///
+/// A [`NetThroughput`] with the rates
+pub(crate) fn calc_net_throughput(iface: &str, prev_sample: Option<&NetSample>,
+                                  cur_sample: &NetSample) -> NetThroughput
+{
+    let elapsed = prev_sample
+        .map(|prev_sample| cur_sample.time.saturating_duration_since(prev_sample.time).as_secs_f64())
+        .unwrap_or(0.0);
+
+    // Counters might be reset when the interface comes back
+    let (rx_bytes_per_sec, tx_bytes_per_sec) = match prev_sample {
+        Some(prev_sample) if 0.0 < elapsed => (
+            cur_sample.rx_bytes.saturating_sub(prev_sample.rx_bytes) as f64 / elapsed,
+            cur_sample.tx_bytes.saturating_sub(prev_sample.tx_bytes) as f64 / elapsed,
+        ),
+        _ => (0.0, 0.0),
+    };
+
+    NetThroughput {
+        iface: iface.to_string(),
+        present: true,
+        rx_bytes_per_sec,
+        tx_bytes_per_sec,
+        rx_total: cur_sample.rx_bytes,
+        tx_total: cur_sample.tx_bytes,
+        warming_up: prev_sample.is_none(),
+    }
+}
+
+/// Calculate disk usage from filesystem block counts
+///
+/// # Arguments
+///
+/// * `block_size` - Size of a block in bytes
+/// * `blocks` - Total number of blocks
+/// * `free_blocks` - Free blocks including the reserved ones
+/// * `available_blocks` - Blocks available to unprivileged users
+///
+/// # Returns
+///
+/// A [`DiskUsage`] with the values in bytes
+pub(crate) fn calc_disk_usage(block_size: u64, blocks: u64, free_blocks: u64, available_blocks: u64) -> DiskUsage {
+    let used_blocks = blocks.saturating_sub(free_blocks);
+
+    // Leave reserved blocks out and round up like `df`
+    let usable_blocks = used_blocks + available_blocks;
+
+    let percent = if 0 < usable_blocks {
+        (used_blocks * 100).div_ceil(usable_blocks).min(100) as u8
+    } else {
+        0
+    };
+
+    DiskUsage {
+        total_bytes: blocks * block_size,
+        used_bytes: used_blocks * block_size,
+        available_bytes: available_blocks * block_size,
+        percent,
+        error: None,
+    }
+}
+
+/// Run `pactl`, which talks to both PulseAudio and PipeWire
+///
+/// # Arguments
+///
+/// * `args` - Arguments of the command
+///
+/// # Returns
+///
+/// A [`Result`] with either the output on success or otherwise [`anyhow::Error`]
+pub(crate) fn run_pactl(args: &[&str]) -> Result<String> {
+    let output = run_helper("pactl", args, VOLUME_TIMEOUT)?;
+
+    if 0 != output.exit_code {
+        return Err(anyhow!("Sound server not available: {}", output.stderr.trim()));
+    }
+
+    Ok(output.stdout)
+}
+
+/// Run `playerctl`, which talks to all MPRIS players
+///
+/// # Arguments
+///
+/// * `args` - Arguments of the command
+///
+/// # Returns
+///
+/// A [`Result`] with either [`Some`] with the output, [`None`] when it failed like without
+/// players or otherwise [`anyhow::Error`] when it timed out
+pub(crate) fn run_playerctl(args: &[&str]) -> Result<Option<String>> {
+    let output = run_helper("playerctl", args, MEDIA_TIMEOUT)?;
+
+    Ok((0 == output.exit_code).then_some(output.stdout))
+}
+
+/// Read status of the most recently active media player
+///
+/// # Returns
+///
+/// A [`MediaStatus`] which is stopped when no player is running
+pub(crate) fn read_media_status() -> MediaStatus {
+    match run_playerctl(&["--all-players", "metadata", "--format", MEDIA_FORMAT]) {
+        Ok(Some(output)) => parse_media_status(&output),
+        Ok(None) => MediaStatus::default(),
+        Err(err) => MediaStatus {
+            error: Some(err.to_string()),
+            ..MediaStatus::default()
+        },
+    }
+}
+
+/// Parse the status of all players and pick one
+///
+/// # Arguments
+///
+/// * `output` - Output of `playerctl` with one player per line in [`MEDIA_FORMAT`]
+///
+/// # Returns
+///
+/// A [`MediaStatus`] of the first playing or otherwise the first player
+pub(crate) fn parse_media_status(output: &str) -> MediaStatus {
+    // Players are ordered by recent activity
+    let players: Vec<MediaStatus> = output.lines()
+        .filter_map(|line| {
+            let fields: Vec<&str> = line.split('\t').collect();
+
+            if 7 != fields.len() || fields[0].is_empty() {
+                return None;
+            }
+
+            let micros_to_secs = |micros: &str| micros.trim().parse::<u64>().ok()
+                .map(|micros| micros / 1_000_000);
+
+            Some(MediaStatus {
+                player: Some(fields[0].to_string()),
+                status: fields[1].to_string(),
+                artist: fields[2].to_string(),
+                title: fields[3].to_string(),
+                album: fields[4].to_string(),
+                position_secs: micros_to_secs(fields[5]),
+                length_secs: micros_to_secs(fields[6]),
+                error: None,
+                pending: false,
+            })
+        })
+        .collect();
+
+    players.iter()
+        .find(|player| "playing" == player.status)
+        .or_else(|| players.first())
+        .cloned()
+        .unwrap_or_default()
+}
+
+/// Read volume and mute state of the default sink
+///
+/// # Returns
+///
+/// A [`VolumeState`] which is marked as not present when no sound server is running
+pub(crate) fn read_volume() -> VolumeState {
+    // Skip the second query when the sound server doesn't respond
+    let volume = run_pactl(&["get-sink-volume", DEFAULT_SINK])
+        .and_then(|output| parse_volume(&output).context("Cannot parse volume"))
+        .and_then(|percent| run_pactl(&["get-sink-mute", DEFAULT_SINK])
+            .and_then(|output| parse_mute(&output).context("Cannot parse mute state"))
+            .map(|muted| (percent, muted)));
+
+    match volume {
+        Ok((percent, muted)) => VolumeState {
+            present: true,
+            percent,
+            muted,
+            error: None,
+            pending: false,
+        },
+        Err(err) => VolumeState {
+            error: Some(err.to_string()),
+            ..VolumeState::default()
+        },
+    }
+}
+
+/// Parse the volume from the output of `pactl get-sink-volume`
+///
+/// # Arguments
+///
+/// * `output` - Output of the command
+///
+/// # Returns
+///
+/// Either [`Some`] with the average volume of all channels in percent or otherwise [`None`]
+pub(crate) fn parse_volume(output: &str) -> Option<u8> {
+    let volume_line = output.lines().find(|line| line.trim_start().starts_with("Volume:"))?;
+
+    let channels: Vec<u64> = volume_line.split('/')
+        .filter_map(|part| part.trim().strip_suffix('%'))
+        .filter_map(|percent| percent.trim().parse::<u64>().ok())
+        .collect();
+
+    if channels.is_empty() {
+        return None;
+    }
+
+    Some((channels.iter().sum::<u64>() / channels.len() as u64).min(u8::MAX as u64) as u8)
+}
+
+/// Parse the mute state from the output of `pactl get-sink-mute`
+///
+/// # Arguments
+///
+/// * `output` - Output of the command
+///
+/// # Returns
+///
+/// Either [`Some`] with the mute state or otherwise [`None`]
+pub(crate) fn parse_mute(output: &str) -> Option<bool> {
+    match output.trim().strip_prefix("Mute:")?.trim() {
+        "yes" => Some(true),
+        "no" => Some(false),
+        _ => None,
+    }
+}
+
+/// Calculate the new volume of a request
+///
+/// # Arguments
+///
+/// * `percent` - Current volume in percent
+/// * `request` - Requested change
+///
+/// # Returns
+///
+/// Either [`Some`] with the new volume clamped to 0-100 or otherwise [`None`] when unchanged
+pub(crate) fn calc_volume(percent: u8, request: &VolumeRequest) -> Option<u8> {
+    let new_percent = match (request.percent, request.delta) {
+        (Some(percent), _) => percent,
+        (None, Some(delta)) => percent as i32 + delta,
+        (None, None) => return None,
+    };
+
+    Some(new_percent.clamp(0, 100) as u8)
+}
+
+/// Read a single sysfs value and parse it
+///
+/// # Arguments
+///
+/// * `path` - Path to the sysfs entry
+/// * `key` - Name of the value
+///
+/// # Returns
+///
+/// Either [`Some`] with the parsed value or otherwise [`None`]
+fn read_sysfs_value<T: std::str::FromStr>(path: &Path, key: &str) -> Option<T> {
+    std::fs::read_to_string(path.join(key)).ok()
+        .and_then(|value| value.trim().parse::<T>().ok())
+}
+
+/// Collect all temperature sensors of the hwmon class
+///
+/// # Arguments
+///
+/// * `base_path` - Base path of the hwmon class
+///
+/// # Returns
+///
+/// A [`Vec`] of [`Temperature`] which is empty when no sensors can be found
+pub(crate) fn read_temperatures(base_path: &Path) -> Vec<Temperature> {
+    let mut chip_paths: Vec<PathBuf> = std::fs::read_dir(base_path).into_iter()
+        .flatten()
+        .flatten()
+        .map(|entry| entry.path())
+        .collect();
+
+    chip_paths.sort();
+
+    let mut temperatures = Vec::new();
+
+    for chip_path in chip_paths {
+        let chip = read_sysfs_value::<String>(&chip_path, "name").unwrap_or_default();
+
+        // Sensors are numbered from one, but might have gaps
+        let mut sensor_ids: Vec<u32> = std::fs::read_dir(&chip_path).into_iter()
+            .flatten()
+            .flatten()
+            .filter_map(|entry| entry.file_name().to_str()
+                .and_then(|file_name| file_name.strip_prefix("temp"))
+                .and_then(|file_name| file_name.strip_suffix("_input"))
+                .and_then(|sensor_id| sensor_id.parse::<u32>().ok()))
+            .collect();
+
+        sensor_ids.sort_unstable();
+
+        for sensor_id in sensor_ids {
+            let read_celsius = |key: &str| read_sysfs_value::<f64>(&chip_path,
+                &format!("temp{}_{}", sensor_id, key)).map(|millis| millis / 1000.0);
+
+            let Some(celsius) = read_celsius("input") else {
+                continue;
+            };
+
+            let label = read_sysfs_value::<String>(&chip_path, &format!("temp{}_label", sensor_id))
+                .unwrap_or_else(|| format!("{} temp{}", chip, sensor_id));
+
+            temperatures.push(Temperature {
+                label,
+                chip: chip.clone(),
+                celsius,
+                high: read_celsius("max"),
+                critical: read_celsius("crit"),
+            });
+        }
+    }
+
+    temperatures
+}
+
+/// Find backlight device and read its brightness
+///
+/// # Arguments
+///
+/// * `base_path` - Base path of the backlight class
+/// * `device` - Name of the device or empty to use the first one
+///
+/// # Returns
+///
+/// A [`BrightnessStatus`] which is marked as not present when no device was found
+pub(crate) fn read_brightness(base_path: &Path, device: &str) -> BrightnessStatus {
+    let device_path = if device.is_empty() {
+        let mut entries: Vec<_> = std::fs::read_dir(base_path).into_iter()
+            .flatten()
+            .flatten()
+            .map(|entry| entry.path())
+            .filter(|path| path.join("max_brightness").exists())
+            .collect();
+
+        entries.sort();
+        entries.into_iter().next()
+    } else if device.contains('/') || device.starts_with('.') {
+        // Keep names from escaping the class dir
+        None
+    } else {
+        Some(base_path.join(device)).filter(|path| path.is_dir())
+    };
+
+    let Some((device_path, raw, max_raw)) = device_path.and_then(|path| {
+        let raw = read_sysfs_value::<u32>(&path, "brightness")?;
+        let max_raw = read_sysfs_value::<u32>(&path, "max_brightness").filter(|max_raw| 0 < *max_raw)?;
+
+        Some((path, raw, max_raw))
+    }) else {
+        return BrightnessStatus::default();
+    };
+
+    BrightnessStatus {
+        present: true,
+        name: device_path.file_name().map(|name| name.to_string_lossy().into_owned()),
+        percent: (raw.min(max_raw) as f64 / max_raw as f64 * 100.0).round() as u8,
+        raw,
+        max_raw,
+        error: None,
+    }
+}
+
+/// Calculate the new raw brightness of a request
+///
+/// # Arguments
+///
+/// * `status` - Current brightness
+/// * `request` - Requested change
+///
+/// # Returns
+///
+/// Either [`Some`] with the new raw value clamped to the maximum or otherwise [`None`] when unchanged
+pub(crate) fn calc_brightness(status: &BrightnessStatus, request: &BrightnessRequest) -> Option<u32> {
+    let new_percent = match (request.percent, request.delta) {
+        (Some(percent), _) => percent,
+        (None, Some(delta)) => status.percent as i32 + delta,
+        (None, None) => return None,
+    };
+
+    let raw = (new_percent.clamp(0, 100) as f64 / 100.0 * status.max_raw as f64).round() as u32;
+
+    // Devices with few steps need at least one step per change
+    Some(match request.delta {
+        Some(delta) if request.percent.is_none() && raw == status.raw && 0 < delta =>
+            (status.raw + 1).min(status.max_raw),
+        Some(delta) if request.percent.is_none() && raw == status.raw && 0 > delta =>
+            status.raw.saturating_sub(1),
+        _ => raw,
+    })
+}
+
+/// Set the brightness of a backlight device
+///
+/// # Arguments
+///
+/// * `base_path` - Base path of the backlight class
+/// * `request` - Requested change
+///
+/// # Returns
+///
+/// A [`BrightnessStatus`] with the resulting brightness and an error when it cannot be set
+pub(crate) fn write_brightness(base_path: &Path, request: &BrightnessRequest) -> BrightnessStatus {
+    let status = read_brightness(base_path, request.device.trim());
+
+    let Some(name) = status.name.clone() else {
+        return BrightnessStatus {
+            error: Some("No backlight device found".into()),
+            ..status
+        };
+    };
+
+    let Some(raw) = calc_brightness(&status, request) else {
+        return status;
+    };
+
+    let brightness_path = base_path.join(name).join("brightness");
+
+    // Writing usually requires an udev rule or a helper
+    match std::fs::write(&brightness_path, raw.to_string()) {
+        Ok(_) => read_brightness(base_path, &name),
+        Err(err) if std::io::ErrorKind::PermissionDenied == err.kind() => BrightnessStatus {
+            error: Some(format!("Permission denied to write `{}`, udev rule or group membership required",
+                brightness_path.display())),
+            ..status
+        },
+        Err(err) => BrightnessStatus {
+            error: Some(format!("Cannot write `{}`: {}", brightness_path.display(), err)),
+            ..status
+        },
+    }
+}
+
+/// Parse either a single path or a JSON list of paths
+///
+/// # Arguments
+///
+/// * `paths` - Path or JSON list of paths
+///
+/// # Returns
+///
+/// A [`Vec`] of paths
+pub(crate) fn parse_mail_paths(paths: &str) -> Vec<String> {
+    let paths = paths.trim();
+
+    if paths.starts_with('[') {
+        serde_json::from_str::<Vec<String>>(paths).unwrap_or_else(|err| {
+            warn!("Invalid list of Maildir paths `{}`: {}", paths, err);
+
+            Vec::new()
+        })
+    } else if paths.is_empty() {
+        Vec::new()
+    } else {
+        vec![paths.to_string()]
+    }
+}
+
+/// Count the messages of a Maildir
+///
+/// # Arguments
+///
+/// * `path` - Path of the Maildir; a leading `~` is replaced with the home dir
+///
+/// # Returns
+///
+/// A [`MailboxCount`] which is marked as not present when the path is no Maildir
+pub(crate) fn read_mailbox(path: &str) -> MailboxCount {
+    let maildir_path = match (path.strip_prefix("~/"), std::env::var_os("HOME")) {
+        (Some(rest), Some(home)) => PathBuf::from(home).join(rest),
+        _ => PathBuf::from(path),
+    };
+
+    let mut count = MailboxCount {
+        path: path.to_string(),
+        ..MailboxCount::default()
+    };
+
+    if !maildir_path.join("new").is_dir() || !maildir_path.join("cur").is_dir() {
+        return count;
+    }
+
+    // Skip hidden files and messages marked as trashed
+    let list_messages = |dir_name: &str| std::fs::read_dir(maildir_path.join(dir_name)).into_iter()
+        .flatten()
+        .flatten()
+        .map(|entry| entry.file_name().to_string_lossy().into_owned())
+        .filter(|file_name| !file_name.starts_with('.'))
+        .map(|file_name| file_name.split_once(":2,")
+            .map(|(_, flags)| flags.to_string())
+            .unwrap_or_default())
+        .filter(|flags| !flags.contains('T'))
+        .collect::<Vec<String>>();
+
+    let new_messages = list_messages("new");
+    let cur_messages = list_messages("cur");
+
+    count.present = true;
+    count.new = new_messages.len() as u32;
+    count.unread = count.new + cur_messages.iter().filter(|flags| !flags.contains('S')).count() as u32;
+    count.total = count.new + cur_messages.len() as u32;
+
+    count
+}
+
+/// Count the messages of several Maildirs
+///
+/// # Arguments
+///
+/// * `paths` - Paths of the Maildirs
+///
+/// # Returns
+///
+/// A [`MailCount`] with the sum and the counts per account; missing Maildirs count as zero
+pub(crate) fn count_mail(paths: &[String]) -> MailCount {
+    let accounts: Vec<MailboxCount> = paths.iter()
+        .map(|path| read_mailbox(path))
+        .collect();
+
+    MailCount {
+        new: accounts.iter().map(|account| account.new).sum(),
+        unread: accounts.iter().map(|account| account.unread).sum(),
+        total: accounts.iter().map(|account| account.total).sum(),
+        accounts,
+    }
+}
+
+/// Find battery and collect its status
+///
+/// # Arguments
+///
+/// * `base_path` - Base path of the power supply class
+/// * `battery_name` - Name of the battery or empty to use the first one
+///
+/// # Returns
+///
+/// A [`BatteryStatus`] which is marked as not present when no battery was found
+pub(crate) fn read_battery_status(base_path: &Path, battery_name: &str) -> BatteryStatus {
+    let battery_path = if battery_name.is_empty() {
+        let mut entries: Vec<_> = std::fs::read_dir(base_path).into_iter()
+            .flatten()
+            .flatten()
+            .map(|entry| entry.path())
+            .filter(|path| read_sysfs_value::<String>(path, "type")
+                .is_some_and(|kind| "Battery" == kind))
+            .collect();
+
+        entries.sort();
+        entries.into_iter().next()
+    } else if battery_name.contains('/') || battery_name.starts_with('.') {
+        // Keep names from escaping the class dir
+        None
+    } else {
+        Some(base_path.join(battery_name)).filter(|path| path.is_dir())
+    };
+
+    let Some(battery_path) = battery_path else {
+        return BatteryStatus::default();
+    };
+
+    let status = read_sysfs_value::<String>(&battery_path, "status").unwrap_or_default();
+
+    // Batteries either report energy (µWh, µW) or charge (µAh, µA)
+    let is_energy = battery_path.join("energy_now").exists();
+
+    let (now, full, rate) = if is_energy {
+        (read_sysfs_value::<f64>(&battery_path, "energy_now"),
+         read_sysfs_value::<f64>(&battery_path, "energy_full"),
+         read_sysfs_value::<f64>(&battery_path, "power_now"))
+    } else {
+        (read_sysfs_value::<f64>(&battery_path, "charge_now"),
+         read_sysfs_value::<f64>(&battery_path, "charge_full"),
+         read_sysfs_value::<f64>(&battery_path, "current_now"))
+    };
+
+    let percent = read_sysfs_value::<u8>(&battery_path, "capacity")
+        .or_else(|| now.zip(full)
+            .filter(|(_, full)| 0.0 < *full)
+            .map(|(now, full)| (now / full * 100.0).round().min(100.0) as u8));
+
+    let charging = "Charging" == status;
+
+    let time_remaining_secs = match (now, full, rate) {
+        (Some(now), Some(full), Some(rate)) if 0.0 < rate => {
+            let remaining = if charging { (full - now).max(0.0) } else { now };
+
+            Some((remaining / rate * 3600.0) as u64)
+        },
+        _ => None,
+    };
+
+    // Charge based batteries need the voltage (µV) to calculate the power
+    let power_draw_watts = rate.map(|rate| {
+        if is_energy {
+            rate / 1_000_000.0
+        } else {
+            let voltage = read_sysfs_value::<f64>(&battery_path, "voltage_now").unwrap_or(0.0);
+
+            rate * voltage / 1_000_000_000_000.0
+        }
+    });
+
+    debug!("{}: battery={:?}, status={}", function_name!(), battery_path, status);
+
+    BatteryStatus {
+        present: true,
+        name: battery_path.file_name().map(|name| name.to_string_lossy().into_owned()),
+        percent,
+        charging,
+        time_remaining_secs,
+        power_draw_watts,
+    }
+}
@@ -0,0 +1,201 @@
+//!
+//! @package subtle-rs
+//!
+//! @file Plugin time functions
+//! @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+//! @version $Id$
+//!
+//! This program can be distributed under the terms of the GNU GPLv3.
+//! See the file LICENSE for details.
+//!
+
+use std::collections::HashMap;
+use anyhow::{anyhow, Context, Result};
+use chrono::{DateTime, Local, Utc};
+use chrono::format::{Item, StrftimeItems};
+use chrono_tz::Tz;
+use log::warn;
+use itertools::Itertools;
+
+/// Marker appended to the time when the given timezone is unknown
+const UNKNOWN_TZ_MARKER: &str = " (TZ?)";
+
+/// Translate a single time component token like `[hour repr:12]` to strftime
+///
+/// # Arguments
+///
+/// * `token` - Content of the token without brackets
+///
+/// # Returns
+///
+/// Either [`Some`] with the strftime specifier or otherwise [`None`]
+fn translate_time_token(token: &str) -> Option<&'static str> {
+    let mut parts = token.split_whitespace();
+    let component = parts.next()?;
+    let modifiers: HashMap<&str, &str> = parts
+        .filter_map(|modifier| modifier.split_once(':'))
+        .collect();
+
+    let repr = modifiers.get("repr").copied();
+
+    Some(match component {
+        "year" => if Some("last_two") == repr { "%y" } else { "%Y" },
+        "month" => match repr {
+            Some("short") => "%b",
+            Some("long") => "%B",
+            _ => "%m",
+        },
+        "day" => "%d",
+        "ordinal" => "%j",
+        "weekday" => match repr {
+            Some("short") => "%a",
+            Some("sunday") => "%w",
+            Some("monday") => "%u",
+            _ => "%A",
+        },
+        "week_number" => "%V",
+        "hour" => if Some("12") == repr { "%I" } else { "%H" },
+        "minute" => "%M",
+        "second" => "%S",
+        "subsecond" => "%3f",
+        "period" => if Some(&"lower") == modifiers.get("case") { "%P" } else { "%p" },
+        "offset_hour" | "offset" => "%z",
+        "unix_timestamp" => "%s",
+        _ => return None,
+    })
+}
+
+/// Translate time component tokens to strftime and keep everything else as is
+///
+/// # Arguments
+///
+/// * `format` - Format string with strftime specifiers and/or tokens
+///
+/// # Returns
+///
+/// A [`Result`] with either [`String`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn translate_time_format(format: &str) -> Result<String> {
+    let mut translated = String::with_capacity(format.len());
+    let mut rest = format;
+
+    while let Some(start) = rest.find('[') {
+        translated.push_str(&rest[..start]);
+
+        // Handle escaped brackets
+        if rest[start..].starts_with("[[") {
+            translated.push('[');
+            rest = &rest[start + 2..];
+
+            continue;
+        }
+
+        let end = rest[start..].find(']')
+            .ok_or_else(|| anyhow!("Unclosed time token in `{}`", format))?;
+        let token = &rest[start + 1..start + end];
+
+        translated.push_str(translate_time_token(token)
+            .ok_or_else(|| anyhow!("Unknown time token `{}`", token))?);
+
+        rest = &rest[start + end + 1..];
+    }
+
+    translated.push_str(rest);
+
+    // Reject invalid specifiers, chrono panics on them while formatting
+    if StrftimeItems::new(&translated).any(|item| matches!(item, Item::Error)) {
+        return Err(anyhow!("Invalid time format `{}`", format));
+    }
+
+    Ok(translated)
+}
+
+/// Format the current time with optional timezone prefix like `TZ=Europe/Berlin;`
+///
+/// # Arguments
+///
+/// * `format` - Format string with strftime specifiers and/or tokens
+///
+/// # Returns
+///
+/// A [`Result`] with either [`String`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn format_time(format: &str) -> Result<String> {
+    let (tz_name, format) = match format.strip_prefix("TZ=").and_then(|rest| rest.split_once(';')) {
+        Some((tz_name, format)) => (Some(tz_name.trim()), format),
+        None => (None, format),
+    };
+
+    let format = translate_time_format(format)?;
+
+    Ok(match tz_name.map(|tz_name| tz_name.parse::<Tz>()) {
+        Some(Ok(tz)) => Utc::now().with_timezone(&tz).format(&format).to_string(),
+        Some(Err(_)) => {
+            warn!("Unknown timezone `{}`, using local time", tz_name.unwrap_or_default());
+
+            let current_local: DateTime<Local> = Local::now();
+
+            format!("{}{}", current_local.format(&format), UNKNOWN_TZ_MARKER)
+        },
+        None => {
+            let current_local: DateTime<Local> = Local::now();
+
+            current_local.format(&format).to_string()
+        },
+    })
+}
+
+/// Format a duration in one of the styles `compact` (2h3m), `long` (2 hours 3 minutes) or `clock` (02:03:00)
+///
+/// # Arguments
+///
+/// * `seconds` - Duration in seconds; negative durations are prefixed with a minus
+/// * `style` - Name of the style
+///
+/// # Returns
+///
+/// A [`Result`] with either [`String`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn format_duration(seconds: i64, style: &str) -> Result<String> {
+    let sign = if 0 > seconds { "-" } else { "" };
+    let total = seconds.unsigned_abs();
+
+    let units = [
+        (total / 86400, "d", "day"),
+        (total / 3600 % 24, "h", "hour"),
+        (total / 60 % 60, "m", "minute"),
+        (total % 60, "s", "second"),
+    ];
+
+    // Skip empty units, but always show at least the seconds
+    let mut used_units: Vec<&(u64, &str, &str)> = units.iter()
+        .filter(|(value, _, _)| 0 < *value)
+        .collect();
+
+    if used_units.is_empty() {
+        used_units.push(&units[3]);
+    }
+
+    Ok(match style.trim() {
+        "compact" => format!("{}{}", sign, used_units.iter()
+            .map(|(value, short_name, _)| format!("{}{}", value, short_name))
+            .join("")),
+        "long" => format!("{}{}", sign, used_units.iter()
+            .map(|(value, _, long_name)| format!("{} {}{}", value, long_name, if 1 == *value { "" } else { "s" }))
+            .join(" ")),
+        "clock" => format!("{}{:02}:{:02}:{:02}", sign, total / 3600, total / 60 % 60, total % 60),
+        _ => return Err(anyhow!("Unknown duration style `{}`", style)),
+    })
+}
+
+/// Parse a RFC 3339 time like `2025-01-01T12:00:00+01:00`
+///
+/// # Arguments
+///
+/// * `time` - Time to parse
+///
+/// # Returns
+///
+/// A [`Result`] with either the unix timestamp on success or otherwise [`anyhow::Error`]
+pub(crate) fn parse_time(time: &str) -> Result<i64> {
+    DateTime::parse_from_rfc3339(time.trim())
+        .map(|time| time.timestamp())
+        .with_context(|| format!("Invalid time `{}`", time))
+}
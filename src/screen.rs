@@ -28,7 +28,7 @@ use crate::client::ClientFlags;
 use crate::ewmh::WMState;
 use crate::panel;
 use crate::panel::{Panel, PanelAction, PanelFlags};
-use crate::plugin::Plugin;
+use crate::plugin::{Plugin, PluginFlags};
 use crate::tagging::Tagging;
 
 bitflags! {
@@ -97,6 +97,7 @@ impl Screen {
 
         let aux = CreateWindowAux::default()
             .event_mask(EventMask::BUTTON_PRESS
+                | EventMask::POINTER_MOTION
                 | EventMask::ENTER_WINDOW
                 | EventMask::LEAVE_WINDOW
                 | EventMask::EXPOSURE)
@@ -121,8 +122,17 @@ impl Screen {
     }
 
     pub(crate) fn handle_action(&self, subtle: &Subtle, action: &PanelAction, is_bottom: bool) -> Result<()> {
+        let mut is_bottom_panel = false;
+
+        // Only handle panels of the selected bar
         for panel in self.panels.iter() {
-            panel.handle_action(subtle, action, is_bottom)?;
+            if panel.flags.intersects(PanelFlags::BOTTOM_START_MARKER) {
+                is_bottom_panel = true;
+            }
+
+            if is_bottom == is_bottom_panel {
+                panel.handle_action(subtle, action, is_bottom)?;
+            }
         }
 
         debug!("{}: screen={}", function_name!(), self);
@@ -170,6 +180,7 @@ impl fmt::Display for Screen {
 /// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
 fn parse_panels(screen: &mut Screen, panel_list: &Vec<String>, plugin_list: &Vec<Plugin>, screen_idx: usize,  is_bottom: bool) {
     let mut flags = PanelFlags::empty();
+    let mut panels: Vec<Panel> = Vec::with_capacity(panel_list.len());
 
     // Add bottom marker to first panel on bottom panel in linear vec
     if is_bottom {
@@ -180,21 +191,110 @@ fn parse_panels(screen: &mut Screen, panel_list: &Vec<String>, plugin_list: &Vec
 
         // Create panel
         if let Ok(mut panel) = Panel::new(panel_name) {
-            panel.flags |= flags;
             panel.screen_idx = screen_idx;
+            panel.is_bottom = is_bottom;
 
             if panel.flags.intersects(PanelFlags::PLUGIN) {
                 if let Some(idx) = plugin_list.iter()
                     .position(|p| panel_name.ends_with(&format!("${}", p.name)))
                 {
                     panel.plugin_idx = idx;
+
+                    apply_plugin(&mut panel, &plugin_list[idx]);
+
+                    // Tooltips can be set anytime
+                    panel.flags.insert(PanelFlags::MOUSE_OVER | PanelFlags::MOUSE_OUT);
                 }
             }
 
-            screen.panels.push(panel);
-            flags.remove(PanelFlags::BOTTOM_START_MARKER);
+            panels.push(panel);
         }
     }
+
+    // Sort by position and keep the order of the list for ties
+    panels.sort_by_key(|panel| panel.position);
+
+    for mut panel in panels {
+        panel.flags |= flags;
+
+        screen.panels.push(panel);
+        flags.remove(PanelFlags::BOTTOM_START_MARKER);
+    }
+}
+
+/// Apply placement and exports of a plugin to its panel item
+///
+/// # Arguments
+///
+/// * `panel` - Panel item of the plugin
+/// * `plugin` - Plugin of the panel item
+fn apply_plugin(panel: &mut Panel, plugin: &Plugin) {
+    let placement = plugin.placement.get();
+
+    // Placement of the plugin wins over the prefix
+    panel.flags.remove(PanelFlags::LEFT_POS | PanelFlags::CENTER_POS | PanelFlags::RIGHT_POS);
+    panel.flags.insert(placement.side.map_or(panel.prefix_flags, |side| side.to_panel_flags()));
+
+    panel.position = placement.position.unwrap_or(0);
+
+    // Enable clicks only when handled by the plugin
+    panel.flags.set(PanelFlags::MOUSE_DOWN, plugin.flags.get().intersects(PluginFlags::ON_CLICK
+        | PluginFlags::ON_SCROLL | PluginFlags::CLICK_ACTIONS));
+}
+
+/// Apply placement and exports of a plugin to its panel items again and restore the order
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+/// * `plugin_idx` - Index of the plugin
+pub(crate) fn place_plugin_panels(subtle: &Subtle, plugin_idx: usize) {
+    let Some(plugin) = subtle.plugins.get(plugin_idx) else {
+        return;
+    };
+
+    for screen in subtle.screens.iter() {
+        for panel_idx in 0..screen.panels.len() {
+            if let Some(mut panel) = screen.panels.borrow_mut(panel_idx)
+                && panel.flags.intersects(PanelFlags::PLUGIN) && plugin_idx == panel.plugin_idx
+            {
+                apply_plugin(&mut panel, plugin);
+            }
+        }
+
+        // Sort by bar and position and keep the order for ties like parse_panels
+        for panel_idx in 1..screen.panels.len() {
+            for swap_idx in (1..=panel_idx).rev() {
+                let (Some(mut prev), Some(mut cur)) = (screen.panels.borrow_mut(swap_idx - 1),
+                                                       screen.panels.borrow_mut(swap_idx)) else {
+                    break;
+                };
+
+                if (prev.is_bottom, prev.position) <= (cur.is_bottom, cur.position) {
+                    break;
+                }
+
+                std::mem::swap(&mut *prev, &mut *cur);
+            }
+        }
+
+        // Move the marker to the first panel of the bottom bar again
+        let mut has_marker = false;
+
+        for panel_idx in 0..screen.panels.len() {
+            if let Some(mut panel) = screen.panels.borrow_mut(panel_idx) {
+                panel.flags.remove(PanelFlags::BOTTOM_START_MARKER);
+
+                if panel.is_bottom && !has_marker {
+                    panel.flags.insert(PanelFlags::BOTTOM_START_MARKER);
+
+                    has_marker = true;
+                }
+            }
+        }
+    }
+
+    debug!("{}: plugin={}, placement={:?}", function_name!(), plugin.name, plugin.placement.get());
 }
 
 /// Check config and init all screen related options
@@ -211,7 +311,7 @@ pub(crate) fn init(config: &Config, subtle: &mut Subtle) -> Result<()> {
     let conn = subtle.conn.get().context("Failed to get connection")?;
 
     // Check xrandr support
-    if subtle.flags.intersects(SubtleFlags::XRANDR) {
+    if subtle.flags.get().intersects(SubtleFlags::XRANDR) {
         let default_screen = &conn.setup().roots[subtle.screen_num];
         let crtcs= conn.randr_get_screen_resources_current(default_screen.root)?.reply()?.crtcs;
 
@@ -227,7 +327,7 @@ pub(crate) fn init(config: &Config, subtle: &mut Subtle) -> Result<()> {
     }
 
     // Check xinerama support, but prefer xrandr
-    if subtle.flags.intersects(SubtleFlags::XINERAMA) && subtle.screens.is_empty() {
+    if subtle.flags.get().intersects(SubtleFlags::XINERAMA) && subtle.screens.is_empty() {
         if 0 != conn.xinerama_is_active()?.reply()?.state {
             let screens = conn.xinerama_query_screens()?.reply()?.screen_info;
 
@@ -381,8 +481,8 @@ pub(crate) fn configure(subtle: &Subtle) -> Result<()> {
 
                 // Warp after gravity and screen have been set if not disabled
                 if client.flags.intersects(ClientFlags::MODE_URGENT)
-                    && !subtle.flags.intersects(SubtleFlags::SKIP_URGENT_WARP)
-                    && !subtle.flags.intersects(SubtleFlags::SKIP_POINTER_WARP)
+                    && !subtle.flags.get().intersects(SubtleFlags::SKIP_URGENT_WARP)
+                    && !subtle.flags.get().intersects(SubtleFlags::SKIP_POINTER_WARP)
                 {
                     client.warp_pointer(subtle)?;
                 }
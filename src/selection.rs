@@ -0,0 +1,425 @@
+//!
+//! @package subtle-rs
+//!
+//! @file Selection functions
+//! @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+//! @version $Id$
+//!
+//! This program can be distributed under the terms of the GNU GPLv3.
+//! See the file LICENSE for details.
+//!
+
+use std::collections::HashMap;
+use std::fmt;
+use anyhow::{anyhow, Context, Result};
+use log::{debug, warn};
+use serde::Serialize;
+use stdext::function_name;
+use x11rb::{COPY_DEPTH_FROM_PARENT, CURRENT_TIME, NONE};
+use x11rb::connection::Connection;
+use x11rb::protocol::xproto::{Atom, AtomEnum, ConnectionExt, CreateWindowAux, EventMask, PropMode, SelectionClearEvent, SelectionNotifyEvent, SelectionRequestEvent, Window, WindowClass, SELECTION_NOTIFY_EVENT};
+use x11rb::wrapper::ConnectionExt as ConnectionExtWrapper;
+use crate::config::Config;
+use crate::subtle::Subtle;
+
+/// Maximum size of selection contents read from other clients in bytes
+const MAX_SELECTION_SIZE: u32 = 256 * 1024;
+
+/// Size of the header of ChangeProperty requests in bytes
+const CHANGE_PROPERTY_HEADER_SIZE: usize = 24;
+
+/// Targets that don't describe the type of the content
+const META_TARGETS: [&str; 5] = ["TARGETS", "TIMESTAMP", "MULTIPLE", "SAVE_TARGETS", "DELETE"];
+
+#[derive(Debug, Copy, Clone, PartialEq, Eq, Hash)]
+pub(crate) enum SelectionKind {
+    /// Selection of explicit copy actions
+    Clipboard,
+    /// Selection of the last selected text
+    Primary,
+}
+
+impl SelectionKind {
+    /// Parse name of the selection
+    ///
+    /// # Arguments
+    ///
+    /// * `name` - Either `clipboard`, `primary` or empty for the clipboard
+    ///
+    /// # Returns
+    ///
+    /// Either [`Some`] with the [`SelectionKind`] or otherwise [`None`] when unknown
+    pub(crate) fn parse(name: &str) -> Option<Self> {
+        match name.trim() {
+            "" | "clipboard" => Some(SelectionKind::Clipboard),
+            "primary" => Some(SelectionKind::Primary),
+            _ => None,
+        }
+    }
+
+    /// Get atom of the selection
+    ///
+    /// # Arguments
+    ///
+    /// * `subtle` - Global state object
+    ///
+    /// # Returns
+    ///
+    /// A [`Result`] with either [`Atom`] on success or otherwise [`anyhow::Error`]
+    fn atom(&self, subtle: &Subtle) -> Result<Atom> {
+        let atoms = subtle.atoms.get().context("Failed to get atoms")?;
+
+        Ok(match self {
+            SelectionKind::Clipboard => atoms.CLIPBOARD,
+            SelectionKind::Primary => AtomEnum::PRIMARY.into(),
+        })
+    }
+
+    /// Find selection of the given atom
+    ///
+    /// # Arguments
+    ///
+    /// * `subtle` - Global state object
+    /// * `atom` - Atom of the selection
+    ///
+    /// # Returns
+    ///
+    /// Either [`Some`] with the [`SelectionKind`] or otherwise [`None`] when not handled
+    fn from_atom(subtle: &Subtle, atom: Atom) -> Option<Self> {
+        [SelectionKind::Clipboard, SelectionKind::Primary].into_iter()
+            .find(|kind| kind.atom(subtle).is_ok_and(|kind_atom| kind_atom == atom))
+    }
+}
+
+#[derive(Default, Debug, Clone, PartialEq, Serialize)]
+pub(crate) struct SelectionContent {
+    /// Text of the selection; empty for other types
+    pub(crate) text: String,
+    /// Type of the content like `text` or `image/png`; empty without owner
+    #[serde(rename = "type")]
+    pub(crate) content_type: String,
+}
+
+#[derive(Default, Debug)]
+pub(crate) struct Selection {
+    /// Window to request and own selections
+    pub(crate) win: Window,
+    /// Last known contents per selection
+    pub(crate) contents: HashMap<SelectionKind, SelectionContent>,
+    /// Text of the selections owned by us
+    pub(crate) owned: HashMap<SelectionKind, String>,
+}
+
+impl fmt::Display for Selection {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "(win={}, contents={}, owned={})", self.win, self.contents.len(), self.owned.len())
+    }
+}
+
+/// Find the type of the content from the list of targets
+///
+/// # Arguments
+///
+/// * `targets` - Names of the targets the owner offers
+///
+/// # Returns
+///
+/// A [`String`] with `text` for text or otherwise the first target that describes the content
+pub(crate) fn find_content_type(targets: &[String]) -> String {
+    let is_text = targets.iter().any(|target| ["UTF8_STRING", "STRING", "TEXT"].contains(&target.as_str())
+        || target.starts_with("text/plain"));
+
+    if is_text {
+        return String::from("text");
+    }
+
+    targets.iter()
+        .find(|target| !META_TARGETS.contains(&target.as_str()))
+        .cloned()
+        .unwrap_or_default()
+}
+
+/// Check config and init all selection related options
+///
+/// # Arguments
+///
+/// * `config` - Config values read either from args or config file
+/// * `subtle` - Global state object
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn init(_config: &Config, subtle: &mut Subtle) -> Result<()> {
+    let conn = subtle.conn.get().context("Failed to get connection")?;
+
+    let default_screen = &conn.setup().roots[subtle.screen_num];
+
+    // Selection events are always sent, so no event mask is required
+    let win = conn.generate_id()?;
+
+    let aux = CreateWindowAux::default()
+        .override_redirect(1);
+
+    conn.create_window(COPY_DEPTH_FROM_PARENT, win, default_screen.root,
+                       -100, -100, 1, 1, 0,
+                       WindowClass::INPUT_OUTPUT, default_screen.root_visual, &aux)?.check()?;
+
+    subtle.selection.get_mut().win = win;
+
+    debug!("{}", function_name!());
+
+    Ok(())
+}
+
+/// Request the current contents of all selections
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn request(subtle: &Subtle) -> Result<()> {
+    let conn = subtle.conn.get().context("Failed to get connection")?;
+    let atoms = subtle.atoms.get().context("Failed to get atoms")?;
+
+    let mut selection = subtle.selection.borrow_mut();
+
+    for kind in [SelectionKind::Clipboard, SelectionKind::Primary] {
+        if let Some(text) = selection.owned.get(&kind).cloned() {
+            selection.contents.insert(kind, SelectionContent {
+                text,
+                content_type: String::from("text"),
+            });
+        } else {
+            // Store the answer in the property of the same name
+            let atom = kind.atom(subtle)?;
+
+            conn.convert_selection(selection.win, atom, atoms.UTF8_STRING, atom, CURRENT_TIME)?;
+        }
+    }
+
+    debug!("{}: selection={}", function_name!(), selection);
+
+    Ok(())
+}
+
+/// Handle answers to requests of the selection contents
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+/// * `event` - Event to handle
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn handle_notify(subtle: &Subtle, event: &SelectionNotifyEvent) -> Result<()> {
+    let conn = subtle.conn.get().context("Failed to get connection")?;
+    let atoms = subtle.atoms.get().context("Failed to get atoms")?;
+
+    let Some(kind) = SelectionKind::from_atom(subtle, event.selection) else {
+        return Ok(());
+    };
+
+    let mut selection = subtle.selection.borrow_mut();
+
+    if event.requestor != selection.win {
+        return Ok(());
+    }
+
+    let content = if NONE == event.property {
+        // Ask for the available types when the content isn't text
+        if event.target != atoms.TARGETS {
+            conn.convert_selection(selection.win, event.selection, atoms.TARGETS,
+                                   event.selection, CURRENT_TIME)?;
+
+            return Ok(());
+        }
+
+        SelectionContent::default()
+    } else {
+        let reply = conn.get_property(true, selection.win, event.property,
+                                      AtomEnum::ANY, 0, MAX_SELECTION_SIZE / 4)?.reply()?;
+
+        if event.target == atoms.TARGETS {
+            let mut targets = Vec::new();
+
+            for target in reply.value32().into_iter().flatten() {
+                if let Ok(reply) = conn.get_atom_name(target)?.reply() {
+                    targets.push(String::from_utf8_lossy(&reply.name).into_owned());
+                }
+            }
+
+            SelectionContent {
+                text: String::new(),
+                content_type: find_content_type(&targets),
+            }
+        } else if reply.type_ == atoms.INCR {
+            // Incremental transfers of large contents aren't supported
+            SelectionContent {
+                text: String::new(),
+                content_type: String::from("incr"),
+            }
+        } else {
+            SelectionContent {
+                text: String::from_utf8_lossy(&reply.value).into_owned(),
+                content_type: String::from("text"),
+            }
+        }
+    };
+
+    debug!("{}: kind={:?}, type={}, len={}", function_name!(), kind, content.content_type, content.text.len());
+
+    selection.contents.insert(kind, content);
+
+    Ok(())
+}
+
+/// Handle requests of other clients for selections owned by us
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+/// * `event` - Event to handle
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn handle_request(subtle: &Subtle, event: &SelectionRequestEvent) -> Result<()> {
+    let conn = subtle.conn.get().context("Failed to get connection")?;
+    let atoms = subtle.atoms.get().context("Failed to get atoms")?;
+
+    let selection = subtle.selection.borrow();
+
+    // Obsolete clients don't set a property
+    let property = if NONE == event.property { event.target } else { event.property };
+
+    let text = SelectionKind::from_atom(subtle, event.selection)
+        .and_then(|kind| selection.owned.get(&kind));
+
+    let property = match text {
+        Some(_) if event.target == atoms.TARGETS => {
+            conn.change_property32(PropMode::REPLACE, event.requestor, property, AtomEnum::ATOM,
+                                   &[atoms.TARGETS, atoms.UTF8_STRING, AtomEnum::STRING.into()])?;
+
+            property
+        },
+        Some(text) if event.target == atoms.UTF8_STRING || event.target == u32::from(AtomEnum::STRING) => {
+            let max_size = conn.maximum_request_bytes().saturating_sub(CHANGE_PROPERTY_HEADER_SIZE);
+
+            // Incremental transfers aren't supported, so refuse texts exceeding a single request
+            if max_size < text.len() {
+                warn!("Refusing selection request, text exceeds the maximum request size ({} > {})",
+                    text.len(), max_size);
+
+                NONE
+            } else if let Err(err) = conn.change_property8(PropMode::REPLACE, event.requestor, property,
+                                                           event.target, text.as_bytes())
+            {
+                warn!("Cannot set selection property of requestor ({}): {}", event.requestor, err);
+
+                NONE
+            } else {
+                property
+            }
+        },
+        _ => NONE,
+    };
+
+    conn.send_event(false, event.requestor, EventMask::NO_EVENT, SelectionNotifyEvent {
+        response_type: SELECTION_NOTIFY_EVENT,
+        sequence: 0,
+        time: event.time,
+        requestor: event.requestor,
+        selection: event.selection,
+        target: event.target,
+        property,
+    })?;
+
+    conn.flush()?;
+
+    debug!("{}: requestor={}, target={}, property={}", function_name!(), event.requestor, event.target, property);
+
+    Ok(())
+}
+
+/// Handle loss of selections owned by us
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+/// * `event` - Event to handle
+pub(crate) fn handle_clear(subtle: &Subtle, event: &SelectionClearEvent) {
+    if let Some(kind) = SelectionKind::from_atom(subtle, event.selection) {
+        subtle.selection.borrow_mut().owned.remove(&kind);
+    }
+
+    debug!("{}: selection={}", function_name!(), event.selection);
+}
+
+/// Take ownership of a selection and set its text
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+/// * `kind` - Selection to set
+/// * `text` - New text; an empty text clears the selection
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn set(subtle: &Subtle, kind: SelectionKind, text: &str) -> Result<()> {
+    let conn = subtle.conn.get().context("Failed to get connection")?;
+
+    let mut selection = subtle.selection.borrow_mut();
+
+    let atom = kind.atom(subtle)?;
+
+    if text.is_empty() {
+        conn.set_selection_owner(NONE, atom, CURRENT_TIME)?.check()?;
+
+        selection.owned.remove(&kind);
+        selection.contents.insert(kind, SelectionContent::default());
+    } else {
+        conn.set_selection_owner(selection.win, atom, CURRENT_TIME)?.check()?;
+
+        if conn.get_selection_owner(atom)?.reply()?.owner != selection.win {
+            return Err(anyhow!("Failed to own selection {:?}", kind));
+        }
+
+        selection.owned.insert(kind, text.to_string());
+        selection.contents.insert(kind, SelectionContent {
+            text: text.to_string(),
+            content_type: String::from("text"),
+        });
+    }
+
+    debug!("{}: kind={:?}, len={}", function_name!(), kind, text.len());
+
+    Ok(())
+}
+
+/// Tidy up afterwards
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn finish(subtle: &Subtle) -> Result<()> {
+    if let Some(conn) = subtle.conn.get() {
+        let selection = subtle.selection.borrow();
+
+        if 0 != selection.win {
+            conn.destroy_window(selection.win)?;
+        }
+    }
+
+    debug!("{}", function_name!());
+
+    Ok(())
+}
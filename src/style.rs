@@ -273,7 +273,7 @@ macro_rules! scale_value {
 /// # Returns
 ///
 /// A [`Result`] with either [`i32`] on success or otherwise [`anyhow::Error`]
-fn alloc_color(conn: &RustConnection, color_str: &str, cmap: Colormap) -> Result<i32> {
+pub(crate) fn alloc_color(conn: &RustConnection, color_str: &str, cmap: Colormap) -> Result<i32> {
     let hex_color = HexColor::parse(color_str)?;
 
     Ok(conn.alloc_color(cmap,
@@ -282,6 +282,23 @@ fn alloc_color(conn: &RustConnection, color_str: &str, cmap: Colormap) -> Result
                         scale_value!(hex_color.b, 255, 65535))?.reply()?.pixel as i32)
 }
 
+/// Convert allocated color back to hex string
+///
+/// # Arguments
+///
+/// * `pixel` - Pixel of the color; this assumes a TrueColor visual like alloc_color
+///
+/// # Returns
+///
+/// Either [`Some`] with a hex color string like #000000 or otherwise [`None`] when unset
+pub(crate) fn pixel_to_hex(pixel: i32) -> Option<String> {
+    if 0 > pixel {
+        return None;
+    }
+
+    Some(format!("#{:06x}", pixel & 0xffffff))
+}
+
 /// Parse style config
 ///
 /// # Arguments
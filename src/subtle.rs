@@ -18,7 +18,7 @@ use bitflags::bitflags;
 use anyhow::Result;
 use std::cell::{Cell, OnceCell, Ref, RefCell, RefMut};
 use std::sync::atomic::AtomicBool;
-use std::sync::Arc;
+use std::sync::{Arc, Mutex};
 use easy_min_max::max;
 use log::debug;
 use stdext::function_name;
@@ -30,10 +30,12 @@ use x11rb::rust_connection::RustConnection;
 use crate::ewmh::Atoms;
 use crate::font::Font;
 use crate::grab::Grab;
-use crate::plugin::Plugin;
+use crate::plugin::{HostCache, Plugin};
 use crate::screen::Screen;
+use crate::selection::Selection;
 use crate::style::{CalcSpacing, Style};
 use crate::tagging::Tagging;
+use crate::tooltip::Tooltip;
 use crate::tray::Tray;
 
 const HISTORY_SIZE: usize = 5;
@@ -76,12 +78,14 @@ bitflags! {
         const SKIP_POINTER_WARP = 1 << 14;
         /// Skip urgent warp
         const SKIP_URGENT_WARP = 1 << 15;
+        /// Using Xkb
+        const XKB = 1 << 16;
     }
 }
 
 pub(crate) struct Subtle {
     /// Config and state-flags
-    pub(crate) flags: SubtleFlags,
+    pub(crate) flags: Cell<SubtleFlags>,
     /// Total display width
     pub(crate) width: u16,
     /// Total display height
@@ -114,6 +118,10 @@ pub(crate) struct Subtle {
     pub(crate) support_win: Window,
     /// Support window for tray handling
     pub(crate) tray_win: Window,
+    /// Tooltip of panel items
+    pub(crate) tooltip: RefCell<Tooltip>,
+    /// Clipboard and primary selection
+    pub(crate) selection: RefCell<Selection>,
     /// Double buffer for panel drawing
     pub(crate) panel_double_buffer: Pixmap,
     /// Focus history list
@@ -170,6 +178,8 @@ pub(crate) struct Subtle {
     pub(crate) views: Vec<View>,
     /// Plugins list
     pub(crate) plugins: Vec<Plugin>,
+    /// Cache of host calls of all plugins
+    pub(crate) plugin_cache: Arc<Mutex<HostCache>>,
 }
 
 impl Subtle {
@@ -510,7 +520,7 @@ impl Subtle {
 impl Default for Subtle {
     fn default() -> Self {
         Subtle {
-            flags: SubtleFlags::TRAY,
+            flags: Cell::new(SubtleFlags::TRAY),
             width: 0,
             height: 0,
 
@@ -532,6 +542,8 @@ impl Default for Subtle {
 
             support_win: Window::default(),
             tray_win: Window::default(),
+            tooltip: RefCell::new(Tooltip::default()),
+            selection: RefCell::new(Selection::default()),
             panel_double_buffer: Pixmap::default(),
             focus_history: VecCell::from(vec![NONE; HISTORY_SIZE]),
 
@@ -564,6 +576,7 @@ impl Default for Subtle {
             tags: Vec::new(),
             views: Vec::new(),
             plugins: Vec::new(),
+            plugin_cache: Arc::new(Mutex::new(HostCache::default())),
         }
     }
 }
@@ -574,11 +587,11 @@ impl From<&Config> for Subtle {
 
         // CLI options
         if config.replace {
-            subtle.flags.insert(SubtleFlags::REPLACE);
+            subtle.flags.get_mut().insert(SubtleFlags::REPLACE);
         }
 
         if config.debug {
-            subtle.flags.insert(SubtleFlags::DEBUG);
+            subtle.flags.get_mut().insert(SubtleFlags::DEBUG);
         }
 
         // Config options
@@ -594,7 +607,7 @@ impl From<&Config> for Subtle {
         macro_rules! apply_config_flag {
             ($config_key:expr, $subtle_flag:path) => {
                 if let Some(MixedConfigVal::B(value)) = config.subtle.get($config_key) && *value {
-                    subtle.flags.insert($subtle_flag);
+                    subtle.flags.get_mut().insert($subtle_flag);
                 }
             };
         }
@@ -0,0 +1,31 @@
+///
+/// @package subtle-rs
+///
+/// @file Font tests
+/// @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+/// @version $Id$
+///
+/// This program can be distributed under the terms of the GNU GPLv3.
+/// See the file LICENSE for details.
+///
+
+use proptest::prelude::*;
+use crate::font;
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_reserve_columns_of_missing_glyphs(value in "[a-z]{0,8}", column_width in 1u16..16) {
+        let latin1 = |code: u16| code < 0x100;
+
+        // Glyphs of the font are measured by the server
+        prop_assert_eq!(font::calc_missing_width(&value, column_width, 0, latin1), 0);
+
+        // Wide glyphs need two columns each
+        let text = format!("{}漢字", value);
+
+        prop_assert_eq!(font::calc_missing_width(&text, column_width, 0, latin1), 4 * column_width);
+        prop_assert_eq!(font::calc_missing_width(&text, column_width, 1, latin1), 4 * column_width - 2);
+        prop_assert_eq!(font::calc_missing_width(&text, column_width, 0, |_| true), 0);
+    }
+}
@@ -13,6 +13,7 @@ use proptest::prelude::*;
 use std::collections::HashMap;
 use x11rb::protocol::xproto::{Keycode, Keysym, ModMask};
 use crate::grab;
+use crate::grab::{GrabAction, GrabFlags};
 
 proptest! {
     #![proptest_config(ProptestConfig::with_cases(10))]
@@ -49,3 +50,14 @@ proptest! {
         }
     }
 }
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_parse_reload_names(name in "[a-z][a-z_]{0,8}") {
+        prop_assert_eq!(grab::parse_name("reload_sublet").ok(),
+            Some((GrabFlags::PLUGIN_RELOAD, GrabAction::Command(String::new()))));
+        prop_assert_eq!(grab::parse_name(&format!("reload_sublet_{}", name)).ok(),
+            Some((GrabFlags::PLUGIN_RELOAD, GrabAction::Command(name.clone()))));
+    }
+}
@@ -0,0 +1,27 @@
+///
+/// @package subtle-rs
+///
+/// @file Keyboard tests
+/// @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+/// @version $Id$
+///
+/// This program can be distributed under the terms of the GNU GPLv3.
+/// See the file LICENSE for details.
+///
+
+use proptest::prelude::*;
+use crate::keyboard;
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_parse_symbols(layout in "[a-z]{2,3}", variant in "[a-z]{1,10}") {
+        let symbols = format!("pc+us+{}({}):2+inet(evdev)+group(alt_shift_toggle)", layout, variant);
+
+        prop_assert_eq!(keyboard::parse_symbols(&symbols), vec![
+            (String::from("us"), String::new()),
+            (layout.clone(), variant.clone()),
+        ]);
+        prop_assert!(keyboard::parse_symbols("pc+inet(evdev)").is_empty());
+    }
+}
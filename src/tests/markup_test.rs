@@ -0,0 +1,77 @@
+///
+/// @package subtle-rs
+///
+/// @file Markup tests
+/// @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+/// @version $Id$
+///
+/// This program can be distributed under the terms of the GNU GPLv3.
+/// See the file LICENSE for details.
+///
+
+use proptest::prelude::*;
+use crate::markup;
+use crate::markup::{Bar, Span};
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_parse_colors(text in "[a-z0-9 ]{1,10}", color in "#[0-9a-f]{6}") {
+        let spans = markup::parse(&format!("cpu <fg={}>{}</fg><bg={}>!</bg>", color, text, color));
+
+        prop_assert_eq!(spans, vec![
+            Span { text: "cpu ".into(), fg: None, bg: None, bar: None },
+            Span { text: text.clone(), fg: Some(color.clone()), bg: None, bar: None },
+            Span { text: "!".into(), fg: None, bg: Some(color.clone()), bar: None },
+        ]);
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_strip_unknown_and_unbalanced_tags(text in "[a-z0-9 ]{1,10}") {
+        prop_assert_eq!(markup::strip(&format!("<b>{}</b></fg>", text)), text.clone());
+        prop_assert_eq!(markup::parse(&format!("<fg=#ff0000>{}", text)), vec![
+            Span { text: text.clone(), fg: Some("#ff0000".into()), bg: None, bar: None },
+        ]);
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_roundtrip_escaped_text(text in "[a-z<>&;= ]{1,20}") {
+        prop_assert_eq!(markup::strip(&markup::escape(&text)), text);
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_keep_lone_brackets(a in 0u8..100, b in 0u8..100) {
+        let text = format!("{} < {} > 0 &", a, b);
+
+        prop_assert_eq!(markup::strip(&text), text);
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_parse_bars(value in -1.0f32..2.0, width in 0u16..200, color in "#[0-9a-f]{6}") {
+        let spans = markup::parse(&format!(r##"cpu <bar value="{}" width="{}" fg="{}" bg="#333"/> <fg={}><bar></fg>"##,
+            value, width, color, color));
+
+        prop_assert_eq!(spans, vec![
+            Span { text: "cpu ".into(), fg: None, bg: None, bar: None },
+            Span { text: "".into(), fg: Some(color.clone()), bg: Some("#333".into()),
+                bar: Some(Bar { value: value.clamp(0.0, 1.0), width: width.clamp(1, 100) }) },
+            Span { text: " ".into(), fg: None, bg: None, bar: None },
+            Span { text: "".into(), fg: Some(color.clone()), bg: None,
+                bar: Some(Bar { value: 0.0, width: 10 }) },
+        ]);
+
+        prop_assert_eq!(markup::strip(&format!(r#"<bar value="{}"/>%"#, value)), "%");
+    }
+}
@@ -15,4 +15,11 @@ mod tag_test;
 mod view_test;
 mod tagging;
 mod style_test;
-mod spacing_test;
\ No newline at end of file
+mod spacing_test;
+mod markup_test;
+mod text_test;
+mod font_test;
+mod keyboard_test;
+mod selection_test;
+#[cfg(feature = "plugins")]
+mod plugin_test;
\ No newline at end of file
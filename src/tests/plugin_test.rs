@@ -0,0 +1,1066 @@
+///
+/// @package subtle-rs
+///
+/// @file Plugin tests
+/// @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+/// @version $Id$
+///
+/// This program can be distributed under the terms of the GNU GPLv3.
+/// See the file LICENSE for details.
+///
+
+use proptest::prelude::*;
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+use std::sync::Arc;
+use std::sync::atomic::{AtomicUsize, Ordering};
+use std::time::{Duration, Instant};
+use x11rb::protocol::xproto::Rectangle;
+use crate::grab::{GrabAction, GrabFlags};
+use crate::keyboard::KeyboardLayout;
+use crate::panel::PanelFlags;
+use crate::plugin;
+use crate::tagging::Tagging;
+use crate::view::ViewBuilder;
+
+fn create_power_supply(name: &str, entries: &[(&str, String)]) -> PathBuf {
+    let base_path = std::env::temp_dir()
+        .join(format!("subtle-rs-power-supply-{}-{}", std::process::id(), name));
+    let battery_path = base_path.join("BAT0");
+
+    std::fs::create_dir_all(&battery_path).unwrap();
+
+    for (key, value) in entries {
+        std::fs::write(battery_path.join(key), value).unwrap();
+    }
+
+    base_path
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_read_battery_status(percent in 0u8..=100, power_now in 1u64..50_000_000) {
+        let base_path = create_power_supply(&format!("{}-{}", percent, power_now), &[
+            ("type", "Battery".into()),
+            ("status", "Discharging".into()),
+            ("capacity", percent.to_string()),
+            ("energy_now", "50000000".into()),
+            ("energy_full", "60000000".into()),
+            ("power_now", power_now.to_string()),
+        ]);
+
+        let status = plugin::read_battery_status(&base_path, "");
+
+        // Names must not escape the class dir even if they point to a battery
+        let escaping_names = [
+            format!("../{}/BAT0", base_path.file_name().unwrap().to_string_lossy()),
+            base_path.join("BAT0").to_string_lossy().into_owned(),
+        ];
+
+        for name in escaping_names {
+            prop_assert!(!plugin::read_battery_status(&base_path, &name).present);
+        }
+
+        std::fs::remove_dir_all(&base_path).unwrap();
+
+        prop_assert!(status.present);
+        prop_assert!(!status.charging);
+        prop_assert_eq!(status.name, Some("BAT0".into()));
+        prop_assert_eq!(status.percent, Some(percent));
+        prop_assert_eq!(status.time_remaining_secs, Some((50_000_000.0 / power_now as f64 * 3600.0) as u64));
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_not_find_missing_battery(name in "BAT[1-9]") {
+        let base_path = create_power_supply(&name, &[("type", "Mains".into())]);
+
+        let status = plugin::read_battery_status(&base_path, "");
+        let named_status = plugin::read_battery_status(&base_path, &name);
+
+        std::fs::remove_dir_all(&base_path).unwrap();
+
+        prop_assert!(!status.present);
+        prop_assert!(!named_status.present);
+        prop_assert_eq!(named_status.percent, None);
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_translate_time_tokens(text in "[a-z ]{0,10}") {
+        let format = format!("{}[weekday repr:long], [day]. [month repr:short] [year] [hour repr:12]:[minute] [period]", text);
+
+        prop_assert_eq!(plugin::translate_time_format(&format).unwrap(),
+                        format!("{}%A, %d. %b %Y %I:%M %p", text));
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_keep_strftime_format(format in "(%[HMSYmdaAbB]|[a-z:. ]){1,10}") {
+        prop_assert_eq!(plugin::translate_time_format(&format).unwrap(), format);
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_reject_unknown_time_tokens(token in "[a-z]{8,12}") {
+        prop_assert!(plugin::translate_time_format(&format!("[{}]", token)).is_err());
+        prop_assert!(plugin::translate_time_format(&format!("[{}", token)).is_err());
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_mark_unknown_timezone(zone in "[A-Z][a-z]{3,8}/[A-Z][a-z]{12,16}") {
+        let formatted = plugin::format_time(&format!("TZ={};[hour]:[minute]", zone)).unwrap();
+
+        prop_assert!(formatted.ends_with(" (TZ?)"));
+        prop_assert!(plugin::format_time("TZ=Europe/Berlin;[hour]:[minute]").unwrap().len() == 5);
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_parse_log_levels(level in "(?i:trace|debug|info|warn|error)") {
+        prop_assert_eq!(plugin::parse_log_level(&level).as_str(), level.to_uppercase().as_str());
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_parse_interval(millis in any::<u32>()) {
+        prop_assert_eq!(plugin::parse_export_value(millis.to_string().as_bytes()), Some(millis));
+        prop_assert!(plugin::parse_export_value(&millis.to_le_bytes()).is_some());
+        prop_assert_eq!(plugin::parse_export_value(b""), None);
+        prop_assert_eq!(plugin::parse_export_value(b"0"), Some(0));
+        prop_assert_eq!(plugin::parse_export_value(b"soon"), None);
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_run_command(text in "[a-z]{1,10}", code in 0i32..10) {
+        let output = plugin::run_command("sh", &["-c".into(),
+            format!("echo {}; echo {} >&2; exit {}", text, text, code)], Duration::from_secs(1)).unwrap();
+
+        prop_assert_eq!(output.stdout.trim(), text.as_str());
+        prop_assert_eq!(output.stderr.trim(), text.as_str());
+        prop_assert_eq!(output.exit_code, code);
+        prop_assert!(!output.timed_out);
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(2))]
+    #[test]
+    fn should_kill_command_after_timeout(millis in 10u64..50) {
+        let output = plugin::run_command("sleep", &["5".into()], Duration::from_millis(millis)).unwrap();
+
+        prop_assert!(output.timed_out);
+        prop_assert_eq!(output.exit_code, -1);
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_calc_cpu_usage(busy in 0u64..1000, idle in 1u64..1000) {
+        let prev_times = plugin::parse_cpu_times("cpu  100 0 100 800 0 0 0 0 0 0\ncpu0 100 0 100 800 0 0 0 0 0 0\nintr 1 2 3");
+        let cur_times = plugin::parse_cpu_times(&format!(
+            "cpu  {} 0 100 {} 0 0 0 0 0 0\ncpu0 {} 0 100 {} 0 0 0 0 0 0", 100 + busy, 800 + idle, 100 + busy, 800 + idle));
+
+        let warm_usage = plugin::calc_cpu_usage(&[], &prev_times);
+        let usage = plugin::calc_cpu_usage(&prev_times, &cur_times);
+
+        prop_assert!(warm_usage.warming_up);
+        prop_assert_eq!(warm_usage.aggregate, 20.0);
+        prop_assert!(!usage.warming_up);
+        prop_assert_eq!(usage.cores.len(), 1);
+        prop_assert_eq!(usage.aggregate, busy as f64 / (busy + idle) as f64 * 100.0);
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_parse_memory_info(total in 1000u64..100_000_000, available in 0u64..1000, swap in 0u64..1000) {
+        let info = plugin::parse_memory_info(&format!(
+            "MemTotal: {} kB\nMemFree: 10 kB\nMemAvailable: {} kB\nSwapTotal: {} kB\nSwapFree: 0 kB\nHugePages_Total: 0\n",
+            total, available, swap));
+
+        prop_assert_eq!(info.total_kb, total);
+        prop_assert_eq!(info.used_kb, total - available);
+        prop_assert_eq!(info.swap_used_kb, swap);
+        prop_assert_eq!(info.raw.get("MemFree"), Some(&10));
+        prop_assert_eq!(info.raw.get("HugePages_Total"), Some(&0));
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_calc_panel_geometry(x in 0i16..2000, width in 100u16..4000, height in 100u16..2000, panel_height in 10u16..50) {
+        let base = Rectangle { x, y: 0, width, height };
+
+        let top = plugin::calc_panel_geometry(&base, panel_height, false, 1, 2);
+        let bottom = plugin::calc_panel_geometry(&base, panel_height, true, 1, 2);
+
+        prop_assert_eq!((top.x, top.y, top.width, top.height), (x, 0, width, panel_height));
+        prop_assert_eq!(bottom.y, (height - panel_height) as i16);
+        prop_assert_eq!((bottom.monitor_index, bottom.monitor_count), (1, 2));
+        prop_assert_eq!((top.is_bottom, bottom.is_bottom), (false, true));
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_parse_run_output(text in "[a-z ]{0,16}", min_width in 0u16..200, column_width in 1u16..16) {
+        let plain = plugin::parse_run_output(&text);
+
+        prop_assert_eq!(plain.version, 0);
+        prop_assert_eq!(&plain.text, &text);
+        prop_assert!(plain.markup);
+
+        let envelope = plugin::parse_run_output(&format!(
+            r#"{{"version": 1, "text": "{}", "markup": false, "min_width": {}, "align": "right"}}"#, text, min_width));
+
+        prop_assert_eq!(envelope.version, 1);
+        prop_assert_eq!(&envelope.text, &text);
+        prop_assert!(!envelope.markup);
+        prop_assert_eq!(envelope.min_width, min_width);
+
+        // Minimum width is given in cells like set_min_width
+        prop_assert_eq!(plugin::calc_min_width(envelope.min_width, column_width, 4),
+            if 0 == min_width { 0 } else { min_width * column_width + 4 });
+        prop_assert_eq!(plugin::calc_min_width(plain.min_width, column_width, 4), 0);
+        prop_assert_eq!(envelope.align, Some(plugin::TextAlign::Right));
+        prop_assert_eq!(plain.align, None);
+
+        // JSON without version and unknown versions are plain text
+        let json = format!(r#"{{"text": "{}"}}"#, text);
+
+        prop_assert_eq!(plugin::parse_run_output(&json).text, json);
+        prop_assert_eq!(plugin::parse_run_output(r#"{"version": 99, "text": "x"}"#).version, 0);
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_check_allowed_hosts(host in "[a-z]{1,10}\\.(com|org)") {
+        let allowed_hosts = vec![host.clone(), String::from("*.example.com")];
+
+        prop_assert!(plugin::is_host_allowed(&allowed_hosts, &format!("https://{}/path?q=1", host)));
+        prop_assert!(plugin::is_host_allowed(&allowed_hosts, &format!("http://{}:8080", host.to_uppercase())));
+        prop_assert!(plugin::is_host_allowed(&allowed_hosts, "https://api.example.com/"));
+        prop_assert!(!plugin::is_host_allowed(&allowed_hosts, &format!("https://evil.{}/", host)));
+        prop_assert!(!plugin::is_host_allowed(&allowed_hosts, &format!("https://{}.evil.net/", host)));
+        prop_assert!(!plugin::is_host_allowed(&allowed_hosts, &format!("file://{}/etc/passwd", host)));
+        prop_assert!(!plugin::is_host_allowed(&[], &format!("https://{}/", host)));
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_persist_store_values(key in "[a-z]{1,8}", value in "[a-z0-9]{1,32}") {
+        let path = std::env::temp_dir()
+            .join(format!("subtle-rs-store-{}", std::process::id()))
+            .join("test.json");
+
+        let mut store = plugin::KvStore::load(path.clone());
+
+        prop_assert!(store.set(&key, &value).is_ok());
+        prop_assert_eq!(plugin::KvStore::load(path.clone()).values.get(&key), Some(&value));
+
+        // Exceed size limit
+        prop_assert!(store.set(&key, &"x".repeat(64 * 1024)).is_err());
+        prop_assert_eq!(store.values.get(&key), Some(&value));
+
+        // Remove value again
+        prop_assert!(store.set(&key, "").is_ok());
+        prop_assert!(plugin::KvStore::load(path.clone()).values.is_empty());
+
+        let _ = std::fs::remove_dir_all(path.parent().unwrap());
+    }
+
+    #[test]
+    fn should_sanitize_store_path(name in "[a-z./]{1,16}") {
+        if let Some(path) = plugin::store_path(&name) {
+            let file_name = path.file_name().unwrap().to_string_lossy().into_owned();
+
+            prop_assert!(!file_name.contains('/'));
+            prop_assert!(path.parent().unwrap().ends_with("subtle-rs/plugins"));
+        }
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_parse_wm_commands(view_name in "[a-z]{1,8}", action in "[a-z]{1,8}") {
+        let view_names = vec![String::from("terminal"), view_name.clone()];
+
+        let command = |action: &str, arg: &str| plugin::WmCommand {
+            action: action.to_string(),
+            arg: arg.to_string(),
+        };
+
+        prop_assert_eq!(plugin::parse_wm_command(&command("switch_view", &view_name), &view_names, false),
+            Ok((GrabFlags::VIEW_SWITCH, GrabAction::Index(if "terminal" == view_name { 1 } else { 2 }))));
+        prop_assert_eq!(plugin::parse_wm_command(&command("switch_view", "0"), &view_names, false),
+            Ok((GrabFlags::VIEW_SWITCH, GrabAction::Index(1))));
+        prop_assert_eq!(plugin::parse_wm_command(&command("switch_view", "9"), &view_names, false),
+            Err(plugin::WmCommandError::InvalidArgument));
+        prop_assert_eq!(plugin::parse_wm_command(&command("spawn", "xterm"), &view_names, false),
+            Err(plugin::WmCommandError::NotAllowed));
+        prop_assert_eq!(plugin::parse_wm_command(&command("spawn", "xterm"), &view_names, true),
+            Ok((GrabFlags::COMMAND, GrabAction::Command(String::from("xterm")))));
+        prop_assert_eq!(plugin::parse_wm_command(&command(&format!("x{}", action), ""), &view_names, true),
+            Err(plugin::WmCommandError::UnknownAction));
+        prop_assert_eq!(plugin::parse_wm_command(&command("focus_client", "4194305"), &view_names, false),
+            Ok((GrabFlags::WINDOW_FOCUS, GrabAction::Index(4194305))));
+        prop_assert_eq!(plugin::parse_wm_command(&command("focus_client", &view_name), &view_names, false),
+            Err(plugin::WmCommandError::InvalidArgument));
+        prop_assert_eq!(plugin::parse_wm_command(&command("reload_sublet", &view_name), &view_names, false),
+            Ok((GrabFlags::PLUGIN_RELOAD, GrabAction::Command(view_name.clone()))));
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_calc_net_throughput(rx in 0u64..1_000_000, tx in 0u64..1_000_000, secs in 1u64..10) {
+        let prev_sample = plugin::NetSample { rx_bytes: 1000, tx_bytes: 1000, time: Instant::now() };
+        let cur_sample = plugin::NetSample {
+            rx_bytes: 1000 + rx,
+            tx_bytes: 1000 + tx,
+            time: prev_sample.time + Duration::from_secs(secs),
+        };
+
+        let throughput = plugin::calc_net_throughput("eth0", Some(&prev_sample), &cur_sample);
+
+        prop_assert!(throughput.present);
+        prop_assert!(!throughput.warming_up);
+        prop_assert!((throughput.rx_bytes_per_sec - rx as f64 / secs as f64).abs() < 0.001);
+        prop_assert!((throughput.tx_bytes_per_sec - tx as f64 / secs as f64).abs() < 0.001);
+        prop_assert_eq!(throughput.rx_total, 1000 + rx);
+
+        // Reset counters and first samples have no rate
+        let reset = plugin::calc_net_throughput("eth0", Some(&cur_sample), &prev_sample);
+
+        prop_assert_eq!(reset.rx_bytes_per_sec, 0.0);
+        prop_assert!(plugin::calc_net_throughput("eth0", None, &cur_sample).warming_up);
+    }
+
+    #[test]
+    fn should_parse_default_route(metric in 0u32..100) {
+        let route = format!("Iface\tDestination\tGateway\tFlags\tRefCnt\tUse\tMetric\tMask\tMTU\tWindow\tIRTT\n\
+            wlan0\t00000000\t0101A8C0\t0003\t0\t0\t{}\t00000000\t0\t0\t0\n\
+            eth0\t00000000\t0101A8C0\t0003\t0\t0\t{}\t00000000\t0\t0\t0\n\
+            eth0\t0001A8C0\t00000000\t0001\t0\t0\t0\t00FFFFFF\t0\t0\t0\n", metric + 1, metric);
+
+        prop_assert_eq!(plugin::parse_default_route(&route), Some(String::from("eth0")));
+        prop_assert_eq!(plugin::parse_default_route("Iface\tDestination\n"), None);
+    }
+
+    #[test]
+    fn should_not_find_missing_iface(iface in "[a-z]{1,8}") {
+        prop_assert!(plugin::read_net_sample(&std::env::temp_dir().join("subtle-rs-no-net"), &iface).is_none());
+        prop_assert!(plugin::read_net_sample(Path::new("/sys/class/net"), "../../etc").is_none());
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_parse_capabilities(host in "[a-z]{1,10}\\.com") {
+        let capabilities = plugin::parse_capabilities(&[String::from("exec"), String::from("kv"),
+            format!("http:{}", host), String::from("http:*.example.com"), String::from("unknown")]);
+
+        prop_assert!(capabilities.exec);
+        prop_assert!(capabilities.kv);
+        prop_assert!(!capabilities.command);
+        prop_assert!(!capabilities.clipboard);
+        prop_assert!(plugin::parse_capabilities(&[String::from("clipboard")]).clipboard);
+        prop_assert_eq!(&capabilities.http_hosts, &vec![host.clone(), String::from("*.example.com")]);
+
+        // Check against config
+        prop_assert_eq!(plugin::find_ungranted_capabilities(&capabilities, true,
+            &[host.clone(), String::from("*.example.com")]), Vec::<String>::new());
+        prop_assert_eq!(plugin::find_ungranted_capabilities(&capabilities, false, &[host.clone()]),
+            vec![String::from("exec"), String::from("http:*.example.com")]);
+        prop_assert_eq!(plugin::find_ungranted_capabilities(&capabilities, true, &[String::from("*.com")]),
+            vec![String::from("http:*.example.com")]);
+    }
+
+    #[test]
+    fn should_calc_disk_usage(used in 0u64..1000, available in 0u64..1000, reserved in 0u64..100) {
+        let blocks = used + available + reserved;
+
+        let usage = plugin::calc_disk_usage(4096, blocks, available + reserved, available);
+
+        prop_assert_eq!(usage.total_bytes, blocks * 4096);
+        prop_assert_eq!(usage.used_bytes, used * 4096);
+        prop_assert_eq!(usage.available_bytes, available * 4096);
+        prop_assert!(usage.percent <= 100);
+
+        // Reserved blocks count neither as used nor as available
+        if 0 < used + available {
+            prop_assert_eq!(usage.percent as u64, (used * 100).div_ceil(used + available));
+        }
+    }
+
+    #[test]
+    fn should_parse_volume(left in 0u64..150, right in 0u64..150) {
+        let output = format!("Volume: front-left: 32768 / {:>3}% / -18.06 dB,   front-right: 32768 / {:>3}% / -18.06 dB\n        \
+            balance 0.00\n", left, right);
+
+        prop_assert_eq!(plugin::parse_volume(&output), Some(((left + right) / 2) as u8));
+        prop_assert_eq!(plugin::parse_volume("Connection failure\n"), None);
+        prop_assert_eq!(plugin::parse_mute("Mute: yes\n"), Some(true));
+        prop_assert_eq!(plugin::parse_mute("Mute: no\n"), Some(false));
+    }
+
+    #[test]
+    fn should_calc_volume(percent in 0u8..=100, delta in -200i32..200) {
+        let relative = plugin::VolumeRequest { delta: Some(delta), ..plugin::VolumeRequest::default() };
+        let absolute = plugin::VolumeRequest { percent: Some(delta), ..plugin::VolumeRequest::default() };
+
+        prop_assert_eq!(plugin::calc_volume(percent, &relative), Some((percent as i32 + delta).clamp(0, 100) as u8));
+        prop_assert_eq!(plugin::calc_volume(percent, &absolute), Some(delta.clamp(0, 100) as u8));
+        prop_assert_eq!(plugin::calc_volume(percent, &plugin::VolumeRequest::default()), None);
+    }
+
+    #[test]
+    fn should_sanitize_text(title in "[a-zA-Z0-9 ]{1,32}", max_len in 1usize..64) {
+        let sanitized = plugin::sanitize_text(&format!("\u{1b}[1m{}\u{fffd}\n", title), max_len);
+
+        prop_assert!(!sanitized.chars().any(|ch| ch.is_control() || '\u{fffd}' == ch));
+        prop_assert!(sanitized.chars().count() <= max_len);
+
+        if title.len() + 3 <= max_len {
+            prop_assert_eq!(sanitized, format!("[1m{}", title));
+        } else {
+            prop_assert!(sanitized.ends_with('…'));
+        }
+    }
+
+    #[test]
+    fn should_read_temperatures(millis in 20_000u32..100_000) {
+        let base_path = std::env::temp_dir()
+            .join(format!("subtle-rs-hwmon-{}-{}", std::process::id(), millis));
+        let chip_path = base_path.join("hwmon0");
+
+        std::fs::create_dir_all(&chip_path).unwrap();
+        std::fs::write(chip_path.join("name"), "coretemp\n").unwrap();
+        std::fs::write(chip_path.join("temp1_input"), format!("{}\n", millis)).unwrap();
+        std::fs::write(chip_path.join("temp1_label"), "Package id 0\n").unwrap();
+        std::fs::write(chip_path.join("temp1_crit"), "100000\n").unwrap();
+        std::fs::write(chip_path.join("temp3_input"), "42000\n").unwrap();
+
+        let temperatures = plugin::read_temperatures(&base_path);
+
+        std::fs::remove_dir_all(&base_path).unwrap();
+
+        prop_assert_eq!(temperatures.len(), 2);
+        prop_assert_eq!(&temperatures[0].label, "Package id 0");
+        prop_assert!((temperatures[0].celsius - millis as f64 / 1000.0).abs() < 0.001);
+        prop_assert_eq!(temperatures[0].high, None);
+        prop_assert_eq!(temperatures[0].critical, Some(100.0));
+        prop_assert_eq!(&temperatures[1].label, "coretemp temp3");
+
+        // Machines without sensors just have none
+        prop_assert!(plugin::read_temperatures(&std::env::temp_dir().join("subtle-rs-no-hwmon")).is_empty());
+    }
+
+    #[test]
+    fn should_parse_load_average(load in 0u32..10_000, running in 0u32..100, total in 100u32..10_000) {
+        let loadavg = format!("{:.2} 0.58 {:.2} {}/{} 56789\n", load as f64 / 100.0, load as f64 / 50.0, running, total);
+
+        let parsed = plugin::parse_load_average(&loadavg).unwrap();
+
+        prop_assert!((parsed.one - load as f64 / 100.0).abs() < 0.001);
+        prop_assert!((parsed.five - 0.58).abs() < 0.001);
+        prop_assert!((parsed.fifteen - load as f64 / 50.0).abs() < 0.001);
+        prop_assert_eq!(parsed.running_procs, running);
+        prop_assert_eq!(parsed.total_procs, total);
+        prop_assert_eq!(plugin::parse_load_average("0.52 0.58\n"), None);
+    }
+
+    #[test]
+    fn should_parse_uptime(secs in 0u64..10_000_000, fraction in 0u32..100) {
+        prop_assert_eq!(plugin::parse_uptime(&format!("{}.{:02} 54321.00\n", secs, fraction)), Some(secs));
+        prop_assert_eq!(plugin::parse_uptime(""), None);
+    }
+
+    #[test]
+    fn should_escape_gvariant_strings(text in "[a-zA-Z0-9 ]{0,16}") {
+        prop_assert_eq!(plugin::escape_gvariant_string(&text), format!("\"{}\"", text));
+        prop_assert_eq!(plugin::escape_gvariant_string(&format!("\"{}\\\n\u{7}", text)),
+            format!("\"\\\"{}\\\\\\n\"", text));
+    }
+
+    #[test]
+    fn should_parse_notification_ids(id in 1u32..u32::MAX) {
+        prop_assert_eq!(plugin::parse_notification_id(&format!("(uint32 {},)\n", id)), Some(id));
+        prop_assert_eq!(plugin::parse_notification_id("Error: GDBus.Error"), None);
+        prop_assert_eq!(plugin::parse_urgency("critical"), Some(2));
+        prop_assert_eq!(plugin::parse_urgency("urgent"), None);
+    }
+
+    #[test]
+    fn should_parse_media_status(title in "[a-zA-Z0-9 ]{1,16}", secs in 0u64..1000) {
+        let output = format!("spotify\tpaused\tArtist\tOther\tAlbum\t0\t1000000\n\
+            mpv\tplaying\t\t{}\t\t{}\t\n", title, secs * 1_000_000 + 999);
+
+        let status = plugin::parse_media_status(&output);
+
+        prop_assert_eq!(status.player, Some(String::from("mpv")));
+        prop_assert_eq!(&status.status, "playing");
+        prop_assert_eq!(&status.title, &title);
+        prop_assert_eq!(status.position_secs, Some(secs));
+        prop_assert_eq!(status.length_secs, None);
+
+        // Fall back to the first player and stop without players
+        prop_assert_eq!(plugin::parse_media_status(output.lines().next().unwrap()).player,
+            Some(String::from("spotify")));
+        prop_assert_eq!(plugin::parse_media_status(""), plugin::MediaStatus::default());
+    }
+
+    #[test]
+    fn should_cache_host_calls(args in "[a-z]{1,8}", value in "[a-z0-9]{1,16}") {
+        let mut cache = plugin::HostCache::default();
+
+        prop_assert_eq!(cache.get_or_insert_with("get_uptime", &args, || Ok(value.clone())).unwrap(), value.clone());
+        prop_assert_eq!(cache.get_or_insert_with("get_uptime", &args, || Ok(String::from("other"))).unwrap(), value.clone());
+        prop_assert_eq!(cache.get_or_insert_with("get_volume", &args, || Ok(String::from("other"))).unwrap(), "other");
+
+        // Errors are passed along, but not cached
+        prop_assert!(cache.get_or_insert_with("get_memory_info", "", || Err(anyhow::anyhow!("failed"))).is_err());
+        prop_assert_eq!(cache.get_or_insert_with("get_memory_info", "", || Ok(value.clone())).unwrap(), value.clone());
+
+        cache.invalidate("get_uptime");
+
+        prop_assert_eq!(cache.values.len(), 2);
+
+        // Pointer is only queried once per cycle
+        let mut queries = 0;
+
+        for _ in 0..3 {
+            let pointer = cache.pointer_or_insert_with(|| {
+                queries += 1;
+
+                Some(plugin::PointerInfo { x: value.len() as i16, ..Default::default() })
+            });
+
+            prop_assert_eq!(pointer.map(|pointer| pointer.x), Some(value.len() as i16));
+        }
+
+        prop_assert_eq!(queries, 1);
+
+        // Keyboard layout survives the cycle
+        let layout = cache.keyboard_layout_or_insert_with(|| Some(KeyboardLayout {
+            layout: args.clone(),
+            ..Default::default()
+        }));
+
+        prop_assert_eq!(layout.map(|layout| layout.layout), Some(args.clone()));
+
+        cache.clear();
+
+        prop_assert!(cache.values.is_empty());
+        prop_assert!(cache.pointer.is_none());
+        prop_assert_eq!(cache.keyboard_layout_or_insert_with(|| None).map(|layout| layout.layout), Some(args.clone()));
+    }
+
+    #[test]
+    fn should_lookup_config_values(value in "[a-z0-9_%]{1,16}", key in "[a-z]{1,8}") {
+        let config_values: HashMap<String, String> = HashMap::from([
+            (String::from("units"), value.clone()),
+            (String::from("password"), value.clone()),
+            (key.clone(), value.clone()),
+        ]);
+
+        prop_assert_eq!(plugin::lookup_config_value(&config_values, "units"), value.clone());
+        prop_assert_eq!(plugin::lookup_config_value(&config_values, "locale"), "");
+        prop_assert_eq!(plugin::lookup_config_value(&config_values, "password"), "");
+
+        if !["locale", "units"].contains(&key.as_str()) {
+            prop_assert_eq!(plugin::lookup_config_value(&config_values, &key), "");
+        }
+    }
+
+    #[test]
+    fn should_parse_wifi_status(ssid in "[a-zA-Z0-9]{1,16}", dbm in -110i32..-20, freq in 2400u32..6000, link in 1u32..=70) {
+        let output = format!("Connected to aa:bb:cc:dd:ee:ff (on wlan0)\n\tSSID: {}\n\tfreq: {}.0\n\t\
+            RX: 1234 bytes (10 packets)\n\tsignal: {} dBm\n\trx bitrate: 433.3 MBit/s VHT-MCS 9\n", ssid, freq, dbm);
+
+        let status = plugin::parse_iw_link("wlan0", &output);
+
+        prop_assert!(status.connected);
+        prop_assert_eq!(&status.ssid, &ssid);
+        prop_assert_eq!(status.frequency_mhz, freq);
+        prop_assert_eq!(status.signal_percent, (2 * (dbm + 100)).clamp(0, 100) as u8);
+        prop_assert!((status.bitrate_mbps - 433.3).abs() < 0.001);
+        prop_assert!(!plugin::parse_iw_link("wlan0", "Not connected.\n").connected);
+
+        let wireless = format!("Inter-| sta-|   Quality        |   Discarded packets               | Missed | WE\n \
+            face | tus | link level noise |  nwid  crypt   frag  retry   misc | beacon | 22\n \
+            wlan0: 0000   {}.  -56.  -256        0      0      0      0     20        0\n", link);
+
+        let status = plugin::parse_proc_wireless("wlan0", &wireless).unwrap();
+
+        prop_assert!(status.connected);
+        prop_assert_eq!(status.signal_percent, (link as f64 * 100.0 / 70.0).round() as u8);
+        prop_assert_eq!(plugin::parse_proc_wireless("eth0", &wireless), None);
+    }
+
+    #[test]
+    fn should_create_error_output(text in "[a-z ]{1,16}", min_width in 0u16..200) {
+        let last_output = plugin::RunOutput { min_width, ..plugin::parse_run_output(&text) };
+
+        let output = plugin::error_output(Some(&last_output), "!", Some("#ff0000"), false);
+
+        prop_assert!(output.failed);
+        prop_assert_eq!(&output.text, "<fg=#ff0000>!</fg>");
+        prop_assert_eq!(output.min_width, min_width);
+
+        let output = plugin::error_output(Some(&last_output), "<!>", None, true);
+
+        prop_assert_eq!(&output.text, &format!("{} &lt;!&gt;", text));
+        prop_assert_eq!(&plugin::error_output(None, "!", None, true).text, "!");
+    }
+
+    #[test]
+    fn should_calc_backoff(interval in 0u64..600, failures in 1u32..64) {
+        let interval = Duration::from_secs(interval);
+        let backoff = plugin::calc_backoff(interval, failures);
+
+        prop_assert!(backoff >= interval.max(Duration::from_secs(1)).min(interval.max(Duration::from_secs(300))));
+        prop_assert!(backoff <= interval.max(Duration::from_secs(300)));
+        prop_assert!(plugin::calc_backoff(interval, failures + 1) >= backoff);
+        prop_assert_eq!(plugin::calc_backoff(interval, 1), interval.max(Duration::from_secs(1)));
+    }
+
+    #[test]
+    fn should_parse_click_actions(view in "[a-z]{1,8}", button in 1u8..10) {
+        let output = plugin::parse_run_output(&format!(
+            r#"{{"version": 1, "text": "x", "click_actions": {{"{}": {{"action": "switch_view", "arg": "{}"}}, "3": {{"action": "restart"}}}}}}"#,
+            button, view));
+
+        prop_assert_eq!(output.click_actions.get(&button).map(|command| command.arg.as_str()), Some(view.as_str()));
+        prop_assert_eq!(output.click_actions.get(&3).map(|command| command.action.as_str()), Some("restart"));
+        prop_assert!(plugin::parse_run_output("x").click_actions.is_empty());
+    }
+
+    #[test]
+    fn should_summarize_views(name in "[a-z]{1,8}", nclients in 0usize..8) {
+        let mut views = Vec::new();
+
+        for (view_name, tags) in [(name.clone(), Tagging::TAG1), (String::from("www"), Tagging::TAG2)] {
+            let mut builder = ViewBuilder::default();
+
+            builder.name(view_name).tags(tags);
+
+            views.push(builder.build().unwrap());
+        }
+
+        let client_tags = vec![Tagging::TAG1; nclients];
+
+        let summaries = plugin::summarize_views(&views, &client_tags, Tagging::TAG2, Some(1));
+
+        prop_assert_eq!(summaries.len(), 2);
+        prop_assert_eq!(&summaries[0].name, &name);
+        prop_assert_eq!(summaries[0].client_count, nclients);
+        prop_assert_eq!(summaries[0].occupied, 0 < nclients);
+        prop_assert!(!summaries[0].active && !summaries[0].urgent);
+        prop_assert_eq!(summaries[1].index, 1);
+        prop_assert!(summaries[1].active && summaries[1].urgent && !summaries[1].occupied);
+    }
+
+    #[test]
+    fn should_calc_retry_delay(backoff_ms in 1u64..1000, attempt in 1u32..4) {
+        let backoff = Duration::from_millis(backoff_ms);
+        let remaining = Duration::from_secs(60);
+
+        prop_assert_eq!(plugin::calc_retry_delay(backoff, 1, remaining), Some(backoff));
+        prop_assert_eq!(plugin::calc_retry_delay(backoff, attempt + 1, remaining),
+            plugin::calc_retry_delay(backoff, attempt, remaining).map(|delay| delay * 2));
+        prop_assert_eq!(plugin::calc_retry_delay(backoff, attempt, backoff), None);
+
+        let response = plugin::HttpResponse { status: 200, ..plugin::HttpResponse::default() };
+
+        prop_assert!(!plugin::is_transient_failure(&response));
+        prop_assert!(plugin::is_transient_failure(&plugin::HttpResponse { status: 503, ..response.clone() }));
+        prop_assert!(plugin::is_transient_failure(&plugin::HttpResponse {
+            status: 0, error: Some(String::from("Connection refused")), ..response.clone() }));
+        prop_assert!(!plugin::is_transient_failure(&plugin::HttpResponse { status: 404, ..response }));
+    }
+
+    #[test]
+    fn should_format_durations(hours in 0i64..100, minutes in 0i64..60) {
+        let seconds = hours * 3600 + minutes * 60;
+
+        prop_assert_eq!(plugin::format_duration(seconds, "clock").unwrap(),
+            format!("{:02}:{:02}:00", hours, minutes));
+        prop_assert_eq!(plugin::format_duration(-seconds - 1, "clock").unwrap(),
+            format!("-{:02}:{:02}:01", hours, minutes));
+        prop_assert_eq!(plugin::format_duration(7380, "compact").unwrap(), "2h3m");
+        prop_assert_eq!(plugin::format_duration(90061, "compact").unwrap(), "1d1h1m1s");
+        prop_assert_eq!(plugin::format_duration(7380, "long").unwrap(), "2 hours 3 minutes");
+        prop_assert_eq!(plugin::format_duration(0, "long").unwrap(), "0 seconds");
+        prop_assert_eq!(plugin::format_duration(61, "long").unwrap(), "1 minute 1 second");
+        prop_assert!(plugin::format_duration(seconds, "x").is_err());
+    }
+
+    #[test]
+    fn should_parse_times(timestamp in 0i64..4_000_000_000) {
+        let time = chrono::DateTime::from_timestamp(timestamp, 0).unwrap().to_rfc3339();
+
+        prop_assert_eq!(plugin::parse_time(&time).unwrap(), timestamp);
+        prop_assert_eq!(plugin::parse_time("1970-01-01T01:00:00+01:00").unwrap(), 0);
+        prop_assert!(plugin::parse_time("yesterday").is_err());
+    }
+
+    #[test]
+    fn should_parse_placement(position in -100i32..100) {
+        let placement = plugin::parse_placement(&format!(r#"{{"side": "right", "position": {}}}"#, position)).unwrap();
+
+        prop_assert_eq!(placement.side, Some(plugin::PanelSide::Right));
+        prop_assert_eq!(placement.position, Some(position));
+        prop_assert_eq!(plugin::parse_placement("{}").unwrap(), plugin::Placement::default());
+        prop_assert!(plugin::parse_placement(r#"{"side": "top"}"#).is_err());
+        prop_assert_eq!(plugin::PanelSide::Center.to_panel_flags(), PanelFlags::CENTER_POS);
+    }
+
+    #[test]
+    fn should_read_and_write_brightness(raw in 0u32..=7, delta in -20i32..20) {
+        let base_path = std::env::temp_dir()
+            .join(format!("subtle-rs-backlight-{}-{}-{}", std::process::id(), raw, delta));
+        let device_path = base_path.join("intel_backlight");
+
+        std::fs::create_dir_all(&device_path).unwrap();
+        std::fs::write(device_path.join("brightness"), raw.to_string()).unwrap();
+        std::fs::write(device_path.join("max_brightness"), "7").unwrap();
+
+        let status = plugin::read_brightness(&base_path, "");
+
+        prop_assert!(status.present);
+        prop_assert_eq!(status.name.as_deref(), Some("intel_backlight"));
+        prop_assert_eq!(status.percent, (raw as f64 / 7.0 * 100.0).round() as u8);
+
+        let request = plugin::BrightnessRequest { delta: Some(delta), ..plugin::BrightnessRequest::default() };
+        let new_raw = plugin::calc_brightness(&status, &request).unwrap();
+
+        prop_assert!(new_raw <= 7);
+        prop_assert!(0 == delta || new_raw != raw || (0 == raw && 0 > delta) || (7 == raw && 0 < delta));
+
+        let status = plugin::write_brightness(&base_path, &plugin::BrightnessRequest {
+            percent: Some(100), ..plugin::BrightnessRequest::default() });
+
+        prop_assert_eq!((status.raw, status.percent, status.error), (7, 100, None));
+        prop_assert!(!plugin::read_brightness(&base_path, "acpi_video0").present);
+        prop_assert!(plugin::write_brightness(&base_path, &plugin::BrightnessRequest {
+            device: String::from("acpi_video0"), ..plugin::BrightnessRequest::default() }).error.is_some());
+
+        // Names must not escape the class dir even if they point to a device
+        let escaping_names = [
+            format!("../{}/intel_backlight", base_path.file_name().unwrap().to_string_lossy()),
+            device_path.to_string_lossy().into_owned(),
+            String::from(".."),
+        ];
+
+        for name in escaping_names {
+            prop_assert!(!plugin::read_brightness(&base_path, &name).present);
+            prop_assert!(plugin::write_brightness(&base_path, &plugin::BrightnessRequest {
+                device: name, percent: Some(0), ..plugin::BrightnessRequest::default() }).error.is_some());
+        }
+
+        prop_assert_eq!(std::fs::read_to_string(device_path.join("brightness")).unwrap(), "7");
+
+        std::fs::remove_dir_all(&base_path).unwrap();
+    }
+
+    #[test]
+    fn should_count_mail(nnew in 0usize..5, nseen in 0usize..5, nunseen in 0usize..5) {
+        let base_path = std::env::temp_dir()
+            .join(format!("subtle-rs-maildir-{}-{}-{}-{}", std::process::id(), nnew, nseen, nunseen));
+
+        for dir_name in ["new", "cur", "tmp"] {
+            std::fs::create_dir_all(base_path.join(dir_name)).unwrap();
+        }
+
+        for idx in 0..nnew {
+            std::fs::write(base_path.join("new").join(format!("{}.mail", idx)), "").unwrap();
+        }
+
+        for idx in 0..nseen {
+            std::fs::write(base_path.join("cur").join(format!("{}.seen:2,FS", idx)), "").unwrap();
+        }
+
+        for idx in 0..nunseen {
+            std::fs::write(base_path.join("cur").join(format!("{}.unseen:2,R", idx)), "").unwrap();
+        }
+
+        std::fs::write(base_path.join("cur").join("trashed:2,ST"), "").unwrap();
+
+        let path = base_path.to_string_lossy().into_owned();
+        let paths = plugin::parse_mail_paths(&serde_json::to_string(&[&path, "/nonexistent/Maildir"]).unwrap());
+        let mail_count = plugin::count_mail(&paths);
+
+        std::fs::remove_dir_all(&base_path).unwrap();
+
+        prop_assert_eq!(mail_count.new, nnew as u32);
+        prop_assert_eq!(mail_count.unread, (nnew + nunseen) as u32);
+        prop_assert_eq!(mail_count.total, (nnew + nseen + nunseen) as u32);
+        prop_assert_eq!(mail_count.accounts.len(), 2);
+        prop_assert!(mail_count.accounts[0].present && !mail_count.accounts[1].present);
+        prop_assert_eq!(plugin::parse_mail_paths(&path), vec![path]);
+    }
+
+    #[test]
+    fn should_read_processes(utime in 0u64..1000, rss_kb in 0u64..100_000) {
+        let base_path = std::env::temp_dir()
+            .join(format!("subtle-rs-proc-{}-{}-{}", std::process::id(), utime, rss_kb));
+
+        let write_process = |pid: u32, name: &str, utime: u64| {
+            let pid_path = base_path.join(pid.to_string());
+
+            std::fs::create_dir_all(&pid_path).unwrap();
+            std::fs::write(pid_path.join("stat"),
+                format!("{} ({}) S 1 1 1 0 -1 0 0 0 0 0 {} 0 0 0 20 0 1 0 100", pid, name, utime)).unwrap();
+            std::fs::write(pid_path.join("status"),
+                format!("Name:\t{}\nVmRSS:\t{} kB\n", name, rss_kb)).unwrap();
+        };
+
+        write_process(100, "my (vpn)", 0);
+        write_process(200, "build", 0);
+        std::fs::write(base_path.join("version"), "").unwrap();
+
+        let mut samples = HashMap::new();
+        let now = Instant::now();
+
+        let read_processes = |samples: &mut HashMap<u32, plugin::ProcSample>, now: Instant| {
+            plugin::calc_processes(&base_path, "my (vpn)", &plugin::scan_processes(&base_path, "my (vpn)"), samples, now)
+        };
+
+        let processes = read_processes(&mut samples, now);
+
+        prop_assert_eq!(processes.len(), 1);
+        prop_assert_eq!(processes[0].pid, 100);
+        prop_assert_eq!(processes[0].rss_kb, rss_kb);
+        prop_assert_eq!(processes[0].state.as_str(), "sleeping");
+        prop_assert!(processes[0].warming_up);
+
+        // Second sample a second later
+        write_process(100, "my (vpn)", utime);
+
+        let processes = read_processes(&mut samples, now + Duration::from_secs(1));
+
+        prop_assert!(!processes[0].warming_up);
+        prop_assert!((processes[0].cpu_percent - utime as f64).abs() < 0.001);
+
+        // Other instances sharing the counters of the cycle keep their own samples
+        let counters = plugin::scan_processes(&base_path, "my (vpn)");
+        let mut other_samples = HashMap::new();
+
+        prop_assert!(plugin::calc_processes(&base_path, "my (vpn)", &counters, &mut other_samples, now)
+            .first().is_some_and(|process| process.warming_up));
+
+        // Stopped processes are an empty list and drop their samples
+        std::fs::remove_dir_all(base_path.join("100")).unwrap();
+
+        prop_assert!(read_processes(&mut samples, now).is_empty());
+        prop_assert!(samples.is_empty());
+
+        std::fs::remove_dir_all(&base_path).unwrap();
+    }
+
+    #[test]
+    fn should_run_jobs_in_background(value in 0u32..1000) {
+        let mut job = plugin::Job::spawn(move || value);
+
+        // Wait a bit for the thread without blocking forever
+        for _ in 0..100 {
+            if job.poll() {
+                break;
+            }
+
+            std::thread::sleep(Duration::from_millis(10));
+        }
+
+        prop_assert!(matches!(job, plugin::Job::Done(result) if result == value));
+        prop_assert!(!job.poll());
+
+        let mut failed_job: plugin::Job<u32> = plugin::Job::spawn(|| panic!("Job died"));
+
+        for _ in 0..100 {
+            if failed_job.poll() {
+                break;
+            }
+
+            std::thread::sleep(Duration::from_millis(10));
+        }
+
+        prop_assert!(matches!(failed_job, plugin::Job::Failed));
+    }
+
+    #[test]
+    fn should_run_same_job_again_after_handover(key in "[a-z]{1,8}") {
+        let runs = Arc::new(AtomicUsize::new(0));
+        let mut jobs: plugin::Jobs<usize> = plugin::Jobs::default();
+
+        // Toggles like play-pause have to run on every call
+        for run in 1..=2 {
+            let mut status = plugin::JobStatus::Pending;
+
+            for _ in 0..100 {
+                let runs = runs.clone();
+
+                status = jobs.run(&key, 4, move || runs.fetch_add(1, Ordering::SeqCst) + 1);
+
+                if plugin::JobStatus::Pending != status {
+                    break;
+                }
+
+                std::thread::sleep(Duration::from_millis(10));
+            }
+
+            prop_assert_eq!(status, plugin::JobStatus::Done(run));
+
+            // Runs for other panels within the same call get the same result
+            prop_assert_eq!(jobs.run(&key, 4, || 0), plugin::JobStatus::Done(run));
+
+            jobs.drop_handed_over();
+        }
+
+        prop_assert_eq!(runs.load(Ordering::SeqCst), 2);
+        prop_assert!(jobs.entries.is_empty());
+
+        // Short jobs return their result with the first call
+        prop_assert_eq!(jobs.run_waiting(&key, 4, Duration::from_secs(1), || 3), plugin::JobStatus::Done(3));
+
+        jobs.drop_handed_over();
+
+        prop_assert_eq!(jobs.run_waiting(&key, 4, Duration::from_millis(1), || {
+            std::thread::sleep(Duration::from_millis(50));
+
+            4
+        }), plugin::JobStatus::Pending);
+    }
+
+    #[test]
+    fn should_limit_only_running_jobs(key in "[a-z]{1,8}") {
+        let mut jobs: plugin::Jobs<u32> = plugin::Jobs::default();
+
+        for idx in 0..2 {
+            prop_assert_eq!(jobs.run(&format!("{}{}", key, idx), 2, move || {
+                std::thread::sleep(Duration::from_millis(50));
+
+                idx
+            }), plugin::JobStatus::Pending);
+        }
+
+        prop_assert_eq!(jobs.run(&format!("{}busy", key), 2, || 2), plugin::JobStatus::Busy);
+
+        // Wait until both have finished without being collected
+        for _ in 0..100 {
+            jobs.poll(Instant::now());
+
+            if 0 == jobs.running() {
+                break;
+            }
+
+            std::thread::sleep(Duration::from_millis(10));
+        }
+
+        prop_assert_eq!(jobs.running(), 0);
+        prop_assert_ne!(jobs.run(&format!("{}busy", key), 2, || 2), plugin::JobStatus::Busy);
+
+        // Results nobody collects are dropped after a while
+        jobs.poll(Instant::now() + Duration::from_secs(60));
+
+        prop_assert!(!jobs.entries.contains_key(&format!("{}0", key)));
+        prop_assert!(!jobs.entries.contains_key(&format!("{}1", key)));
+    }
+
+    #[test]
+    fn should_drop_uncollected_results(key in "[a-z]{1,8}") {
+        let mut jobs: plugin::Jobs<u32> = plugin::Jobs::default();
+
+        prop_assert_eq!(jobs.run(&key, 2, || {
+            std::thread::sleep(Duration::from_millis(20));
+
+            1
+        }), plugin::JobStatus::Pending);
+
+        // Running jobs are kept
+        jobs.drop_finished();
+
+        prop_assert!(jobs.entries.contains_key(&key));
+
+        for _ in 0..100 {
+            jobs.poll(Instant::now());
+
+            if 0 == jobs.running() {
+                break;
+            }
+
+            std::thread::sleep(Duration::from_millis(10));
+        }
+
+        // A run that has not collected the result gives it up
+        jobs.drop_finished();
+
+        prop_assert!(jobs.entries.is_empty());
+        prop_assert_ne!(jobs.run(&key, 2, || 2), plugin::JobStatus::Done(1));
+    }
+
+    #[test]
+    fn should_refresh_helper_in_background(key in "[a-z]{1,8}") {
+        let mut state = plugin::PluginState::default();
+        let calls = Arc::new(AtomicUsize::new(0));
+
+        let query = |calls: &Arc<AtomicUsize>| {
+            let calls = calls.clone();
+
+            move || {
+                calls.fetch_add(1, Ordering::SeqCst);
+
+                String::from("42")
+            }
+        };
+
+        // Nothing is known until the first query has finished
+        for _ in 0..100 {
+            if state.refresh_helper(&key, query(&calls)).is_some() {
+                break;
+            }
+
+            std::thread::sleep(Duration::from_millis(10));
+        }
+
+        // Fresh results are handed out without querying again
+        prop_assert_eq!(state.refresh_helper(&key, query(&calls)), Some(String::from("42")));
+        prop_assert_eq!(calls.load(Ordering::SeqCst), 1);
+
+        // Outdated results are still handed out while the refresh runs
+        state.outdate_helper(&key);
+
+        prop_assert_eq!(state.refresh_helper(&key, query(&calls)), Some(String::from("42")));
+    }
+}
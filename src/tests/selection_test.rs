@@ -0,0 +1,43 @@
+///
+/// @package subtle-rs
+///
+/// @file Selection tests
+/// @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+/// @version $Id$
+///
+/// This program can be distributed under the terms of the GNU GPLv3.
+/// See the file LICENSE for details.
+///
+
+use proptest::prelude::*;
+use crate::selection;
+use crate::selection::SelectionKind;
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_find_content_type(mime in "(image|application)/[a-z]{1,8}") {
+        let targets: Vec<String> = ["TARGETS", "TIMESTAMP", mime.as_str()].iter()
+            .map(|target| target.to_string())
+            .collect();
+
+        prop_assert_eq!(selection::find_content_type(&targets), mime.clone());
+        prop_assert_eq!(selection::find_content_type(&[mime.clone(), String::from("UTF8_STRING")]), "text");
+        prop_assert_eq!(selection::find_content_type(&[String::from("text/plain;charset=utf-8")]), "text");
+        prop_assert_eq!(selection::find_content_type(&[String::from("TARGETS")]), "");
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_parse_selection_kinds(name in "[a-z]{1,10}") {
+        prop_assert_eq!(SelectionKind::parse(""), Some(SelectionKind::Clipboard));
+        prop_assert_eq!(SelectionKind::parse("clipboard"), Some(SelectionKind::Clipboard));
+        prop_assert_eq!(SelectionKind::parse(" primary "), Some(SelectionKind::Primary));
+
+        if !["clipboard", "primary"].contains(&name.as_str()) {
+            prop_assert_eq!(SelectionKind::parse(&name), None);
+        }
+    }
+}
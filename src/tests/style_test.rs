@@ -11,7 +11,7 @@
 
 use proptest::prelude::*;
 use crate::spacing::Spacing;
-use crate::style::{CalcSpacing, Style};
+use crate::style::{pixel_to_hex, CalcSpacing, Style};
 
 proptest! {
     #![proptest_config(ProptestConfig::with_cases(5))]
@@ -36,4 +36,10 @@ proptest! {
         prop_assert_eq!(style.calc_spacing(CalcSpacing::Bottom), n * 2 * 3);
         prop_assert_eq!(style.calc_spacing(CalcSpacing::Left), n * 2 * 3);
     }
+
+    #[test]
+    fn should_convert_pixel_to_hex(r in 0i32..256, g in 0i32..256, b in 0i32..256) {
+        prop_assert_eq!(pixel_to_hex(r << 16 | g << 8 | b), Some(format!("#{:02x}{:02x}{:02x}", r, g, b)));
+        prop_assert_eq!(pixel_to_hex(-1), None);
+    }
 }
@@ -0,0 +1,80 @@
+///
+/// @package subtle-rs
+///
+/// @file Text tests
+/// @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+/// @version $Id$
+///
+/// This program can be distributed under the terms of the GNU GPLv3.
+/// See the file LICENSE for details.
+///
+
+use proptest::prelude::*;
+use crate::text;
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_measure_ascii(value in "[a-zA-Z0-9 :]{0,32}") {
+        prop_assert_eq!(text::display_width(&value), value.len());
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_ignore_combining_marks(value in "[a-z]{1,8}") {
+        // Add combining acute accent to every char
+        let combined: String = value.chars()
+            .flat_map(|ch| [ch, '\u{301}'])
+            .collect();
+
+        prop_assert_eq!(text::display_width(&combined), value.len());
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_measure_wide_cjk(value in "[\u{4e00}-\u{9fff}]{1,8}") {
+        prop_assert_eq!(text::display_width(&value), value.chars().count() * 2);
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_measure_emoji_sequences(prefix in "[a-z]{0,4}") {
+        // Woman technologist and family as zero width joiner sequences
+        prop_assert_eq!(text::display_width(&format!("{}\u{1f469}\u{200d}\u{1f4bb}", prefix)), prefix.len() + 2);
+        prop_assert_eq!(text::display_width(&format!("{}\u{1f468}\u{200d}\u{1f469}\u{200d}\u{1f467}", prefix)),
+            prefix.len() + 2);
+
+        // Heart with emoji presentation selector
+        prop_assert_eq!(text::display_width(&format!("{}\u{2764}\u{fe0f}", prefix)), prefix.len() + 2);
+
+        // Figure space is a single column
+        prop_assert_eq!(text::display_width(&format!("{}\u{2007}", prefix)), prefix.len() + 1);
+    }
+}
+
+proptest! {
+    #![proptest_config(ProptestConfig::with_cases(5))]
+    #[test]
+    fn should_truncate_text(value in "[a-z\u{4e00}-\u{9fff}]{1,16}", cells in 1usize..20) {
+        let truncated = text::truncate(&value, cells, "\u{2026}");
+
+        prop_assert!(text::display_width(&truncated) <= cells);
+
+        if text::display_width(&value) <= cells {
+            prop_assert_eq!(&truncated, &value);
+        } else {
+            prop_assert!(truncated.ends_with('\u{2026}'));
+            prop_assert!(value.starts_with(truncated.trim_end_matches('\u{2026}')));
+        }
+
+        // Grapheme clusters stay intact
+        prop_assert_eq!(text::truncate("e\u{301}e\u{301}e\u{301}", 2, ""), "e\u{301}e\u{301}");
+        prop_assert_eq!(text::truncate("\u{1f469}\u{200d}\u{1f4bb}ab", 2, "\u{2026}"), "\u{2026}");
+    }
+}
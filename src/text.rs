@@ -0,0 +1,105 @@
+//!
+//! @package subtle-rs
+//!
+//! @file Text functions
+//! @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+//! @version $Id$
+//!
+//! This program can be distributed under the terms of the GNU GPLv3.
+//! See the file LICENSE for details.
+//!
+
+use unicode_segmentation::UnicodeSegmentation;
+use unicode_width::UnicodeWidthStr;
+
+/// Zero width joiner of emoji sequences
+const ZERO_WIDTH_JOINER: char = '\u{200d}';
+
+/// Variation selector to request emoji presentation
+const EMOJI_PRESENTATION: char = '\u{fe0f}';
+
+/// Calculate the display width of a single grapheme cluster in columns
+///
+/// # Arguments
+///
+/// * `cluster` - Grapheme cluster to measure
+///
+/// # Returns
+///
+/// Number of columns, which is either 0, 1 or 2
+pub(crate) fn cluster_width(cluster: &str) -> usize {
+    // Emoji sequences are rendered as a single wide glyph
+    if cluster.contains([ZERO_WIDTH_JOINER, EMOJI_PRESENTATION]) {
+        return 2;
+    }
+
+    cluster.width().min(2)
+}
+
+/// Calculate the display width of the text in columns
+///
+/// # Arguments
+///
+/// * `text` - Text to measure
+///
+/// # Returns
+///
+/// Number of columns of the text
+pub(crate) fn display_width(text: &str) -> usize {
+    text.graphemes(true)
+        .map(cluster_width)
+        .sum()
+}
+
+/// Split text into grapheme clusters with their display width
+///
+/// # Arguments
+///
+/// * `text` - Text to split
+///
+/// # Returns
+///
+/// An [`Iterator`] of grapheme clusters and their number of columns
+pub(crate) fn clusters(text: &str) -> impl Iterator<Item = (&str, usize)> {
+    text.graphemes(true)
+        .map(|cluster| (cluster, cluster_width(cluster)))
+}
+
+/// Truncate text to the given number of columns and append an ellipsis
+///
+/// # Arguments
+///
+/// * `text` - Text to truncate
+/// * `cells` - Maximum number of columns including the ellipsis
+/// * `ellipsis` - Text to append when truncated
+///
+/// # Returns
+///
+/// A [`String`] which is cut between grapheme clusters and fits into the columns
+pub(crate) fn truncate(text: &str, cells: usize, ellipsis: &str) -> String {
+    if display_width(text) <= cells {
+        return text.to_string();
+    }
+
+    // Drop the ellipsis when it doesn't fit itself
+    let ellipsis_width = display_width(ellipsis);
+    let (budget, ellipsis) = if ellipsis_width <= cells {
+        (cells - ellipsis_width, ellipsis)
+    } else {
+        (cells, "")
+    };
+
+    let mut width = 0;
+    let mut truncated: String = clusters(text)
+        .take_while(|(_, cluster_width)| {
+            width += cluster_width;
+
+            width <= budget
+        })
+        .map(|(cluster, _)| cluster)
+        .collect();
+
+    truncated.push_str(ellipsis);
+
+    truncated
+}
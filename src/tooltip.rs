@@ -0,0 +1,330 @@
+//!
+//! @package subtle-rs
+//!
+//! @file Tooltip functions
+//! @copyright (c) 2025-present Christoph Kappel <christoph@unexist.dev>
+//! @version $Id$
+//!
+//! This program can be distributed under the terms of the GNU GPLv3.
+//! See the file LICENSE for details.
+//!
+
+use std::fmt;
+use std::time::{Duration, Instant};
+use anyhow::{Context, Result};
+use easy_min_max::max;
+use log::debug;
+use stdext::function_name;
+use x11rb::COPY_DEPTH_FROM_PARENT;
+use x11rb::connection::Connection;
+use x11rb::protocol::xproto::{ChangeGCAux, ChangeWindowAttributesAux, ConfigureWindowAux, ConnectionExt, CreateWindowAux, EventMask, StackMode, Window, WindowClass};
+use crate::config::Config;
+use crate::font;
+use crate::subtle::Subtle;
+
+/// Delay until the tooltip is shown
+const TOOLTIP_DELAY: Duration = Duration::from_millis(500);
+
+#[derive(Default, Debug)]
+pub(crate) struct Tooltip {
+    /// Tooltip window
+    pub(crate) win: Window,
+    /// Hovered item as screen index and panel x position
+    pub(crate) owner: Option<(usize, i16)>,
+    /// Whether the hovered item is on the bottom panel
+    pub(crate) is_bottom: bool,
+    /// Text of the tooltip
+    pub(crate) text: String,
+    /// Time when the tooltip is shown
+    pub(crate) deadline: Option<Instant>,
+    /// Whether the tooltip is visible
+    pub(crate) is_visible: bool,
+}
+
+impl fmt::Display for Tooltip {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "(owner={:?}, is_bottom={}, text={}, is_visible={})",
+               self.owner, self.is_bottom, self.text, self.is_visible)
+    }
+}
+
+/// Check config and init all tooltip related options
+///
+/// # Arguments
+///
+/// * `config` - Config values read either from args or config file
+/// * `subtle` - Global state object
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn init(_config: &Config, subtle: &mut Subtle) -> Result<()> {
+    let conn = subtle.conn.get().context("Failed to get connection")?;
+
+    let default_screen = &conn.setup().roots[subtle.screen_num];
+
+    let win = conn.generate_id()?;
+
+    let aux = CreateWindowAux::default()
+        .event_mask(EventMask::EXPOSURE)
+        .override_redirect(1);
+
+    conn.create_window(COPY_DEPTH_FROM_PARENT, win, default_screen.root,
+                       0, 0, 1, 1, 1,
+                       WindowClass::INPUT_OUTPUT, default_screen.root_visual, &aux)?.check()?;
+
+    subtle.tooltip.get_mut().win = win;
+
+    debug!("{}", function_name!());
+
+    Ok(())
+}
+
+/// Set or update the tooltip of the hovered item
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+/// * `owner` - Hovered item as screen index and panel x position
+/// * `is_bottom` - Whether the item is on the bottom panel
+/// * `text` - Text of the tooltip; empty to clear it
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn hover(subtle: &Subtle, owner: (usize, i16), is_bottom: bool, text: &str) -> Result<()> {
+    if text.is_empty() {
+        return leave(subtle, None);
+    }
+
+    let mut tooltip = subtle.tooltip.borrow_mut();
+
+    if Some(owner) == tooltip.owner {
+        // Redraw when the text has changed in the meantime
+        if tooltip.text != text {
+            tooltip.text = text.to_string();
+
+            if tooltip.is_visible {
+                drop(tooltip);
+
+                show(subtle)?;
+            }
+        }
+    } else {
+        if tooltip.is_visible {
+            subtle.conn.get().context("Failed to get connection")?.unmap_window(tooltip.win)?;
+        }
+
+        tooltip.owner = Some(owner);
+        tooltip.is_bottom = is_bottom;
+        tooltip.text = text.to_string();
+        tooltip.deadline = Some(Instant::now() + TOOLTIP_DELAY);
+        tooltip.is_visible = false;
+    }
+
+    Ok(())
+}
+
+/// Clear and hide the tooltip
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+/// * `owner` - Only clear when owned by this item or [`None`] to always clear
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn leave(subtle: &Subtle, owner: Option<(usize, i16)>) -> Result<()> {
+    let mut tooltip = subtle.tooltip.borrow_mut();
+
+    if tooltip.owner.is_none() || (owner.is_some() && owner != tooltip.owner) {
+        return Ok(());
+    }
+
+    if tooltip.is_visible {
+        subtle.conn.get().context("Failed to get connection")?.unmap_window(tooltip.win)?;
+    }
+
+    tooltip.owner = None;
+    tooltip.text.clear();
+    tooltip.deadline = None;
+    tooltip.is_visible = false;
+
+    debug!("{}", function_name!());
+
+    Ok(())
+}
+
+/// Calculate the time until the tooltip is due
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+///
+/// # Returns
+///
+/// Either [`Some`] with the timeout or [`None`] when nothing is pending
+pub(crate) fn next_timeout(subtle: &Subtle) -> Option<Duration> {
+    subtle.tooltip.borrow().deadline
+        .map(|deadline| deadline.saturating_duration_since(Instant::now()))
+}
+
+/// Show the tooltip when the delay has passed
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn update(subtle: &Subtle) -> Result<()> {
+    let is_due = subtle.tooltip.borrow().deadline
+        .is_some_and(|deadline| deadline <= Instant::now());
+
+    if is_due {
+        subtle.tooltip.borrow_mut().deadline = None;
+
+        show(subtle)?;
+    }
+
+    Ok(())
+}
+
+/// Resize, place and map the tooltip window
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+fn show(subtle: &Subtle) -> Result<()> {
+    let conn = subtle.conn.get().context("Failed to get connection")?;
+    let style = &subtle.views_style;
+
+    let mut tooltip = subtle.tooltip.borrow_mut();
+
+    let Some((screen_idx, panel_x)) = tooltip.owner else {
+        return Ok(());
+    };
+
+    let (Some(screen), Some(font)) = (subtle.screens.get(screen_idx), style.get_font(subtle)) else {
+        return Ok(());
+    };
+
+    // Calculate size of all lines
+    let mut width = 0;
+    let mut nlines: u16 = 0;
+
+    for line in tooltip.text.split('\n') {
+        let (line_width, _, _) = font.calc_text_width(conn, &line.to_string(), false)?;
+
+        width = max!(width, line_width);
+        nlines += 1;
+    }
+
+    width += style.padding.left as u16 + style.padding.right as u16;
+
+    let height = nlines * font.height + style.padding.top as u16 + style.padding.bottom as u16;
+
+    // Place below the top or above the bottom panel and keep it on screen
+    let x = (screen.base.x + panel_x)
+        .min(screen.base.x + screen.base.width as i16 - width as i16 - 2)
+        .max(screen.base.x);
+
+    let y = if tooltip.is_bottom {
+        screen.base.y + screen.base.height as i16 - subtle.panel_height as i16 - height as i16 - 2
+    } else {
+        screen.base.y + subtle.panel_height as i16
+    };
+
+    conn.change_window_attributes(tooltip.win, &ChangeWindowAttributesAux::default()
+        .background_pixel(style.bg as u32)
+        .border_pixel(style.fg as u32))?.check()?;
+
+    conn.configure_window(tooltip.win, &ConfigureWindowAux::default()
+        .x(x as i32)
+        .y(y as i32)
+        .width(width as u32)
+        .height(height as u32)
+        .stack_mode(StackMode::ABOVE))?.check()?;
+
+    conn.map_window(tooltip.win)?.check()?;
+
+    tooltip.is_visible = true;
+
+    drop(tooltip);
+
+    render(subtle)?;
+
+    debug!("{}: x={}, y={}, width={}, height={}", function_name!(), x, y, width, height);
+
+    Ok(())
+}
+
+/// Render the tooltip text
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn render(subtle: &Subtle) -> Result<()> {
+    let conn = subtle.conn.get().context("Failed to get connection")?;
+    let style = &subtle.views_style;
+
+    let tooltip = subtle.tooltip.borrow();
+
+    if !tooltip.is_visible {
+        return Ok(());
+    }
+
+    if let Some(font) = style.get_font(subtle) {
+        conn.clear_area(false, tooltip.win, 0, 0, 0, 0)?.check()?;
+
+        conn.change_gc(subtle.draw_gc, &ChangeGCAux::default()
+            .font(font.fontable)
+            .foreground(style.fg as u32)
+            .background(style.bg as u32))?.check()?;
+
+        for (line_idx, line) in tooltip.text.split('\n').enumerate() {
+            conn.image_text16(tooltip.win, subtle.draw_gc, style.padding.left,
+                              style.padding.top + font.y as i16 + (line_idx as u16 * font.height) as i16,
+                              &font::encode_text(line))?.check()?;
+        }
+    }
+
+    conn.flush()?;
+
+    debug!("{}: tooltip={}", function_name!(), tooltip);
+
+    Ok(())
+}
+
+/// Tidy up afterwards
+///
+/// # Arguments
+///
+/// * `subtle` - Global state object
+///
+/// # Returns
+///
+/// A [`Result`] with either [`unit`] on success or otherwise [`anyhow::Error`]
+pub(crate) fn finish(subtle: &Subtle) -> Result<()> {
+    if let Some(conn) = subtle.conn.get() {
+        let tooltip = subtle.tooltip.borrow();
+
+        if 0 != tooltip.win {
+            conn.destroy_window(tooltip.win)?;
+        }
+    }
+
+    debug!("{}", function_name!());
+
+    Ok(())
+}
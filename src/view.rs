@@ -25,6 +25,10 @@ use crate::config::{Config, MixedConfigVal};
 use crate::subtle::Subtle;
 use crate::tagging::Tagging;
 use crate::icon::Icon;
+#[cfg(feature = "plugins")]
+use crate::plugin;
+#[cfg(feature = "plugins")]
+use crate::plugin::PluginEvents;
 
 bitflags! {
     /// Config and state-flags for [`View`]
@@ -116,6 +120,9 @@ impl View {
                 } else {
                     screen.view_idx.set(view_idx as isize);
                 }
+
+                #[cfg(feature = "plugins")]
+                plugin::notify(subtle, PluginEvents::VIEW_CHANGE);
             }
         }
 